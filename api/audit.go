@@ -0,0 +1,74 @@
+package api
+
+import (
+	"prompt-share-backend/middleware"
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditMeta 当前请求的操作者与来源信息
+func auditMeta(c *gin.Context) service.AuditMeta {
+	return service.AuditMeta{
+		ActorID:   currentUserID(c),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: c.GetString(middleware.RequestIDKey),
+	}
+}
+
+// audit 以当前用户为操作者追加审计记录
+func audit(c *gin.Context, action, targetType string, targetID uint, before, after interface{}) {
+	service.Audit(auditMeta(c), action, targetType, targetID, before, after)
+}
+
+// AdminListAudit 审计日志
+// @Summary list audit log entries, newest first
+// @Tags admin
+// @Produce json
+// @Param actor_id query int false "user who performed the action"
+// @Param action query string false "exact action such as prompt.delete, or a prefix such as auth or moderation"
+// @Param target_type query string false "user, prompt, comment, file, collection, webhook or content_rule"
+// @Param target_id query int false "target id"
+// @Param ip query string false "client IP"
+// @Param request_id query string false "X-Request-ID of the request"
+// @Param from query string false "at or after, YYYY-MM-DD or RFC3339"
+// @Param to query string false "before, YYYY-MM-DD (inclusive day) or RFC3339"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/audit [get]
+func AdminListAudit(c *gin.Context) {
+	errs := utils.FieldErrors{}
+	f := service.AuditFilter{Action: c.Query("action"), TargetType: c.Query("target_type"),
+		IP: c.Query("ip"), RequestID: c.Query("request_id")}
+	for field, dst := range map[string]*uint{"actor_id": &f.ActorID, "target_id": &f.TargetID} {
+		if v := c.Query(field); v != "" {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				errs.Add(field, "must be an integer")
+			}
+			*dst = uint(id)
+		}
+	}
+	var err error
+	if f.From, err = parseDateParam(c.Query("from"), false); err != nil {
+		errs.Add("from", err.Error())
+	}
+	if f.To, err = parseDateParam(c.Query("to"), true); err != nil {
+		errs.Add("to", err.Error())
+	}
+	if len(errs) > 0 {
+		utils.ValidationError(c, errs)
+		return
+	}
+	list, info, err := service.ListAuditLogs(f, pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
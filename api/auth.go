@@ -4,6 +4,7 @@ import (
 	"prompt-share-backend/model"
 	"prompt-share-backend/service"
 	"prompt-share-backend/utils"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -34,6 +35,9 @@ func Register(c *gin.Context) {
 		utils.Error(c, 1, err.Error())
 		return
 	}
+	meta := auditMeta(c)
+	meta.ActorID = u.ID
+	service.Audit(meta, model.AuditRegister, model.TargetUser, u.ID, nil, u)
 	utils.Success(c, gin.H{"message": "registered"})
 }
 
@@ -55,11 +59,79 @@ func Login(c *gin.Context) {
 		return
 	}
 	token, user, err := service.Login(in.Username, in.Password)
+	meta := auditMeta(c)
 	if err != nil {
+		service.Audit(meta, model.AuditLoginFailed, model.TargetUser, 0, nil, gin.H{"username": in.Username})
 		utils.Error(c, 1, "username or password invalid")
 		return
 	}
+	meta.ActorID = user.ID
+	service.Audit(meta, model.AuditLogin, model.TargetUser, user.ID, nil, nil)
 	// hide password
 	user.PasswordHash = ""
 	utils.Success(c, gin.H{"token": token, "user": user})
 }
+
+// GetMe 当前用户信息
+// @Summary get current user
+// @Tags auth
+// @Produce json
+// @Success 200 {object} model.User
+// @Router /me [get]
+func GetMe(c *gin.Context) {
+	u, err := service.GetUser(currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, u)
+}
+
+// UpdateMe 修改个人资料
+// @Summary update current user's profile
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param data body service.ProfileInput true "avatar, nsfw_preference (show, blur or hide)"
+// @Success 200 {object} model.User
+// @Router /me [patch]
+func UpdateMe(c *gin.Context) {
+	var in service.ProfileInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	u, err := service.UpdateProfile(currentUserID(c), in)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, u)
+}
+
+// AdminSetUserRole 修改用户角色
+// @Summary change a user's role
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "user id"
+// @Param data body map[string]interface{} true "role: user, moderator or admin"
+// @Success 200 {object} model.User
+// @Router /admin/users/{id}/role [put]
+func AdminSetUserRole(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	before, u, err := service.SetUserRole(currentUserID(c), uint(id), in.Role)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	audit(c, model.AuditRoleChange, model.TargetUser, u.ID, before, u)
+	utils.Success(c, u)
+}
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"prompt-share-backend/model"
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateCollection 创建收藏夹
+// @Summary create collection
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param data body map[string]interface{} true "name, description, visibility"
+// @Success 200 {object} model.Collection
+// @Router /collections [post]
+func CreateCollection(c *gin.Context) {
+	var in struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Visibility  string `json:"visibility"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	col := &model.Collection{
+		UserID:      currentUserID(c),
+		Name:        in.Name,
+		Description: in.Description,
+		Visibility:  in.Visibility,
+	}
+	if err := service.CreateCollection(col); err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, col)
+}
+
+// ListCollections 公开收藏夹列表
+// @Summary list public collections
+// @Tags collections
+// @Produce json
+// @Param user_id query int false "owner id"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param page query int false "page, used only without cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /collections [get]
+func ListCollections(c *gin.Context) {
+	req := pageRequest(c, service.DefaultPageSize)
+	ownerID, _ := strconv.ParseUint(c.Query("user_id"), 10, 64)
+
+	var list []model.Collection
+	var info service.PageInfo
+	var err error
+	if ownerID != 0 {
+		list, info, err = service.ListUserCollections(uint(ownerID), currentUserID(c), req)
+	} else {
+		list, info, err = service.ListPublicCollections(currentUserID(c), req)
+	}
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// ListMyCollections 我的收藏夹
+// @Summary list my collections
+// @Tags collections
+// @Produce json
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param page query int false "page, used only without cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /me/collections [get]
+func ListMyCollections(c *gin.Context) {
+	uid := currentUserID(c)
+	list, info, err := service.ListUserCollections(uid, uid, pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// ListFollowedCollections 我关注的收藏夹
+// @Summary list followed collections
+// @Tags collections
+// @Produce json
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param page query int false "page, used only without cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /me/collections/following [get]
+func ListFollowedCollections(c *gin.Context) {
+	list, info, err := service.ListFollowedCollections(currentUserID(c), pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// GetCollection 收藏夹详情
+// @Summary get collection
+// @Tags collections
+// @Produce json
+// @Param id path int true "collection id"
+// @Param token query string false "share token for unlisted collections"
+// @Success 200 {object} model.Collection
+// @Router /collections/{id} [get]
+func GetCollection(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	col, err := service.GetCollection(uint(id), currentUserID(c), c.Query("token"))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, col)
+}
+
+// UpdateCollection 修改收藏夹
+// @Summary update collection
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param id path int true "collection id"
+// @Param data body service.CollectionUpdate true "fields to update"
+// @Success 200 {object} model.Collection
+// @Router /collections/{id} [put]
+func UpdateCollection(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in service.CollectionUpdate
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	col, err := service.UpdateCollection(uint(id), currentUserID(c), in)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, col)
+}
+
+// DeleteCollection 删除收藏夹
+// @Summary delete collection
+// @Tags collections
+// @Produce json
+// @Param id path int true "collection id"
+// @Success 200 {object} map[string]interface{}
+// @Router /collections/{id} [delete]
+func DeleteCollection(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	col, err := service.DeleteCollection(uint(id), currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	audit(c, model.AuditCollectionDelete, model.TargetCollection, col.ID, col, nil)
+	utils.Success(c, gin.H{"deleted": id})
+}
+
+// AddCollectionItem 添加 prompt 到收藏夹
+// @Summary add prompt to collection
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param id path int true "collection id"
+// @Param data body map[string]interface{} true "prompt_id, note"
+// @Success 200 {object} model.CollectionItem
+// @Router /collections/{id}/items [post]
+func AddCollectionItem(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in struct {
+		PromptID uint   `json:"prompt_id" binding:"required"`
+		Note     string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	item, err := service.AddCollectionItem(uint(id), currentUserID(c), in.PromptID, in.Note)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, item)
+}
+
+// UpdateCollectionItem 修改成员备注
+// @Summary update collection item note
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param id path int true "collection id"
+// @Param prompt_id path int true "prompt id"
+// @Param data body map[string]interface{} true "note"
+// @Success 200 {object} model.CollectionItem
+// @Router /collections/{id}/items/{prompt_id} [put]
+func UpdateCollectionItem(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	pid, _ := strconv.ParseUint(c.Param("prompt_id"), 10, 64)
+	var in struct {
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	item, err := service.UpdateCollectionItem(uint(id), currentUserID(c), uint(pid), in.Note)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, item)
+}
+
+// RemoveCollectionItem 从收藏夹移除 prompt
+// @Summary remove prompt from collection
+// @Tags collections
+// @Produce json
+// @Param id path int true "collection id"
+// @Param prompt_id path int true "prompt id"
+// @Success 200 {object} map[string]interface{}
+// @Router /collections/{id}/items/{prompt_id} [delete]
+func RemoveCollectionItem(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	pid, _ := strconv.ParseUint(c.Param("prompt_id"), 10, 64)
+	if err := service.RemoveCollectionItem(uint(id), currentUserID(c), uint(pid)); err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"removed": pid})
+}
+
+// ReorderCollection 重排收藏夹成员
+// @Summary reorder collection items
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param id path int true "collection id"
+// @Param data body map[string]interface{} true "prompt_ids in new order"
+// @Success 200 {object} map[string]interface{}
+// @Router /collections/{id}/order [put]
+func ReorderCollection(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in struct {
+		PromptIDs []uint `json:"prompt_ids"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	if err := service.ReorderCollection(uint(id), currentUserID(c), in.PromptIDs); err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"ok": true})
+}
+
+// FollowCollection 关注收藏夹
+// @Summary follow collection
+// @Tags collections
+// @Produce json
+// @Param id path int true "collection id"
+// @Success 200 {object} map[string]interface{}
+// @Router /collections/{id}/follow [post]
+func FollowCollection(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	count, err := service.FollowCollection(uint(id), currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"followed": true, "follower_count": count})
+}
+
+// UnfollowCollection 取消关注收藏夹
+// @Summary unfollow collection
+// @Tags collections
+// @Produce json
+// @Param id path int true "collection id"
+// @Success 200 {object} map[string]interface{}
+// @Router /collections/{id}/follow [delete]
+func UnfollowCollection(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	count, err := service.UnfollowCollection(uint(id), currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"followed": false, "follower_count": count})
+}
+
+// CloneCollection 复制收藏夹
+// @Summary clone collection
+// @Tags collections
+// @Produce json
+// @Param id path int true "collection id"
+// @Param token query string false "share token for unlisted collections"
+// @Success 200 {object} model.Collection
+// @Router /collections/{id}/clone [post]
+func CloneCollection(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	col, err := service.CloneCollection(uint(id), currentUserID(c), c.Query("token"))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, col)
+}
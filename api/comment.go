@@ -14,12 +14,13 @@ import (
 // @Tags comment
 // @Produce json
 // @Param id path int true "prompt id"
-// @Param data body map[string]interface{} true "data"
+// @Param data body map[string]interface{} true "content (Markdown, @username mentions), parent_id (optional, comment to reply to)"
 // @Success 200 {object} model.Comment
 // @Router /prompts/{id}/comments [post]
 func CreateComment(c *gin.Context) {
 	var in struct {
-		Content string `json:"content" binding:"required"`
+		Content  string `json:"content" binding:"required"`
+		ParentID uint   `json:"parent_id"`
 	}
 	if err := c.ShouldBindJSON(&in); err != nil {
 		utils.Error(c, 1, err.Error())
@@ -35,29 +36,138 @@ func CreateComment(c *gin.Context) {
 	com := &model.Comment{
 		UserID:   uid,
 		PromptID: uint(pid),
+		ParentID: in.ParentID,
 		Content:  in.Content,
 	}
 	if err := service.CreateComment(com); err != nil {
-		utils.Error(c, 1, err.Error())
+		serviceError(c, err)
 		return
 	}
 	utils.Success(c, com)
 }
 
 // ListComments 获取评论列表
-// @Summary list comments
+// @Summary list top-level comments, newest first, each with its reply tree
 // @Tags comment
 // @Produce json
 // @Param id path int true "prompt id"
-// @Success 200 {object} []model.Comment
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
 // @Router /prompts/{id}/comments [get]
 func ListComments(c *gin.Context) {
 	pidStr := c.Param("id")
 	pid, _ := strconv.ParseUint(pidStr, 10, 64)
-	list, err := service.ListComments(uint(pid))
+	if !promptVisible(c, uint(pid)) {
+		return
+	}
+	list, info, err := service.ListComments(uint(pid), currentUserID(c), pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// ListReplies 获取评论的直接回复
+// @Summary list direct replies of a comment, oldest first
+// @Tags comment
+// @Produce json
+// @Param id path int true "comment id"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /comments/{id}/replies [get]
+func ListReplies(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	list, info, err := service.ListReplies(uint(id), currentUserID(c), pageRequest(c, service.DefaultPageSize))
 	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// UpdateComment 修改评论
+// @Summary edit own comment
+// @Tags comment
+// @Accept json
+// @Produce json
+// @Param id path int true "comment id"
+// @Param data body map[string]interface{} true "content"
+// @Success 200 {object} model.Comment
+// @Router /comments/{id} [patch]
+func UpdateComment(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
 		utils.Error(c, 1, err.Error())
 		return
 	}
-	utils.Success(c, list)
+	com, err := service.UpdateComment(uint(id), currentUserID(c), in.Content)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, com)
+}
+
+// DeleteComment 删除评论
+// @Summary delete own comment, replies are kept under a placeholder
+// @Tags comment
+// @Produce json
+// @Param id path int true "comment id"
+// @Success 200 {object} map[string]interface{}
+// @Router /comments/{id} [delete]
+func DeleteComment(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	com, err := service.DeleteComment(uint(id), currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	if com != nil {
+		audit(c, model.AuditCommentDelete, model.TargetComment, com.ID, com, nil)
+	}
+	utils.Success(c, gin.H{"deleted": id})
+}
+
+// AddReaction 添加表情回应
+// @Summary react to a comment with an emoji
+// @Tags comment
+// @Produce json
+// @Param id path int true "comment id"
+// @Param emoji path string true "a single emoji, URL-encoded"
+// @Success 200 {array} model.ReactionCount
+// @Router /comments/{id}/reactions/{emoji} [post]
+func AddReaction(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	counts, err := service.AddReaction(uint(id), currentUserID(c), c.Param("emoji"))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, counts)
+}
+
+// RemoveReaction 取消表情回应
+// @Summary remove own emoji reaction from a comment
+// @Tags comment
+// @Produce json
+// @Param id path int true "comment id"
+// @Param emoji path string true "a single emoji, URL-encoded"
+// @Success 200 {array} model.ReactionCount
+// @Router /comments/{id}/reactions/{emoji} [delete]
+func RemoveReaction(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	counts, err := service.RemoveReaction(uint(id), currentUserID(c), c.Param("emoji"))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, counts)
 }
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"prompt-share-backend/model"
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListContentRules 内容规则列表
+// @Summary list content filter rules
+// @Tags content-rules
+// @Produce json
+// @Success 200 {array} model.ContentRule
+// @Router /admin/content-rules [get]
+func ListContentRules(c *gin.Context) {
+	list, err := service.ListContentRules()
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, list)
+}
+
+// CreateContentRule 新建内容规则
+// @Summary create a content filter rule, effective immediately
+// @Description Keywords and regexes (RE2, case-insensitive) match text after width folding, lower-casing and homoglyph replacement.
+// @Tags content-rules
+// @Accept json
+// @Produce json
+// @Param data body service.ContentRuleInput true "name, kind, patterns and action required"
+// @Success 200 {object} model.ContentRule
+// @Router /admin/content-rules [post]
+func CreateContentRule(c *gin.Context) {
+	var in service.ContentRuleInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	r, err := service.CreateContentRule(currentUserID(c), in)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	audit(c, model.AuditContentRuleCreate, model.TargetContentRule, r.ID, nil, r)
+	utils.Success(c, r)
+}
+
+// UpdateContentRule 修改内容规则
+// @Summary update a content filter rule, effective immediately
+// @Tags content-rules
+// @Accept json
+// @Produce json
+// @Param id path int true "rule id"
+// @Param data body service.ContentRuleInput true "omitted fields are unchanged"
+// @Success 200 {object} model.ContentRule
+// @Router /admin/content-rules/{id} [put]
+func UpdateContentRule(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in service.ContentRuleInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	before, r, err := service.UpdateContentRule(uint(id), in)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	audit(c, model.AuditContentRuleUpdate, model.TargetContentRule, r.ID, before, r)
+	utils.Success(c, r)
+}
+
+// DeleteContentRule 删除内容规则
+// @Summary delete a content filter rule
+// @Tags content-rules
+// @Produce json
+// @Param id path int true "rule id"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/content-rules/{id} [delete]
+func DeleteContentRule(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	r, err := service.DeleteContentRule(uint(id))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	audit(c, model.AuditContentRuleDelete, model.TargetContentRule, r.ID, r, nil)
+	utils.Success(c, gin.H{"deleted": id})
+}
+
+// TestContentRules 试运行内容规则
+// @Summary check a text against the active rules without saving anything
+// @Tags content-rules
+// @Accept json
+// @Produce json
+// @Param data body map[string]interface{} true "text, scope (prompt or comment, default prompt)"
+// @Success 200 {array} service.ContentMatch
+// @Router /admin/content-rules/test [post]
+func TestContentRules(c *gin.Context) {
+	var in struct {
+		Text  string `json:"text" binding:"required"`
+		Scope string `json:"scope"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	if in.Scope == "" {
+		in.Scope = "prompt"
+	}
+	matches, err := service.TestContentRules(in.Scope, in.Text)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, matches)
+}
+
+// ReloadContentRules 重新加载内容规则
+// @Summary reload content rules from the database, e.g. after editing them directly
+// @Tags content-rules
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/content-rules/reload [post]
+func ReloadContentRules(c *gin.Context) {
+	if err := service.ReloadContentRules(); err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"ok": true})
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"prompt-share-backend/model"
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// currentUserID 获取当前登录用户 ID，未登录返回 0
+func currentUserID(c *gin.Context) uint {
+	uidRaw, _ := c.Get("user_id")
+	if uid, ok := uidRaw.(uint); ok {
+		return uid
+	}
+	return 0
+}
+
+// promptVisible 校验当前用户能否看到该 prompt，不可见时按不存在输出 404 并返回 false
+// nsfw 偏好为 hide 时被标记的 prompt 输出 403
+func promptVisible(c *gin.Context, id uint) bool {
+	err := service.CheckPromptVisible(id, currentUserID(c))
+	if err == nil {
+		err = service.CheckPromptNSFW(id, currentUserID(c), nsfwPreference(c))
+	}
+	if err != nil {
+		serviceError(c, err)
+		return false
+	}
+	return true
+}
+
+// nsfwPreference 当前用户的 nsfw 偏好，同一请求内只查询一次；查询失败时按 hide 处理
+func nsfwPreference(c *gin.Context) string {
+	if pref := c.GetString("nsfw_preference"); pref != "" {
+		return pref
+	}
+	pref, err := service.NSFWPreference(currentUserID(c))
+	if err != nil {
+		log.Println("load nsfw preference failed:", err)
+		pref = model.NSFWHide
+	}
+	c.Set("nsfw_preference", pref)
+	return pref
+}
+
+// serviceError 按 service 层错误类型输出对应的 HTTP 状态码
+func serviceError(c *gin.Context, err error) {
+	var fieldErrs utils.FieldErrors
+	switch {
+	case errors.As(err, &fieldErrs):
+		utils.ValidationError(c, fieldErrs)
+	case errors.Is(err, service.ErrForbidden):
+		utils.ErrorWithHttpCode(c, http.StatusForbidden, 1, err.Error())
+	case errors.Is(err, service.ErrNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		utils.ErrorWithHttpCode(c, http.StatusNotFound, 1, err.Error())
+	case errors.Is(err, service.ErrConflict):
+		utils.ErrorWithHttpCode(c, http.StatusConflict, 1, err.Error())
+	case errors.Is(err, service.ErrInvalidParam):
+		utils.ErrorWithHttpCode(c, http.StatusBadRequest, 1, err.Error())
+	default:
+		utils.Error(c, 1, err.Error())
+	}
+}
@@ -21,7 +21,7 @@ import (
 // @Tags files
 // @Accept multipart/form-data
 // @Param file formData file true "file"
-// @Success 200 {object} model.File
+// @Success 200 {object} model.File "near_duplicates lists existing images that look the same"
 // @Router /files/upload [post]
 func UploadFile(c *gin.Context) {
 	fh, err := c.FormFile("file")
@@ -47,6 +47,7 @@ func UploadFile(c *gin.Context) {
 // @Tags files
 // @Accept multipart/form-data
 // @Param file formData file true "file"
+// @Param reveal query bool false "download an nsfw image although the preference is blur"
 // @Success 200 {object} model.File
 // @Router /files/download/{id} [get]
 func DownloadFile(c *gin.Context) {
@@ -57,6 +58,17 @@ func DownloadFile(c *gin.Context) {
 		utils.Error(c, 1, "file not found")
 		return
 	}
+	nsfw, blur, ok := nsfwFile(c, &f)
+	if !ok {
+		return
+	}
+	if blur {
+		serviceError(c, fmt.Errorf("%w: nsfw image, pass reveal=true to download the original", service.ErrForbidden))
+		return
+	}
+	if nsfw {
+		setFileCache(c, true)
+	}
 	rc, err := service.GetFileReader(f.Path)
 	if err != nil {
 		utils.Error(c, 1, err.Error())
@@ -76,6 +88,7 @@ func DownloadFile(c *gin.Context) {
 // @Description 预览文件
 // @Tags files
 // @Accept  json
+// @Param reveal query bool false "show the original of an nsfw image although the preference is blur"
 // @Route /files/preview/{id} [get]
 func PreviewFile(c *gin.Context) {
 	idStr := c.Param("id")
@@ -88,8 +101,15 @@ func PreviewFile(c *gin.Context) {
 		return
 	}
 
-	c.Header("Cache-Control", "public, max-age=31536000, immutable, s-maxage=31536000")
-	c.Header("Expires", time.Now().AddDate(1, 0, 0).UTC().Format(http.TimeFormat))
+	nsfw, blur, ok := nsfwFile(c, &f)
+	if !ok {
+		return
+	}
+	setFileCache(c, nsfw)
+	if blur {
+		writeBlurred(c, &f)
+		return
+	}
 
 	rc, err := service.GetFileReader(f.Path)
 	if err != nil {
@@ -122,6 +142,16 @@ func Thumbnail(c *gin.Context) {
 		return
 	}
 
+	nsfw, blur, ok := nsfwFile(c, &f)
+	if !ok {
+		return
+	}
+	if blur {
+		setFileCache(c, nsfw)
+		writeBlurred(c, &f)
+		return
+	}
+
 	thumbnail := f.Thumbnail
 	if thumbnail == "" {
 		genThumbnail, err := service.GenThumbnail(&f)
@@ -142,10 +172,47 @@ func Thumbnail(c *gin.Context) {
 
 	// 设置响应头
 	c.Header("Content-Type", "image/jpeg") // 根据实际图像类型调整 MIME 类型
+	setFileCache(c, nsfw)
+
+	// 输出图像数据
+	c.Data(http.StatusOK, "image/jpeg", data)
+}
+
+// nsfwFile 按当前用户的 nsfw 偏好决定文件的输出方式，ok 为 false 时已输出错误
+// nsfw 表示文件被标记，blur 表示应输出模糊的缩略图
+func nsfwFile(c *gin.Context, f *model.File) (nsfw, blur, ok bool) {
+	reveal, _ := strconv.ParseBool(c.Query("reveal"))
+	nsfw, blur, err := service.NSFWFileView(f, currentUserID(c), nsfwPreference(c), reveal)
+	if err != nil {
+		serviceError(c, err)
+		return false, false, false
+	}
+	return nsfw, blur, true
+}
+
+// setFileCache 设置缓存头，nsfw 文件的输出因浏览者而异，只允许私有缓存
+func setFileCache(c *gin.Context, nsfw bool) {
+	if nsfw {
+		c.Header("Cache-Control", "private, no-cache")
+		c.Header("Vary", "Authorization")
+		return
+	}
 	c.Header("Cache-Control", "public, max-age=31536000, immutable, s-maxage=31536000")
 	c.Header("Expires", time.Now().AddDate(1, 0, 0).UTC().Format(http.TimeFormat))
+}
 
-	// 输出图像数据
+// writeBlurred 输出模糊的缩略图
+func writeBlurred(c *gin.Context, f *model.File) {
+	blurred, err := service.BlurredThumbnail(f)
+	if err != nil {
+		utils.Error(c, 1, "generate thumbnail failed")
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(blurred)
+	if err != nil {
+		utils.Error(c, 1, "decode thumbnail failed")
+		return
+	}
 	c.Data(http.StatusOK, "image/jpeg", data)
 }
 
@@ -153,18 +220,16 @@ func Thumbnail(c *gin.Context) {
 func ListFiles(c *gin.Context) {
 	q := c.Query("q")
 	tag := c.Query("tag")
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
-	list, total, err := service.QueryFiles(q, tag, page, size)
+	list, info, err := service.QueryFiles(q, tag, nsfwPreference(c), pageRequest(c, service.DefaultPageSize))
 	if err != nil {
-		utils.Error(c, 1, err.Error())
+		serviceError(c, err)
 		return
 	}
-	utils.Success(c, gin.H{"list": list, "total": total})
+	utils.Success(c, pageResponse(list, info))
 }
 
-// DeleteFile 删除文件
-// @Summary 删除文件
+// DeleteFile 删除文件，移入回收站
+// @Summary 删除文件，仅上传者或版主，保留期内可在回收站恢复
 // @Tags files
 // @Param id path int true "文件ID"
 // @Success 200 {object} map[string]interface{}
@@ -183,11 +248,35 @@ func DeleteFile(c *gin.Context) {
 		return
 	}
 
-	err = service.DeleteFile(uint(id))
+	f, err := service.DeleteFile(uint(id), currentUserID(c))
 	if err != nil {
-		utils.Error(c, 1, err.Error())
+		serviceError(c, err)
 		return
 	}
+	audit(c, model.AuditFileDelete, model.TargetFile, f.ID, f, nil)
 
 	utils.Success(c, gin.H{"message": "file deleted"})
 }
+
+// SimilarFiles 近似重复图片
+// @Summary list near-duplicate images by perceptual hash
+// @Tags files
+// @Produce json
+// @Param id path int true "file id"
+// @Param distance query int false "max hamming distance, default 10, at most 24"
+// @Success 200 {object} map[string]interface{}
+// @Router /files/{id}/similar [get]
+func SimilarFiles(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	distance, err := strconv.Atoi(c.DefaultQuery("distance", strconv.Itoa(service.NearDuplicateDistance)))
+	if err != nil || distance < 0 {
+		utils.ValidationError(c, utils.FieldErrors{"distance": "must be a non-negative integer"})
+		return
+	}
+	list, err := service.SimilarFiles(uint(id), currentUserID(c), nsfwPreference(c), distance)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"list": list})
+}
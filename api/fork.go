@@ -0,0 +1,80 @@
+package api
+
+import (
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForkPrompt 派生
+// @Summary fork prompt into the current user's account
+// @Tags prompts
+// @Produce json
+// @Param id path int true "prompt id"
+// @Success 200 {object} model.Prompt
+// @Router /prompts/{id}/fork [post]
+func ForkPrompt(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	p, err := service.ForkPrompt(uint(id), currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, p)
+}
+
+// ListForks 派生列表
+// @Summary list direct forks of a prompt
+// @Tags prompts
+// @Produce json
+// @Param id path int true "prompt id"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param page query int false "page, used only without cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /prompts/{id}/forks [get]
+func ListForks(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if !promptVisible(c, uint(id)) {
+		return
+	}
+	nsfw := nsfwPreference(c)
+	list, info, err := service.ListForks(uint(id), nsfw, pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	if err := service.FillPromptImages(list); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	list = service.ApplyNSFWPreference(nsfw, list)
+	if err := service.FillUserMarks(currentUserID(c), list); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// GetLineage 派生谱系
+// @Summary get fork lineage tree of a prompt
+// @Tags prompts
+// @Produce json
+// @Param id path int true "prompt id"
+// @Success 200 {object} service.Lineage
+// @Router /prompts/{id}/lineage [get]
+func GetLineage(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if !promptVisible(c, uint(id)) {
+		return
+	}
+	lineage, err := service.GetLineage(uint(id))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, lineage)
+}
@@ -0,0 +1,18 @@
+package api
+
+import (
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListModelSchemas 模型家族及推荐参数约束
+// @Summary list target model families and their parameter schemas
+// @Tags prompts
+// @Produce json
+// @Success 200 {object} []service.ModelSchema
+// @Router /models [get]
+func ListModelSchemas(c *gin.Context) {
+	utils.Success(c, service.ListModelSchemas())
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"prompt-share-backend/model"
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportPrompt 举报 prompt
+// @Summary report a prompt; reports from several users move it to pending review
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param id path int true "prompt id"
+// @Param data body service.ReportInput true "reason: spam, abuse, nsfw, illegal, copyright or other (detail required)"
+// @Success 200 {object} model.Report
+// @Router /prompts/{id}/report [post]
+func ReportPrompt(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in service.ReportInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	r, err := service.ReportPrompt(uint(id), currentUserID(c), in)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, r)
+}
+
+// ReportComment 举报评论
+// @Summary report a comment; reports from several users move it to pending review
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param id path int true "comment id"
+// @Param data body service.ReportInput true "reason: spam, abuse, nsfw, illegal, copyright or other (detail required)"
+// @Success 200 {object} model.Report
+// @Router /comments/{id}/report [post]
+func ReportComment(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in service.ReportInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	r, err := service.ReportComment(uint(id), currentUserID(c), in)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, r)
+}
+
+// ModerationQueue 审核队列
+// @Summary list content with open reports, most reported first
+// @Tags moderation
+// @Produce json
+// @Param target_type query string false "prompt or comment"
+// @Param reason query string false "only content reported for this reason"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /moderation/queue [get]
+func ModerationQueue(c *gin.Context) {
+	list, info, err := service.ModerationQueue(currentUserID(c), c.Query("target_type"), c.Query("reason"),
+		pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// ListReports 举报列表
+// @Summary list individual reports, newest first
+// @Tags moderation
+// @Produce json
+// @Param status query string false "open, resolved or dismissed"
+// @Param target_type query string false "prompt or comment"
+// @Param target_id query int false "reported prompt or comment id"
+// @Param reason query string false "report reason"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /moderation/reports [get]
+func ListReports(c *gin.Context) {
+	targetID, _ := strconv.ParseUint(c.Query("target_id"), 10, 64)
+	f := service.ReportFilter{Status: c.Query("status"), TargetType: c.Query("target_type"),
+		TargetID: uint(targetID), Reason: c.Query("reason")}
+	list, info, err := service.ListReports(currentUserID(c), f, pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// Moderate 处理内容
+// @Summary hide, restore, delete or warn about a prompt or comment, or dismiss its reports
+// @Description Open reports on the content are closed with the action; every action is recorded with its moderator.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param data body service.ModerateInput true "target_type, target_id and action required"
+// @Success 200 {object} model.ModerationAction
+// @Router /moderation/actions [post]
+func Moderate(c *gin.Context) {
+	var in service.ModerateInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	a, err := service.Moderate(currentUserID(c), in)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	audit(c, model.AuditModeration+"."+a.Action, a.TargetType, a.TargetID, nil, a)
+	utils.Success(c, a)
+}
+
+// ListModerationActions 处理记录
+// @Summary list moderation actions, newest first
+// @Tags moderation
+// @Produce json
+// @Param target_type query string false "prompt or comment"
+// @Param target_id query int false "prompt or comment id"
+// @Param moderator_id query int false "moderator user id"
+// @Param user_id query int false "author of the moderated content"
+// @Param action query string false "hide, restore, delete, warn or dismiss"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /moderation/actions [get]
+func ListModerationActions(c *gin.Context) {
+	f := service.ModerationActionFilter{TargetType: c.Query("target_type"), Action: c.Query("action")}
+	for field, dst := range map[string]*uint{"target_id": &f.TargetID, "moderator_id": &f.ModeratorID, "user_id": &f.TargetUserID} {
+		v, _ := strconv.ParseUint(c.Query(field), 10, 64)
+		*dst = uint(v)
+	}
+	list, info, err := service.ListModerationActions(f, pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// ListMyModerationActions 我的内容处理记录
+// @Summary list moderation actions taken on my prompts and comments, including warnings
+// @Tags moderation
+// @Produce json
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /me/moderation [get]
+func ListMyModerationActions(c *gin.Context) {
+	list, info, err := service.ListMyModerationActions(currentUserID(c), pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
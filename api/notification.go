@@ -0,0 +1,119 @@
+package api
+
+import (
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListNotifications 我的通知
+// @Summary list my notifications, most recently updated first, with unread counts
+// @Tags notification
+// @Produce json
+// @Param unread query bool false "only unread notifications"
+// @Param type query string false "like, favorite, comment, reply, mention or fork"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /me/notifications [get]
+func ListNotifications(c *gin.Context) {
+	uid := currentUserID(c)
+	f := service.NotificationFilter{UnreadOnly: c.Query("unread") == "true", Type: c.Query("type")}
+	list, info, err := service.ListNotifications(uid, f, pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	unread, err := service.CountUnreadNotifications(uid)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	resp := pageResponse(list, info)
+	resp["unread"] = unread
+	utils.Success(c, resp)
+}
+
+// MarkNotificationRead 标记通知已读
+// @Summary mark one notification as read
+// @Tags notification
+// @Produce json
+// @Param id path int true "notification id"
+// @Success 200 {object} service.UnreadCounts
+// @Router /me/notifications/{id}/read [post]
+func MarkNotificationRead(c *gin.Context) {
+	uid := currentUserID(c)
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err := service.MarkNotificationRead(uid, uint(id)); err != nil {
+		serviceError(c, err)
+		return
+	}
+	unread, err := service.CountUnreadNotifications(uid)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, unread)
+}
+
+// MarkAllNotificationsRead 全部标记已读
+// @Summary mark all my notifications as read, optionally only one type
+// @Tags notification
+// @Produce json
+// @Param type query string false "only this notification type"
+// @Success 200 {object} map[string]interface{}
+// @Router /me/notifications/read [post]
+func MarkAllNotificationsRead(c *gin.Context) {
+	uid := currentUserID(c)
+	n, err := service.MarkAllNotificationsRead(uid, c.Query("type"))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	unread, err := service.CountUnreadNotifications(uid)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"marked": n, "unread": unread})
+}
+
+// GetNotificationPreferences 通知偏好
+// @Summary get which notification types are enabled
+// @Tags notification
+// @Produce json
+// @Success 200 {object} map[string]bool
+// @Router /me/notification-preferences [get]
+func GetNotificationPreferences(c *gin.Context) {
+	prefs, err := service.NotificationPreferences(currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, prefs)
+}
+
+// UpdateNotificationPreferences 修改通知偏好
+// @Summary enable or disable notification types, omitted types are unchanged
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param data body map[string]bool true "e.g. {\"like\": false}"
+// @Success 200 {object} map[string]bool
+// @Router /me/notification-preferences [put]
+func UpdateNotificationPreferences(c *gin.Context) {
+	var in map[string]bool
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	prefs, err := service.UpdateNotificationPreferences(currentUserID(c), in)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, prefs)
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"prompt-share-backend/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pageRequest 解析分页参数：cursor 优先，没有时按 page 兼容；size 超过上限时截断；with_total=false 跳过总数统计
+func pageRequest(c *gin.Context, defaultSize int) service.PageRequest {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(defaultSize)))
+	return service.PageRequest{
+		Cursor:    c.Query("cursor"),
+		Page:      page,
+		Size:      size,
+		WithTotal: c.DefaultQuery("with_total", "true") != "false",
+	}
+}
+
+// pageResponse 列表响应：list、next_cursor、prev_cursor，以及请求了时的 total
+func pageResponse(list interface{}, info service.PageInfo) gin.H {
+	resp := gin.H{"list": list, "next_cursor": info.NextCursor, "prev_cursor": info.PrevCursor}
+	if info.Total != nil {
+		resp["total"] = *info.Total
+	}
+	return resp
+}
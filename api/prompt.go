@@ -1,11 +1,14 @@
 package api
 
 import (
-	"prompt-share-backend/database"
+	"errors"
+	"prompt-share-backend/dto"
 	"prompt-share-backend/model"
 	"prompt-share-backend/service"
 	"prompt-share-backend/utils"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,80 +17,161 @@ import (
 // @Summary list prompts
 // @Tags prompts
 // @Produce json
-// @Param q query string false "query"
-// @Param tag query string false "tag"
-// @Param page query int false "page"
-// @Param size query int false "page size"
+// @Param q query string false "full-text query: \"phrase\", prefix*, AND/OR/NOT, (group), -exclude, title:/content:/tags:/author:"
+// @Param tag query string false "tag, comma separated tags must all match"
+// @Param tags_any query string false "comma separated, any may match"
+// @Param tags_not query string false "comma separated, none may match"
+// @Param model query string false "target model family"
+// @Param negative query string false "negative prompt contains"
+// @Param param.{name} query string false "recommended parameter equals, e.g. param.sampler=Euler a"
+// @Param author query string false "author name"
+// @Param user_id query int false "publisher user id"
+// @Param source query string false "source_by"
+// @Param from query string false "created at or after, YYYY-MM-DD or RFC3339"
+// @Param to query string false "created before, YYYY-MM-DD (inclusive day) or RFC3339"
+// @Param has_images query bool false "with or without images"
+// @Param min_likes query int false "minimum like count"
+// @Param min_favs query int false "minimum favorite count"
+// @Param sort query string false "newest, likes, favs, comments, trending, relevance"
+// @Param facets query bool false "include tag/author/source facet counts, default true"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param page query int false "page, used only without cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
 // @Success 200 {object} map[string]interface{}
 // @Router /prompts [get]
 func GetPrompts(c *gin.Context) {
-	q := c.Query("q")
-	tag := c.Query("tag")
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
-	list, total, err := service.QueryPrompts(q, tag, page, size)
+	f, errs := promptFilter(c)
+	if len(errs) > 0 {
+		utils.ValidationError(c, errs)
+		return
+	}
+	list, info, err := service.QueryPrompts(f, pageRequest(c, service.DefaultPageSize))
 	if err != nil {
-		utils.Error(c, 1, err.Error())
+		serviceError(c, err)
 		return
 	}
 
-	if len(list) > 0 {
-		// 1. 查询所有id
-		ids := make([]uint, len(list))
-		for i, v := range list {
-			ids[i] = v.ID
-		}
+	if err := service.FillPromptImages(list); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	list = service.ApplyNSFWPreference(f.NSFW, list)
+	if err := service.FillUserMarks(currentUserID(c), list); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
 
-		// 2. 批量查询图片
-		images, err := service.GetPromptImgByPromptIds(ids)
+	resp := pageResponse(list, info)
+	if c.DefaultQuery("facets", "true") != "false" {
+		facets, err := service.FacetPrompts(f, facetLimit)
 		if err != nil {
-			utils.Error(c, 1, err.Error())
+			serviceError(c, err)
 			return
 		}
+		resp["facets"] = facets
+	}
+	utils.Success(c, resp)
+}
 
-		// 3. 绑定图片
-		if len(images) > 0 {
-			// 创建以PromptID为键的图片映射
-			imageMap := make(map[uint][]model.PromptImg)
-			for _, img := range images {
-				imageMap[img.PromptID] = append(imageMap[img.PromptID], img)
-			}
+// facetLimit 每个分面最多返回的取值个数
+const facetLimit = 20
 
-			// 单次遍历将图片绑定到对应的prompt
-			for i := range list {
-				if promptImages, exists := imageMap[list[i].ID]; exists {
-					list[i].Images = promptImages
-				}
+// promptFilter 解析列表查询参数
+func promptFilter(c *gin.Context) (service.PromptFilter, utils.FieldErrors) {
+	errs := utils.FieldErrors{}
+	f := service.PromptFilter{
+		Q:           c.Query("q"),
+		TagsAll:     service.SplitTags(c.Query("tag")),
+		TagsAny:     service.SplitTags(c.Query("tags_any")),
+		TagsNot:     service.SplitTags(c.Query("tags_not")),
+		ModelFamily: c.Query("model"),
+		Negative:    c.Query("negative"),
+		Params:      map[string]string{},
+		Author:      c.Query("author"),
+		Source:      c.Query("source"),
+		Sort:        c.Query("sort"),
+		NSFW:        nsfwPreference(c),
+	}
+	for key, values := range c.Request.URL.Query() {
+		if name := strings.TrimPrefix(key, "param."); name != key && len(values) > 0 {
+			f.Params[name] = values[0]
+		}
+	}
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			errs.Add("user_id", "must be an integer")
+		}
+		f.UserID = uint(id)
+	}
+	for field, dst := range map[string]*int64{"min_likes": &f.MinLikes, "min_favs": &f.MinFavs} {
+		if v := c.Query(field); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				errs.Add(field, "must be an integer")
 			}
+			*dst = n
+		}
+	}
+	if v := c.Query("has_images"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			errs.Add("has_images", "must be true or false")
 		}
+		f.HasImages = &b
+	}
+	var err error
+	if f.CreatedFrom, err = parseDateParam(c.Query("from"), false); err != nil {
+		errs.Add("from", err.Error())
 	}
+	if f.CreatedTo, err = parseDateParam(c.Query("to"), true); err != nil {
+		errs.Add("to", err.Error())
+	}
+	return f, errs
+}
 
-	utils.Success(c, gin.H{"list": list, "total": total})
+// parseDateParam 解析 YYYY-MM-DD 或 RFC3339，endOfDay 时日期格式取次日零点作为开区间上界
+func parseDateParam(v string, endOfDay bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New("must be YYYY-MM-DD or RFC3339")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
 }
 
 // CreatePrompt 创建
-// @Summary create prompt
+// @Summary create prompt, optionally with its ordered images in the same transaction
 // @Tags prompts
 // @Accept json
 // @Produce json
-// @Param prompt body model.Prompt true "prompt"
+// @Param prompt body dto.PromptInput true "prompt"
 // @Success 200 {object} model.Prompt
 // @Router /prompts [post]
 func CreatePrompt(c *gin.Context) {
-	var p model.Prompt
-	if err := c.ShouldBindJSON(&p); err != nil {
+	var in dto.PromptInput
+	if err := c.ShouldBindJSON(&in); err != nil {
 		utils.Error(c, 1, err.Error())
 		return
 	}
-	uidRaw, _ := c.Get("user_id")
-	if uidRaw != nil {
-		p.UserID = uidRaw.(uint)
-	}
-	if p.AuthorName == "" {
-		p.AuthorName = "anonymous"
+	in.Normalize()
+	if errs := in.Validate(); len(errs) > 0 {
+		utils.ValidationError(c, errs)
+		return
 	}
+	p := model.Prompt{UserID: currentUserID(c)}
+	in.ApplyTo(&p)
 	if err := service.CreatePrompt(&p); err != nil {
-		utils.Error(c, 1, err.Error())
+		serviceError(c, err)
 		return
 	}
 	utils.Success(c, p)
@@ -103,80 +187,178 @@ func CreatePrompt(c *gin.Context) {
 func GetPrompt(c *gin.Context) {
 	idStr := c.Param("id")
 	id, _ := strconv.ParseUint(idStr, 10, 64)
-	p, err := service.GetPromptByID(uint(id))
+	p, err := service.GetVisiblePrompt(uint(id), currentUserID(c))
 	if err != nil {
 		utils.Error(c, 1, "not found")
 		return
 	}
-	utils.Success(c, p)
+	if err := service.CheckNSFW(p.NSFW, p.UserID, currentUserID(c), nsfwPreference(c)); err != nil {
+		serviceError(c, err)
+		return
+	}
+	list := []model.Prompt{*p}
+	if err := service.FillUserMarks(currentUserID(c), list); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	c.Header("ETag", versionETag(p.Version))
+	utils.Success(c, list[0])
 }
 
 func GetImage(c *gin.Context) {
 	idStr := c.Param("id")
 	id, _ := strconv.ParseUint(idStr, 10, 64)
+	if !promptVisible(c, uint(id)) {
+		return
+	}
 	p, err := service.GetPromptImgByID(uint(id))
 	if err != nil {
 		utils.Error(c, 1, "not found")
 		return
 	}
+	utils.Success(c, service.FilterNSFWImages(nsfwPreference(c), p))
+}
+
+// SetPromptNSFW 修改 nsfw 标记
+// @Summary mark a prompt or some of its images as nsfw, by the author or a moderator
+// @Description Flags set by a moderator on someone else's prompt are locked and can only be cleared by a moderator.
+// @Tags prompts
+// @Accept json
+// @Produce json
+// @Param id path int true "prompt id"
+// @Param data body service.NSFWInput true "nsfw for the whole prompt, images maps image id to flag"
+// @Success 200 {object} model.Prompt
+// @Router /prompts/{id}/nsfw [put]
+func SetPromptNSFW(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in service.NSFWInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	p, err := service.SetPromptNSFW(uint(id), currentUserID(c), in)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	audit(c, model.AuditPromptNSFW, model.TargetPrompt, p.ID, nil, in)
 	utils.Success(c, p)
 }
 
 // UpdatePrompt 更新
-// @Summary update prompt
+// @Summary update prompt; images, when present, replace the image list in the same transaction
 // @Tags prompts
 // @Accept json
 // @Produce json
-// @Param prompt body model.Prompt true "prompt"
+// @Param prompt body dto.PromptInput true "prompt"
+// @Param If-Match header string false "expected current version"
 // @Success 200 {object} model.Prompt
 // @Router /prompts/{id} [put]
 func UpdatePrompt(c *gin.Context) {
 	idStr := c.Param("id")
 	id, _ := strconv.ParseUint(idStr, 10, 64)
-	var in model.Prompt
+	var in dto.PromptInput
 	if err := c.ShouldBindJSON(&in); err != nil {
 		utils.Error(c, 1, err.Error())
 		return
 	}
-	in.ID = uint(id)
-	if err := database.DB.Save(&in).Error; err != nil {
+	savePrompt(c, uint(id), &in)
+}
+
+// PatchPrompt 部分更新
+// @Summary patch prompt (JSON Merge Patch)
+// @Tags prompts
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param patch body map[string]interface{} true "editable fields to change, null clears"
+// @Param If-Match header string false "expected current version"
+// @Success 200 {object} model.Prompt
+// @Router /prompts/{id} [patch]
+func PatchPrompt(c *gin.Context) {
+	idStr := c.Param("id")
+	id, _ := strconv.ParseUint(idStr, 10, 64)
+	raw, err := c.GetRawData()
+	if err != nil {
 		utils.Error(c, 1, err.Error())
 		return
 	}
-	utils.Success(c, in)
+	cur, err := service.GetPromptByID(uint(id))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+
+	in := dto.PromptInputFrom(cur)
+	if errs := in.ApplyMergePatch(raw); len(errs) > 0 {
+		utils.ValidationError(c, errs)
+		return
+	}
+	// 未显式指定版本时，以合并所基于的版本做并发校验，避免覆盖他人修改
+	if in.Version == 0 && c.GetHeader("If-Match") == "" {
+		in.Version = cur.Version
+	}
+	savePrompt(c, uint(id), &in)
+}
+
+// savePrompt 校验输入并写入新版本
+func savePrompt(c *gin.Context, id uint, in *dto.PromptInput) {
+	in.Normalize()
+	if errs := in.Validate(); len(errs) > 0 {
+		utils.ValidationError(c, errs)
+		return
+	}
+	expected, err := expectedVersion(c, in.Version)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	p := model.Prompt{ID: id}
+	in.ApplyTo(&p)
+	updated, err := service.UpdatePrompt(&p, currentUserID(c), expected)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	c.Header("ETag", versionETag(updated.Version))
+	utils.Success(c, updated)
 }
 
 // SavePromptImages 保存提示图片
-// @Summary 保存提示图片
-// @Description 保存提示图片
+// @Summary 按顺序整体替换提示图片，仅作者
+// @Description 文件须为本人上传的图片，file_url 由服务端生成；空数组删除全部图片。也可以在创建或修改 prompt 时通过 images 字段一并提交
 // @Tags prompts
 // @Accept json
 // @Produce json
 // @Param id path int true "提示ID"
+// @Param images body []dto.PromptImageInput true "图片列表"
+// @Success 200 {array} model.PromptImg
+// @Router /prompts/{id}/images [post]
 func SavePromptImages(c *gin.Context) {
-	var in []model.PromptImg
-	if err := c.ShouldBindJSON(&in); err != nil {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	in := dto.PromptInput{Images: []dto.PromptImageInput{}}
+	if err := c.ShouldBindJSON(&in.Images); err != nil {
 		utils.Error(c, 1, err.Error())
 		return
 	}
-
-	// 1. 先根据prompt_id删除已有的数据
-	promptID := in[0].PromptID
-	if err := service.DeletePromptImages(promptID); err != nil {
-		utils.Error(c, 1, err.Error())
+	in.Normalize()
+	errs := utils.FieldErrors{}
+	in.ValidateImages(errs)
+	if len(errs) > 0 {
+		utils.ValidationError(c, errs)
 		return
 	}
-
-	// 2. 保存新的数据
-	if err := database.DB.Save(&in).Error; err != nil {
-		utils.Error(c, 1, err.Error())
+	images, err := service.SavePromptImages(uint(id), currentUserID(c), in.PromptImages())
+	if err != nil {
+		serviceError(c, err)
 		return
 	}
-	utils.Success(c, in)
+	utils.Success(c, images)
 }
 
-// DeletePrompt 删除
-// @Summary delete prompt
+// DeletePrompt 删除，移入回收站
+// @Summary move prompt to the trash, author or moderator only
+// @Description The prompt can be restored from /me/trash until the retention window passes.
 // @Tags prompts
 // @Produce json
 // @Param id path string true "id"
@@ -185,10 +367,14 @@ func SavePromptImages(c *gin.Context) {
 func DeletePrompt(c *gin.Context) {
 	idStr := c.Param("id")
 	id, _ := strconv.ParseUint(idStr, 10, 64)
-	if err := database.DB.Delete(&model.Prompt{}, uint(id)).Error; err != nil {
-		utils.Error(c, 1, err.Error())
+	p, err := service.DeletePrompt(uint(id), currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
 		return
 	}
+	if p.ID != 0 {
+		audit(c, model.AuditPromptDelete, model.TargetPrompt, p.ID, p, nil)
+	}
 	utils.Success(c, gin.H{"deleted": id})
 }
 
@@ -202,11 +388,30 @@ func DeletePrompt(c *gin.Context) {
 func LikePrompt(c *gin.Context) {
 	idStr := c.Param("id")
 	id, _ := strconv.ParseUint(idStr, 10, 64)
-	if err := service.LikePrompt(uint(id)); err != nil {
+	count, err := service.LikePrompt(currentUserID(c), uint(id))
+	if err != nil {
 		utils.Error(c, 1, err.Error())
 		return
 	}
-	utils.Success(c, gin.H{"ok": true})
+	utils.Success(c, gin.H{"liked": true, "like_count": count})
+}
+
+// UnlikePrompt 取消点赞
+// @Summary unlike prompt
+// @Tags prompts
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} map[string]interface{}
+// @Router /prompts/{id}/like [delete]
+func UnlikePrompt(c *gin.Context) {
+	idStr := c.Param("id")
+	id, _ := strconv.ParseUint(idStr, 10, 64)
+	count, err := service.UnlikePrompt(currentUserID(c), uint(id))
+	if err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"liked": false, "like_count": count})
 }
 
 // FavoritePrompt 收藏
@@ -215,13 +420,62 @@ func LikePrompt(c *gin.Context) {
 // @Produce json
 // @Param id path string true "id"
 // @Success 200 {object} map[string]interface{}
-// @Router /prompts/{id}/favorite [post]
+// @Router /prompts/{id}/fav [post]
 func FavoritePrompt(c *gin.Context) {
 	idStr := c.Param("id")
 	id, _ := strconv.ParseUint(idStr, 10, 64)
-	if err := service.FavoritePrompt(uint(id)); err != nil {
+	count, err := service.FavoritePrompt(currentUserID(c), uint(id))
+	if err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"faved": true, "fav_count": count})
+}
+
+// UnfavoritePrompt 取消收藏
+// @Summary unfavorite prompt
+// @Tags prompts
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} map[string]interface{}
+// @Router /prompts/{id}/fav [delete]
+func UnfavoritePrompt(c *gin.Context) {
+	idStr := c.Param("id")
+	id, _ := strconv.ParseUint(idStr, 10, 64)
+	count, err := service.UnfavoritePrompt(currentUserID(c), uint(id))
+	if err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"faved": false, "fav_count": count})
+}
+
+// ListMyFavorites 我的收藏
+// @Summary list my favorite prompts
+// @Tags prompts
+// @Produce json
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param page query int false "page, used only without cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /me/favorites [get]
+func ListMyFavorites(c *gin.Context) {
+	uid := currentUserID(c)
+	nsfw := nsfwPreference(c)
+	list, info, err := service.ListFavoritePrompts(uid, nsfw, pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	if err := service.FillPromptImages(list); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	list = service.ApplyNSFWPreference(nsfw, list)
+	if err := service.FillUserMarks(uid, list); err != nil {
 		utils.Error(c, 1, err.Error())
 		return
 	}
-	utils.Success(c, gin.H{"ok": true})
+	utils.Success(c, pageResponse(list, info))
 }
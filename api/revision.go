@@ -0,0 +1,127 @@
+package api
+
+import (
+	"fmt"
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// versionETag 以版本号作为 prompt 的 ETag
+func versionETag(version int) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// expectedVersion 读取客户端期望的版本号：优先 If-Match 头，其次请求体中的 version
+func expectedVersion(c *gin.Context, bodyVersion int) (int, error) {
+	h := strings.TrimSpace(c.GetHeader("If-Match"))
+	if h == "" || h == "*" {
+		return bodyVersion, nil
+	}
+	h = strings.TrimPrefix(h, "W/")
+	h = strings.Trim(h, "\"")
+	v, err := strconv.Atoi(h)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("%w: If-Match must be a prompt version", service.ErrInvalidParam)
+	}
+	return v, nil
+}
+
+// ListRevisions 版本列表
+// @Summary list prompt revisions
+// @Tags revisions
+// @Produce json
+// @Param id path int true "prompt id"
+// @Success 200 {object} []model.PromptRevision
+// @Router /prompts/{id}/revisions [get]
+func ListRevisions(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if !promptVisible(c, uint(id)) {
+		return
+	}
+	list, err := service.ListRevisions(uint(id))
+	if err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	utils.Success(c, list)
+}
+
+// GetRevision 版本详情
+// @Summary get prompt revision
+// @Tags revisions
+// @Produce json
+// @Param id path int true "prompt id"
+// @Param rev path int true "version"
+// @Success 200 {object} model.PromptRevision
+// @Router /prompts/{id}/revisions/{rev} [get]
+func GetRevision(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if !promptVisible(c, uint(id)) {
+		return
+	}
+	rev, _ := strconv.Atoi(c.Param("rev"))
+	r, err := service.GetRevision(uint(id), rev)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, r)
+}
+
+// DiffRevisions 版本对比
+// @Summary diff two prompt revisions
+// @Tags revisions
+// @Produce json
+// @Param id path int true "prompt id"
+// @Param from query int true "from version"
+// @Param to query int true "to version"
+// @Success 200 {object} service.RevisionDiff
+// @Router /prompts/{id}/diff [get]
+func DiffRevisions(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if !promptVisible(c, uint(id)) {
+		return
+	}
+	from, err1 := strconv.Atoi(c.Query("from"))
+	to, err2 := strconv.Atoi(c.Query("to"))
+	if err1 != nil || err2 != nil {
+		utils.ErrorWithHttpCode(c, 400, 1, "from and to are required")
+		return
+	}
+	diff, err := service.DiffRevisions(uint(id), from, to)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, diff)
+}
+
+// RevertPrompt 回滚到指定版本
+// @Summary revert prompt to a revision
+// @Tags revisions
+// @Produce json
+// @Param id path int true "prompt id"
+// @Param rev path int true "version"
+// @Param If-Match header string false "expected current version"
+// @Success 200 {object} model.Prompt
+// @Router /prompts/{id}/revert/{rev} [post]
+func RevertPrompt(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	rev, _ := strconv.Atoi(c.Param("rev"))
+	expected, err := expectedVersion(c, 0)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	p, err := service.RevertPrompt(uint(id), rev, currentUserID(c), expected)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	c.Header("ETag", versionETag(p.Version))
+	utils.Success(c, p)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
 	"prompt-share-backend/middleware"
+	"prompt-share-backend/model"
 	"prompt-share-backend/service"
 
 	"github.com/gin-gonic/gin"
@@ -14,6 +15,7 @@ import (
 func InitRouter() *gin.Engine {
 	r := gin.Default()
 	r.Use(middleware.Cors())
+	r.Use(middleware.RequestID())
 	r.Use(middleware.Logger())
 
 	// health
@@ -24,17 +26,40 @@ func InitRouter() *gin.Engine {
 
 	// public
 	public := r.Group("/api")
+	public.Use(middleware.OptionalJWTAuth())
 	{
 		public.POST("/auth/register", Register)
 		public.POST("/auth/login", Login)
 		public.GET("/prompts", GetPrompts)
+		public.GET("/models", ListModelSchemas)
+		public.GET("/tags", ListTags)
+		public.GET("/prompts/search", SemanticSearch)
 		public.GET("/prompts/:id", GetPrompt)
 		public.GET("/prompts/:id/images", GetImage)
 		public.GET("/files/:id", DownloadFile)
+		public.GET("/files/:id/similar", SimilarFiles)
 		public.GET("/files/preview/:id", PreviewFile)
 		public.GET("/files/thumbnail/:id", Thumbnail)
 		public.GET("/files", ListFiles)
 		public.GET("/prompts/:id/comments", ListComments)
+		public.GET("/comments/:id/replies", ListReplies)
+		public.GET("/prompts/:id/revisions", ListRevisions)
+		public.GET("/prompts/:id/revisions/:rev", GetRevision)
+		public.GET("/prompts/:id/diff", DiffRevisions)
+		public.POST("/prompts/:id/render", RenderPrompt)
+		public.GET("/prompts/:id/forks", ListForks)
+		public.GET("/prompts/:id/lineage", GetLineage)
+		public.GET("/prompts/:id/similar", SimilarPrompts)
+		public.GET("/collections", ListCollections)
+		public.GET("/collections/:id", GetCollection)
+	}
+
+	// real-time events, token may also be passed as access_token
+	events := r.Group("/api")
+	events.Use(middleware.StreamAuth())
+	{
+		events.GET("/stream", StreamEvents)
+		events.GET("/stream/ws", StreamWebSocket)
 	}
 
 	// protected
@@ -43,14 +68,91 @@ func InitRouter() *gin.Engine {
 	{
 		protected.POST("/prompts", CreatePrompt)
 		protected.PUT("/prompts/:id", UpdatePrompt)
+		protected.PATCH("/prompts/:id", PatchPrompt)
+		protected.POST("/prompts/:id/revert/:rev", RevertPrompt)
+		protected.POST("/prompts/:id/fork", ForkPrompt)
 		protected.POST("/prompts/:id/images", SavePromptImages)
+		protected.PUT("/prompts/:id/nsfw", SetPromptNSFW)
 		protected.DELETE("/prompts/:id", DeletePrompt)
 		protected.POST("/prompts/:id/like", LikePrompt)
+		protected.DELETE("/prompts/:id/like", UnlikePrompt)
 		protected.POST("/prompts/:id/fav", FavoritePrompt)
+		protected.DELETE("/prompts/:id/fav", UnfavoritePrompt)
+		protected.POST("/prompts/:id/report", ReportPrompt)
+		protected.GET("/me/favorites", ListMyFavorites)
+		protected.GET("/me/collections", ListMyCollections)
+		protected.GET("/me/collections/following", ListFollowedCollections)
+		protected.POST("/collections", CreateCollection)
+		protected.PUT("/collections/:id", UpdateCollection)
+		protected.DELETE("/collections/:id", DeleteCollection)
+		protected.POST("/collections/:id/items", AddCollectionItem)
+		protected.PUT("/collections/:id/items/:prompt_id", UpdateCollectionItem)
+		protected.DELETE("/collections/:id/items/:prompt_id", RemoveCollectionItem)
+		protected.PUT("/collections/:id/order", ReorderCollection)
+		protected.POST("/collections/:id/follow", FollowCollection)
+		protected.DELETE("/collections/:id/follow", UnfollowCollection)
+		protected.POST("/collections/:id/clone", CloneCollection)
 		protected.POST("/prompts/:id/comments", CreateComment)
+		protected.PATCH("/comments/:id", UpdateComment)
+		protected.DELETE("/comments/:id", DeleteComment)
+		protected.POST("/comments/:id/reactions/:emoji", AddReaction)
+		protected.DELETE("/comments/:id/reactions/:emoji", RemoveReaction)
+		protected.POST("/comments/:id/report", ReportComment)
+		protected.GET("/me", GetMe)
+		protected.PATCH("/me", UpdateMe)
+		protected.GET("/me/notifications", ListNotifications)
+		protected.POST("/me/notifications/read", MarkAllNotificationsRead)
+		protected.POST("/me/notifications/:id/read", MarkNotificationRead)
+		protected.GET("/me/notification-preferences", GetNotificationPreferences)
+		protected.PUT("/me/notification-preferences", UpdateNotificationPreferences)
+		protected.GET("/me/moderation", ListMyModerationActions)
+		protected.GET("/me/trash", ListTrash)
+		protected.POST("/me/trash/:type/:id/restore", RestoreTrash)
+		protected.DELETE("/me/trash/:type/:id", PurgeTrash)
+		protected.GET("/webhooks", ListMyWebhooks)
+		protected.POST("/webhooks", CreateWebhook)
+		protected.GET("/webhooks/:id", GetWebhook)
+		protected.PUT("/webhooks/:id", UpdateWebhook)
+		protected.DELETE("/webhooks/:id", DeleteWebhook)
+		protected.POST("/webhooks/:id/ping", PingWebhook)
+		protected.GET("/webhooks/:id/deliveries", ListWebhookDeliveries)
+		protected.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", RedeliverWebhook)
 		protected.POST("/files/upload", UploadFile)
 		protected.DELETE("/files/:id", DeleteFile)
 	}
+
+	// moderation
+	moderation := r.Group("/api/moderation")
+	moderation.Use(middleware.JWTAuth(), middleware.RequireRole(model.RoleModerator, model.RoleAdmin))
+	{
+		moderation.GET("/queue", ModerationQueue)
+		moderation.GET("/reports", ListReports)
+		moderation.GET("/actions", ListModerationActions)
+		moderation.POST("/actions", Moderate)
+	}
+
+	// admin
+	admin := r.Group("/api/admin")
+	admin.Use(middleware.JWTAuth(), middleware.RequireRole(model.RoleAdmin))
+	{
+		admin.POST("/search/rebuild", RebuildSearchIndex)
+		admin.GET("/audit", AdminListAudit)
+		admin.PUT("/users/:id/role", AdminSetUserRole)
+		admin.GET("/webhooks", AdminListWebhooks)
+		admin.GET("/content-rules", ListContentRules)
+		admin.POST("/content-rules", CreateContentRule)
+		admin.POST("/content-rules/test", TestContentRules)
+		admin.POST("/content-rules/reload", ReloadContentRules)
+		admin.PUT("/content-rules/:id", UpdateContentRule)
+		admin.DELETE("/content-rules/:id", DeleteContentRule)
+		admin.GET("/tags", AdminListTags)
+		admin.POST("/tags", CreateTag)
+		admin.PUT("/tags/:id", UpdateTag)
+		admin.POST("/tags/:id/merge", MergeTag)
+		admin.POST("/tags/:id/aliases", AddTagAlias)
+		admin.DELETE("/tags/:id/aliases/:alias", RemoveTagAlias)
+	}
+
 	// init storage
 	service.InitStorage()
 	return r
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"prompt-share-backend/model"
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RebuildSearchIndex 重建全文索引
+// @Summary rebuild the full-text search index
+// @Tags prompts
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/search/rebuild [post]
+func RebuildSearchIndex(c *gin.Context) {
+	if err := service.RebuildSearchIndex(); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"rebuilt": true})
+}
+
+// maxSimilar 相似检索最多返回条数
+const maxSimilar = 50
+
+// SimilarPrompts 语义相似的 prompt
+// @Summary list semantically similar prompts
+// @Tags prompts
+// @Produce json
+// @Param id path int true "prompt id"
+// @Param size query int false "number of results, at most 50"
+// @Success 200 {object} map[string]interface{}
+// @Router /prompts/{id}/similar [get]
+func SimilarPrompts(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if !promptVisible(c, uint(id)) {
+		return
+	}
+	list, err := service.SimilarPrompts(uint(id), similarSize(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	respondSemantic(c, list)
+}
+
+// SemanticSearch 语义检索
+// @Summary search prompts by meaning
+// @Tags prompts
+// @Produce json
+// @Param semantic query string true "natural language description"
+// @Param size query int false "number of results, at most 50"
+// @Param min_score query number false "minimum cosine similarity, 0-1"
+// @Success 200 {object} map[string]interface{}
+// @Router /prompts/search [get]
+func SemanticSearch(c *gin.Context) {
+	text := strings.TrimSpace(c.Query("semantic"))
+	if text == "" {
+		utils.ValidationError(c, utils.FieldErrors{"semantic": "is required"})
+		return
+	}
+	minScore, _ := strconv.ParseFloat(c.DefaultQuery("min_score", "0"), 32)
+	list, err := service.SemanticSearch(text, similarSize(c), float32(minScore))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	respondSemantic(c, list)
+}
+
+func similarSize(c *gin.Context) int {
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	if size <= 0 {
+		size = 10
+	}
+	return min(size, maxSimilar)
+}
+
+func respondSemantic(c *gin.Context, list []model.Prompt) {
+	if err := service.FillPromptImages(list); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	list = service.ApplyNSFWPreference(nsfwPreference(c), list)
+	if err := service.FillUserMarks(currentUserID(c), list); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"list": list})
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"prompt-share-backend/config"
+	"prompt-share-backend/service"
+	"prompt-share-backend/stream"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// wsMaxMessage 客户端消息的最大字节数
+const wsMaxMessage = 4096
+
+// 跨域策略与 Cors 中间件一致；认证使用 token 而非 cookie，不依赖来源校验
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// heartbeatInterval 心跳间隔
+func heartbeatInterval() time.Duration {
+	if s := config.Cfg.Stream.HeartbeatSeconds; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return 25 * time.Second
+}
+
+// streamTopics 解析逗号分隔的 topics 参数，未指定时订阅全站 prompt 动态
+func streamTopics(c *gin.Context) []string {
+	raw, ok := c.GetQuery("topics")
+	if !ok {
+		return []string{service.TopicPrompts}
+	}
+	var topics []string
+	for _, t := range strings.Split(raw, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
+// subscribe 按请求参数订阅；Last-Event-ID 头优先，其次为 last_event_id 查询参数
+func subscribe(c *gin.Context) (*stream.Subscription, []stream.Event, bool, bool) {
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	sub, missed, resumed, err := service.SubscribeEvents(currentUserID(c), streamTopics(c), lastID)
+	if err != nil {
+		serviceError(c, err)
+		return nil, nil, false, false
+	}
+	return sub, missed, resumed, true
+}
+
+// resetEvent 无法续传时发送给客户端的事件
+func resetEvent() stream.Event {
+	return stream.Event{Type: service.EventStreamReset, Time: time.Now()}
+}
+
+// StreamEvents 实时事件流（SSE）
+// @Summary subscribe to real-time events via Server-Sent Events
+// @Description Topics: prompts (all prompt activity), prompt:<id> (one prompt, incl. comments), user:<id> (own notifications and files, subscribed automatically when logged in).
+// @Description EventSource cannot send headers, so the token may be passed as access_token. Reconnects resume from Last-Event-ID; a stream.reset event means events were missed and data should be refetched.
+// @Tags stream
+// @Produce text/event-stream
+// @Param topics query string false "comma separated topics, default prompts"
+// @Param access_token query string false "JWT, alternative to the Authorization header"
+// @Param last_event_id query string false "resume after this event id, alternative to the Last-Event-ID header"
+// @Success 200 {string} string "event stream"
+// @Router /stream [get]
+func StreamEvents(c *gin.Context) {
+	sub, missed, resumed, ok := subscribe(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	write := func(e stream.Event) error {
+		return sse.Encode(c.Writer, sse.Event{Id: e.ID, Event: e.Type, Data: e})
+	}
+	if _, err := io.WriteString(c.Writer, "retry: 3000\n\n"); err != nil {
+		return
+	}
+	if !resumed {
+		if err := write(resetEvent()); err != nil {
+			return
+		}
+	}
+	for _, e := range missed {
+		if err := write(e); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(heartbeatInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, open := <-sub.Events():
+			if !open {
+				// 积压过多被断开，客户端会带 Last-Event-ID 重连补发
+				return
+			}
+			if err := write(e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := io.WriteString(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// wsCommand 客户端通过 WebSocket 发送的订阅指令
+type wsCommand struct {
+	Action string   `json:"action"` // subscribe | unsubscribe
+	Topics []string `json:"topics"`
+}
+
+// StreamWebSocket 实时事件流（WebSocket）
+// @Summary subscribe to real-time events via WebSocket
+// @Description Same topics, events and resume rules as GET /stream. Each server message is one JSON event.
+// @Description Send {"action":"subscribe"|"unsubscribe","topics":[...]} to change topics; the reply is a "subscribed" message listing the current topics, or an "error" message.
+// @Tags stream
+// @Param topics query string false "comma separated topics, default prompts"
+// @Param access_token query string false "JWT, alternative to the Authorization header"
+// @Param last_event_id query string false "resume after this event id"
+// @Success 101 {string} string "switching protocols"
+// @Router /stream/ws [get]
+func StreamWebSocket(c *gin.Context) {
+	sub, missed, resumed, ok := subscribe(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade 已向客户端写入错误响应
+		return
+	}
+	defer conn.Close()
+
+	uid := currentUserID(c)
+	// 连续两次心跳都没有收到 pong 视为连接已断开
+	pongWait := 2*heartbeatInterval() + 10*time.Second
+	replies := make(chan stream.Event, 8)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(wsMaxMessage)
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var cmd wsCommand
+			if json.Unmarshal(msg, &cmd) != nil {
+				cmd.Action = ""
+			}
+			reply := stream.Event{Type: "subscribed", Time: time.Now()}
+			switch err := service.CheckStreamTopics(uid, cmd.Topics); {
+			case cmd.Action != "subscribe" && cmd.Action != "unsubscribe":
+				reply = stream.Event{Type: "error", Data: gin.H{"message": `action must be "subscribe" or "unsubscribe"`}, Time: time.Now()}
+			case err != nil:
+				reply = stream.Event{Type: "error", Data: gin.H{"message": err.Error()}, Time: time.Now()}
+			case cmd.Action == "subscribe":
+				sub.Add(cmd.Topics...)
+			default:
+				sub.Remove(cmd.Topics...)
+			}
+			if reply.Type == "subscribed" {
+				reply.Topics = sub.Topics()
+			}
+			select {
+			case replies <- reply:
+			default:
+			}
+		}
+	}()
+
+	// 所有写操作都在当前 goroutine 中进行
+	write := func(e stream.Event) error {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return conn.WriteJSON(e)
+	}
+	if !resumed {
+		if err := write(resetEvent()); err != nil {
+			return
+		}
+	}
+	for _, e := range missed {
+		if err := write(e); err != nil {
+			return
+		}
+	}
+	ticker := time.NewTicker(heartbeatInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case e, open := <-sub.Events():
+			if !open {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow, reconnect with last_event_id"),
+					time.Now().Add(time.Second))
+				return
+			}
+			if err := write(e); err != nil {
+				return
+			}
+		case r := <-replies:
+			if err := write(r); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTags 标签列表
+// @Summary list tags with usage counts, or autocomplete by prefix
+// @Tags tags
+// @Produce json
+// @Param q query string false "prefix for autocomplete"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param page query int false "page, used only without cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /tags [get]
+func ListTags(c *gin.Context) {
+	list, info, err := service.ListTags(c.Query("q"), false, pageRequest(c, 20))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// AddTagAlias 添加别名
+// @Summary add an alias (synonym) to a tag
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "tag id"
+// @Param data body map[string]interface{} true "alias"
+// @Success 200 {object} model.TagAlias
+// @Router /admin/tags/{id}/aliases [post]
+func AddTagAlias(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in struct {
+		Alias string `json:"alias" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	alias, err := service.AddTagAlias(uint(id), in.Alias)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, alias)
+}
+
+// RemoveTagAlias 删除别名
+// @Summary remove a tag alias
+// @Tags tags
+// @Produce json
+// @Param id path int true "tag id"
+// @Param alias path string true "alias"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/tags/{id}/aliases/{alias} [delete]
+func RemoveTagAlias(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err := service.RemoveTagAlias(uint(id), c.Param("alias")); err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"ok": true})
+}
+
+// AdminListTags 管理端标签列表，包含禁用标签
+// @Summary list all tags including banned ones
+// @Tags tags
+// @Produce json
+// @Param q query string false "prefix"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param page query int false "page, used only without cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/tags [get]
+func AdminListTags(c *gin.Context) {
+	list, info, err := service.ListTags(c.Query("q"), true, pageRequest(c, 20))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// CreateTag 创建标签
+// @Summary create a tag (e.g. to ban it ahead of use)
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param data body map[string]interface{} true "name, parent_id, banned"
+// @Success 200 {object} model.Tag
+// @Router /admin/tags [post]
+func CreateTag(c *gin.Context) {
+	var in struct {
+		Name     string `json:"name" binding:"required"`
+		ParentID uint   `json:"parent_id"`
+		Banned   bool   `json:"banned"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	tag, err := service.CreateTag(in.Name, in.ParentID, in.Banned)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, tag)
+}
+
+// UpdateTag 修改标签
+// @Summary rename, reparent or ban a tag
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "tag id"
+// @Param data body service.TagUpdate true "fields to update"
+// @Success 200 {object} model.Tag
+// @Router /admin/tags/{id} [put]
+func UpdateTag(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in service.TagUpdate
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	tag, err := service.UpdateTag(uint(id), in)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, tag)
+}
+
+// MergeTag 合并标签
+// @Summary merge a tag into another one
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "source tag id"
+// @Param data body map[string]interface{} true "into: target tag id"
+// @Success 200 {object} model.Tag
+// @Router /admin/tags/{id}/merge [post]
+func MergeTag(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in struct {
+		Into uint `json:"into" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	tag, err := service.MergeTag(uint(id), in.Into)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, tag)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RenderPrompt 渲染模板
+// @Summary render prompt template with variable values
+// @Tags prompts
+// @Accept json
+// @Produce json
+// @Param id path int true "prompt id"
+// @Param data body map[string]interface{} true "values: variable name -> value"
+// @Success 200 {object} map[string]interface{}
+// @Router /prompts/{id}/render [post]
+func RenderPrompt(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if !promptVisible(c, uint(id)) {
+		return
+	}
+	var in struct {
+		Values map[string]interface{} `json:"values"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	text, values, err := service.RenderPrompt(uint(id), in.Values)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, gin.H{"text": text, "values": values})
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"prompt-share-backend/model"
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTrash 回收站
+// @Summary list my deleted prompts, comments and files that can still be restored, newest first
+// @Description Content removed by moderators is not listed. purge_at is when the item is deleted permanently.
+// @Tags trash
+// @Produce json
+// @Param type query string false "prompt, comment or file"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /me/trash [get]
+func ListTrash(c *gin.Context) {
+	list, info, err := service.ListTrash(currentUserID(c), c.Query("type"), pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// RestoreTrash 恢复
+// @Summary restore a deleted prompt, comment or file within the retention window
+// @Description Moderators may restore any content. A comment can only be restored while its prompt exists.
+// @Tags trash
+// @Produce json
+// @Param type path string true "prompt, comment or file"
+// @Param id path int true "content id"
+// @Success 200 {object} service.TrashItem
+// @Router /me/trash/{type}/{id}/restore [post]
+func RestoreTrash(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	item, err := service.RestoreTrash(c.Param("type"), uint(id), currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	audit(c, model.AuditTrashRestore, item.Type, item.ID, nil, item)
+	utils.Success(c, item)
+}
+
+// PurgeTrash 永久删除
+// @Summary permanently delete an item in the trash without waiting for the retention window
+// @Tags trash
+// @Produce json
+// @Param type path string true "prompt, comment or file"
+// @Param id path int true "content id"
+// @Success 200 {object} map[string]interface{}
+// @Router /me/trash/{type}/{id} [delete]
+func PurgeTrash(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	item, err := service.PurgeTrashItem(c.Param("type"), uint(id), currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	audit(c, model.AuditTrashPurge, item.Type, item.ID, item, nil)
+	utils.Success(c, gin.H{"purged": item.ID})
+}
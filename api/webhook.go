@@ -0,0 +1,184 @@
+package api
+
+import (
+	"prompt-share-backend/model"
+	"prompt-share-backend/service"
+	"prompt-share-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListMyWebhooks 我的 webhook
+// @Summary list my webhooks
+// @Tags webhook
+// @Produce json
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /webhooks [get]
+func ListMyWebhooks(c *gin.Context) {
+	list, info, err := service.ListWebhooks(currentUserID(c), pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// AdminListWebhooks 全部 webhook
+// @Summary list all webhooks (admin)
+// @Tags webhook
+// @Produce json
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/webhooks [get]
+func AdminListWebhooks(c *gin.Context) {
+	list, info, err := service.ListWebhooks(0, pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// CreateWebhook 注册 webhook
+// @Summary register a webhook; payloads are signed with X-Webhook-Signature: sha256=hex(HMAC(secret, timestamp + "." + body))
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param data body service.WebhookInput true "url required; events empty means all; global is admin only"
+// @Success 200 {object} model.Webhook
+// @Router /webhooks [post]
+func CreateWebhook(c *gin.Context) {
+	var in service.WebhookInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	w, err := service.CreateWebhook(currentUserID(c), in)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, w)
+}
+
+// GetWebhook webhook 详情
+// @Summary get a webhook
+// @Tags webhook
+// @Produce json
+// @Param id path int true "webhook id"
+// @Success 200 {object} model.Webhook
+// @Router /webhooks/{id} [get]
+func GetWebhook(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	w, err := service.GetWebhook(uint(id), currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, w)
+}
+
+// UpdateWebhook 修改 webhook
+// @Summary update a webhook; set active to true to re-enable an automatically disabled one
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param id path int true "webhook id"
+// @Param data body service.WebhookInput true "omitted fields are unchanged"
+// @Success 200 {object} model.Webhook
+// @Router /webhooks/{id} [put]
+func UpdateWebhook(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var in service.WebhookInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		utils.Error(c, 1, err.Error())
+		return
+	}
+	w, err := service.UpdateWebhook(uint(id), currentUserID(c), in)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, w)
+}
+
+// DeleteWebhook 删除 webhook
+// @Summary delete a webhook and its delivery log
+// @Tags webhook
+// @Produce json
+// @Param id path int true "webhook id"
+// @Success 200 {object} map[string]interface{}
+// @Router /webhooks/{id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	w, err := service.DeleteWebhook(uint(id), currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	audit(c, model.AuditWebhookDelete, model.TargetWebhook, w.ID, w, nil)
+	utils.Success(c, gin.H{"deleted": id})
+}
+
+// ListWebhookDeliveries 投递记录
+// @Summary list deliveries of a webhook, newest first
+// @Tags webhook
+// @Produce json
+// @Param id path int true "webhook id"
+// @Param status query string false "pending, success or failed"
+// @Param cursor query string false "opaque cursor from next_cursor / prev_cursor"
+// @Param size query int false "page size, at most 100"
+// @Param with_total query bool false "set false to skip counting total"
+// @Success 200 {object} map[string]interface{}
+// @Router /webhooks/{id}/deliveries [get]
+func ListWebhookDeliveries(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	list, info, err := service.ListWebhookDeliveries(uint(id), currentUserID(c), c.Query("status"), pageRequest(c, service.DefaultPageSize))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, pageResponse(list, info))
+}
+
+// RedeliverWebhook 重新投递
+// @Summary redeliver a past delivery with the same payload
+// @Tags webhook
+// @Produce json
+// @Param id path int true "webhook id"
+// @Param delivery_id path int true "delivery id"
+// @Success 200 {object} model.WebhookDelivery
+// @Router /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func RedeliverWebhook(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	did, _ := strconv.ParseUint(c.Param("delivery_id"), 10, 64)
+	d, err := service.RedeliverWebhook(uint(id), uint(did), currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, d)
+}
+
+// PingWebhook 测试投递
+// @Summary send a ping event to the webhook
+// @Tags webhook
+// @Produce json
+// @Param id path int true "webhook id"
+// @Success 200 {object} model.WebhookDelivery
+// @Router /webhooks/{id}/ping [post]
+func PingWebhook(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	d, err := service.PingWebhook(uint(id), currentUserID(c))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	utils.Success(c, d)
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"prompt-share-backend/api"
 	"prompt-share-backend/config"
 	"prompt-share-backend/database"
+	"prompt-share-backend/service"
 )
 
 // @title Prompt Share API
@@ -13,12 +15,71 @@ import (
 // @host localhost:8080
 // @BasePath /api
 func main() {
+	reindex := flag.Bool("reindex", false, "rebuild the full-text search index and exit")
+	flag.Parse()
+
 	// load config
 	config.Load()
 
 	// init db
 	database.Init()
 
+	// backfill normalized tags from legacy comma separated strings
+	if err := service.MigrateTags(); err != nil {
+		log.Fatal("migrate tags failed:", err)
+	}
+
+	if err := service.MigrateComments(); err != nil {
+		log.Fatal("migrate comments failed:", err)
+	}
+
+	// recommended parameter schemas of model families
+	if err := service.InitModelSchemas(); err != nil {
+		log.Fatal("init model schemas failed:", err)
+	}
+	if err := service.MigrateModelParams(); err != nil {
+		log.Fatal("migrate model params failed:", err)
+	}
+
+	// full-text search index
+	if err := service.InitSearchIndex(); err != nil {
+		log.Fatal("init search index failed:", err)
+	}
+	if *reindex {
+		if err := service.RebuildSearchIndex(); err != nil {
+			log.Fatal("rebuild search index failed:", err)
+		}
+		log.Println("search index rebuilt")
+		return
+	}
+
+	// perceptual hashes of images, backfilled in background
+	if err := service.InitImageHashes(); err != nil {
+		log.Fatal("init image hashes failed:", err)
+	}
+
+	// semantic embeddings, computed in background
+	if err := service.InitEmbedding(); err != nil {
+		log.Fatal("init embedding failed:", err)
+	}
+
+	// in-process hub for real-time events
+	service.InitStream()
+
+	// outgoing webhooks, pending deliveries resume after restart
+	service.InitWebhooks()
+
+	// content filter rules, reloaded periodically and on every admin change
+	service.InitContentFilter()
+
+	// append-only audit log, expired entries purged daily
+	if err := service.InitAudit(); err != nil {
+		log.Fatal("init audit log failed:", err)
+	}
+
+	// trash: deleted prompts, comments and files are purged after the retention window
+	service.InitTrash()
+
 	// init router and services
 	r := api.InitRouter()
 
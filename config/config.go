@@ -28,11 +28,54 @@ type StorageConfig struct {
 	Local LocalStorageConfig `mapstructure:"local"`
 }
 
+// SearchConfig 全文检索配置
+type SearchConfig struct {
+	AllowLikeFallback bool `mapstructure:"allow_like_fallback"` // SQLite 未编译 FTS5 时允许退化为 LIKE 查询，默认拒绝启动
+}
+
+// EmbeddingConfig 语义检索向量配置，provider 为 hash（默认，本地）或 http（本地模型服务）
+type EmbeddingConfig struct {
+	Provider       string `mapstructure:"provider"`
+	Dim            int    `mapstructure:"dim"`
+	URL            string `mapstructure:"url"`
+	Model          string `mapstructure:"model"`
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"`
+}
+
+// StreamConfig 实时事件推送配置
+type StreamConfig struct {
+	HeartbeatSeconds int `mapstructure:"heartbeat_seconds"` // 心跳间隔，默认 25 秒
+	BufferSize       int `mapstructure:"buffer_size"`       // 可断线续传的最近事件数，默认 1024
+}
+
+// NSFWConfig 成人内容的默认展示方式，取值 show、blur、hide
+type NSFWConfig struct {
+	AnonymousDefault string  `mapstructure:"anonymous_default"` // 未登录访客，默认 hide
+	UserDefault      string  `mapstructure:"user_default"`      // 未设置偏好的登录用户，默认 blur
+	BlurSigma        float64 `mapstructure:"blur_sigma"`        // 缩略图模糊强度，默认 20
+}
+
+// AuditConfig 审计日志配置
+type AuditConfig struct {
+	RetentionDays int `mapstructure:"retention_days"` // 保留天数，默认 365，负数表示永久保留
+}
+
+// TrashConfig 回收站配置
+type TrashConfig struct {
+	RetentionDays int `mapstructure:"retention_days"` // 删除后可恢复的天数，默认 30，过期后永久删除
+}
+
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Storage  StorageConfig  `mapstructure:"storage"`
+	Server    ServerConfig    `mapstructure:"server"`
+	JWT       JWTConfig       `mapstructure:"jwt"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	Embedding EmbeddingConfig `mapstructure:"embedding"`
+	Search    SearchConfig    `mapstructure:"search"`
+	Stream    StreamConfig    `mapstructure:"stream"`
+	NSFW      NSFWConfig      `mapstructure:"nsfw"`
+	Audit     AuditConfig     `mapstructure:"audit"`
+	Trash     TrashConfig     `mapstructure:"trash"`
 }
 
 var Cfg *Config
@@ -1,11 +1,13 @@
 package database
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"prompt-share-backend/config"
 	"prompt-share-backend/model"
+	"strings"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -21,20 +23,58 @@ func Init() {
 		log.Fatal("create db dir failed:", err)
 	}
 
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	db, err := Open(dbPath)
 	if err != nil {
-		log.Fatal("failed to connect database:", err)
+		log.Fatal("failed to open database:", err)
 	}
 	DB = db
+}
+
+// sqliteParams 连接参数：WAL 允许读写并发；事务以 BEGIN IMMEDIATE 开始并在锁被占用时最多等待 5 秒，
+// 避免后台任务（向量、webhook、审计、回收站清理）与请求事务并发写入时直接返回 "database is locked"
+const sqliteParams = "_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+
+// Open 打开 sqlite 数据库并迁移表结构
+func Open(path string) (*gorm.DB, error) {
+	dsn := path + "?" + sqliteParams
+	if strings.Contains(path, "?") {
+		dsn = path + "&" + sqliteParams
+	}
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
 
 	// Auto migrate
-	if err := DB.AutoMigrate(
+	if err := db.AutoMigrate(
 		&model.User{},
 		&model.Prompt{},
 		&model.Comment{},
+		&model.CommentMention{},
+		&model.CommentReaction{},
+		&model.Notification{},
+		&model.NotificationActor{},
+		&model.Webhook{},
+		&model.WebhookDelivery{},
+		&model.Report{},
+		&model.ModerationAction{},
+		&model.ContentRule{},
+		&model.AuditLog{},
 		&model.File{},
 		&model.PromptImg{},
+		&model.PromptRevision{},
+		&model.Tag{},
+		&model.TagAlias{},
+		&model.PromptTag{},
+		&model.PromptImgTag{},
+		&model.PromptLike{},
+		&model.PromptFavorite{},
+		&model.Collection{},
+		&model.CollectionItem{},
+		&model.CollectionFollow{},
+		&model.PromptEmbedding{},
 	); err != nil {
-		log.Fatal("AutoMigrate failed:", err)
+		return nil, fmt.Errorf("auto migrate: %w", err)
 	}
+	return db, nil
 }
@@ -0,0 +1,305 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"strings"
+	"unicode/utf8"
+)
+
+// 字段长度与数量限制
+const (
+	MaxTitleLen      = 255
+	MaxContentLen    = 20000
+	MaxAuthorNameLen = 100
+	MaxSourceByLen   = 100
+	MaxSourceURLLen  = 255
+	MaxTagsLen       = 255
+	MaxSourceTagsLen = 100
+	MaxTagCount      = 20
+	MaxTagLen        = 32
+	MaxNegativeLen   = 5000
+	MaxImageCount    = 20
+)
+
+// PromptInput prompt 的可编辑字段，创建、整体修改和 PATCH 共用
+// 计数、作者、时间等字段由服务端维护，不接受客户端输入
+type PromptInput struct {
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Tags       string `json:"tags"` // comma separated
+	AuthorName string `json:"author_name"`
+	SourceBy   string `json:"source_by"`
+	SourceURL  string `json:"source_url"`
+	SourceTags string `json:"source_tags"` // comma separated
+	Version    int    `json:"version"`     // 期望的当前版本号，仅用于并发控制
+
+	Variables []model.PromptVariable `json:"variables"` // 模板变量声明
+
+	NegativePrompt string                 `json:"negative_prompt"`
+	ModelFamily    string                 `json:"model_family"`
+	Params         map[string]interface{} `json:"params"` // 推荐参数，按 model_family 的 schema 校验
+
+	NSFW bool `json:"nsfw"` // 作者标记为成人内容
+
+	Images []PromptImageInput `json:"images"` // 图片列表，与 prompt 在同一事务中整体替换；修改时省略表示不变
+}
+
+// PromptImageInput prompt 的一张图片，按数组顺序展示
+// 图片地址由服务端根据文件生成，版主锁定等字段由服务端维护
+type PromptImageInput struct {
+	FileID uint   `json:"file_id"`
+	Tags   string `json:"tags"` // comma separated
+	NSFW   bool   `json:"nsfw"`
+}
+
+// PromptInputFrom 以已有 prompt 为基础构造输入，用于 PATCH
+func PromptInputFrom(p *model.Prompt) PromptInput {
+	return PromptInput{
+		Title:      p.Title,
+		Content:    p.Content,
+		Tags:       p.Tags,
+		AuthorName: p.AuthorName,
+		SourceBy:   p.SourceBy,
+		SourceURL:  p.SourceURL,
+		SourceTags: p.SourceTags,
+		Variables:  p.Variables,
+
+		NegativePrompt: p.NegativePrompt,
+		ModelFamily:    p.ModelFamily,
+		Params:         p.Params,
+
+		NSFW: p.NSFW,
+	}
+}
+
+// stringFields 可编辑的字符串字段，key 为 json 字段名
+func (in *PromptInput) stringFields() map[string]*string {
+	return map[string]*string{
+		"title":       &in.Title,
+		"content":     &in.Content,
+		"tags":        &in.Tags,
+		"author_name": &in.AuthorName,
+		"source_by":   &in.SourceBy,
+		"source_url":  &in.SourceURL,
+		"source_tags": &in.SourceTags,
+
+		"negative_prompt": &in.NegativePrompt,
+		"model_family":    &in.ModelFamily,
+	}
+}
+
+// ApplyMergePatch 按 RFC 7386 JSON Merge Patch 合并到当前输入
+// null 表示清空字段；不可编辑的字段返回字段错误
+func (in *PromptInput) ApplyMergePatch(raw []byte) utils.FieldErrors {
+	errs := utils.FieldErrors{}
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &patch); err != nil || patch == nil {
+		errs.Add("body", "must be a JSON object")
+		return errs
+	}
+
+	fields := in.stringFields()
+	for key, value := range patch {
+		isNull := string(value) == "null"
+		switch key {
+		case "version":
+			if isNull {
+				in.Version = 0
+			} else if err := json.Unmarshal(value, &in.Version); err != nil {
+				errs.Add(key, "must be an integer")
+			}
+			continue
+		case "variables":
+			// 数组整体替换
+			in.Variables = nil
+			if !isNull {
+				if err := json.Unmarshal(value, &in.Variables); err != nil {
+					errs.Add(key, "must be an array of variable declarations")
+				}
+			}
+			continue
+		case "images":
+			// 数组整体替换，null 清空全部图片
+			in.Images = []PromptImageInput{}
+			if !isNull {
+				if err := json.Unmarshal(value, &in.Images); err != nil {
+					errs.Add(key, "must be an array of images")
+				}
+			}
+			continue
+		case "nsfw":
+			in.NSFW = false
+			if !isNull {
+				if err := json.Unmarshal(value, &in.NSFW); err != nil {
+					errs.Add(key, "must be true or false")
+				}
+			}
+			continue
+		case "params":
+			// 对象按 RFC 7386 递归合并，null 删除对应参数
+			if isNull {
+				in.Params = nil
+				continue
+			}
+			var patchParams map[string]json.RawMessage
+			if err := json.Unmarshal(value, &patchParams); err != nil {
+				errs.Add(key, "must be an object")
+				continue
+			}
+			if in.Params == nil {
+				in.Params = map[string]interface{}{}
+			}
+			for name, v := range patchParams {
+				if string(v) == "null" {
+					delete(in.Params, name)
+					continue
+				}
+				var decoded interface{}
+				if err := json.Unmarshal(v, &decoded); err != nil {
+					errs.Add("params."+name, "is not valid JSON")
+					continue
+				}
+				in.Params[name] = decoded
+			}
+			continue
+		}
+		dst, ok := fields[key]
+		if !ok {
+			errs.Add(key, "field is not editable")
+			continue
+		}
+		if isNull {
+			*dst = ""
+			continue
+		}
+		if err := json.Unmarshal(value, dst); err != nil {
+			errs.Add(key, "must be a string")
+		}
+	}
+	return errs
+}
+
+// Normalize 去除首尾空白，规范化标签，补全默认作者名
+func (in *PromptInput) Normalize() {
+	in.Title = strings.TrimSpace(in.Title)
+	in.Content = strings.TrimSpace(in.Content)
+	in.AuthorName = strings.TrimSpace(in.AuthorName)
+	in.SourceBy = strings.TrimSpace(in.SourceBy)
+	in.SourceURL = strings.TrimSpace(in.SourceURL)
+	in.NegativePrompt = strings.TrimSpace(in.NegativePrompt)
+	in.ModelFamily = strings.ToLower(strings.TrimSpace(in.ModelFamily))
+	in.Tags = normalizeTags(in.Tags)
+	in.SourceTags = normalizeTags(in.SourceTags)
+	for i := range in.Images {
+		in.Images[i].Tags = normalizeTags(in.Images[i].Tags)
+	}
+	if in.AuthorName == "" {
+		in.AuthorName = "anonymous"
+	}
+}
+
+// Validate 校验字段，返回字段级错误
+func (in *PromptInput) Validate() utils.FieldErrors {
+	errs := utils.FieldErrors{}
+	if in.Title == "" {
+		errs.Add("title", "is required")
+	}
+	if in.Content == "" {
+		errs.Add("content", "is required")
+	}
+	utils.CheckLen(errs, "title", in.Title, MaxTitleLen)
+	utils.CheckLen(errs, "content", in.Content, MaxContentLen)
+	utils.CheckLen(errs, "author_name", in.AuthorName, MaxAuthorNameLen)
+	utils.CheckLen(errs, "source_by", in.SourceBy, MaxSourceByLen)
+	utils.CheckLen(errs, "source_url", in.SourceURL, MaxSourceURLLen)
+	utils.CheckLen(errs, "negative_prompt", in.NegativePrompt, MaxNegativeLen)
+	checkTags(errs, "tags", in.Tags, MaxTagsLen)
+	checkTags(errs, "source_tags", in.SourceTags, MaxSourceTagsLen)
+	if in.SourceURL != "" && !utils.IsHTTPURL(in.SourceURL) {
+		errs.Add("source_url", "must be an http or https URL")
+	}
+	if in.Version < 0 {
+		errs.Add("version", "must not be negative")
+	}
+	in.ValidateImages(errs)
+	return errs
+}
+
+// ValidateImages 校验图片数量、文件 id 与图片标签，文件是否存在由服务端在保存时校验
+func (in *PromptInput) ValidateImages(errs utils.FieldErrors) {
+	if len(in.Images) > MaxImageCount {
+		errs.Add("images", fmt.Sprintf("must have at most %d images", MaxImageCount))
+	}
+	seen := make(map[uint]bool, len(in.Images))
+	for i, img := range in.Images {
+		field := fmt.Sprintf("images[%d]", i)
+		switch {
+		case img.FileID == 0:
+			errs.Add(field+".file_id", "is required")
+		case seen[img.FileID]:
+			errs.Add(field+".file_id", "is duplicated")
+		}
+		seen[img.FileID] = true
+		checkTags(errs, field+".tags", img.Tags, MaxTagsLen)
+	}
+}
+
+// ApplyTo 将输入写入 prompt 的可编辑字段
+func (in *PromptInput) ApplyTo(p *model.Prompt) {
+	p.Title = in.Title
+	p.Content = in.Content
+	p.Tags = in.Tags
+	p.AuthorName = in.AuthorName
+	p.SourceBy = in.SourceBy
+	p.SourceURL = in.SourceURL
+	p.SourceTags = in.SourceTags
+	p.Variables = in.Variables
+	p.NegativePrompt = in.NegativePrompt
+	p.ModelFamily = in.ModelFamily
+	p.Params = in.Params
+	p.NSFW = in.NSFW
+	p.Images = in.PromptImages()
+}
+
+// PromptImages 按顺序转为图片记录，Images 为 nil 时返回 nil 表示不修改图片
+func (in *PromptInput) PromptImages() []model.PromptImg {
+	if in.Images == nil {
+		return nil
+	}
+	images := make([]model.PromptImg, len(in.Images))
+	for i, img := range in.Images {
+		images[i] = model.PromptImg{FileId: img.FileID, Tags: img.Tags, NSFW: img.NSFW}
+	}
+	return images
+}
+
+func checkTags(errs utils.FieldErrors, field, value string, max int) {
+	if value == "" {
+		return
+	}
+	tags := strings.Split(value, ",")
+	if len(tags) > MaxTagCount {
+		errs.Add(field, fmt.Sprintf("must have at most %d tags", MaxTagCount))
+	}
+	for _, t := range tags {
+		if utf8.RuneCountInString(t) > MaxTagLen {
+			errs.Add(field, fmt.Sprintf("each tag must be at most %d characters", MaxTagLen))
+		}
+	}
+	utils.CheckLen(errs, field, value, max)
+}
+
+// normalizeTags 去掉标签首尾空白与空标签
+func normalizeTags(s string) string {
+	parts := strings.Split(s, ",")
+	tags := parts[:0]
+	for _, t := range parts {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return strings.Join(tags, ",")
+}
@@ -0,0 +1,59 @@
+package embedding
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Embedder 将文本转换为向量
+type Embedder interface {
+	// Name 标识模型与维度，模型变化时已存储的向量需重新计算
+	Name() string
+	Embed(texts []string) ([][]float32, error)
+}
+
+// Normalize 原地归一化为单位向量，归一化后余弦相似度即点积
+func Normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	n := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= n
+	}
+	return v
+}
+
+// Dot 点积，长度不同返回 0
+func Dot(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var s float32
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+// Encode 按 little-endian float32 序列化
+func Encode(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(x))
+	}
+	return b
+}
+
+// Decode Encode 的逆过程
+func Decode(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
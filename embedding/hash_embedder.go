@@ -0,0 +1,86 @@
+package embedding
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+
+	"prompt-share-backend/utils"
+)
+
+// HashEmbedder 本地轻量实现：词、字符三元组与 CJK 单字/双字特征哈希到固定维度
+// 不依赖外部模型，能捕捉拼写变体与共享片段，但不理解同义词
+type HashEmbedder struct {
+	dim int
+}
+
+func NewHashEmbedder(dim int) *HashEmbedder {
+	if dim <= 0 {
+		dim = 256
+	}
+	return &HashEmbedder{dim: dim}
+}
+
+func (e *HashEmbedder) Name() string {
+	return fmt.Sprintf("hash-ngram-%d", e.dim)
+}
+
+func (e *HashEmbedder) Embed(texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		v := make([]float32, e.dim)
+		for feature, weight := range features(t) {
+			h := fnv.New64a()
+			h.Write([]byte(feature))
+			sum := h.Sum64()
+			// 最高位决定符号，减少哈希冲突带来的偏差
+			if sum>>63 == 1 {
+				weight = -weight
+			}
+			v[sum%uint64(e.dim)] += weight
+		}
+		out[i] = Normalize(v)
+	}
+	return out, nil
+}
+
+// features 抽取加权特征
+func features(text string) map[string]float32 {
+	f := map[string]float32{}
+	text = strings.ToLower(strings.Map(utils.FoldWidth, text))
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case utils.IsCJK(r):
+			j := i
+			for j < len(runes) && utils.IsCJK(runes[j]) {
+				j++
+			}
+			run := runes[i:j]
+			for k := range run {
+				f["c:"+string(run[k])] += 0.5
+				if k+1 < len(run) {
+					f["b:"+string(run[k:k+2])] += 1
+				}
+			}
+			i = j
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && !utils.IsCJK(runes[j]) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			word := string(runes[i:j])
+			f["w:"+word] += 1
+			padded := []rune("^" + word + "$")
+			for k := 0; k+3 <= len(padded); k++ {
+				f["g:"+string(padded[k:k+3])] += 0.5
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return f
+}
@@ -0,0 +1,61 @@
+package embedding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTPEmbedder 调用本地模型服务的 OpenAI 兼容接口 POST {url}，请求 {"model","input"}，
+// 响应 {"data":[{"index","embedding"}]}；llama.cpp、Ollama、vLLM 等均支持
+type HTTPEmbedder struct {
+	url    string
+	model  string
+	client *http.Client
+}
+
+func NewHTTPEmbedder(url, model string, timeout time.Duration) *HTTPEmbedder {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &HTTPEmbedder{url: url, model: model, client: &http.Client{Timeout: timeout}}
+}
+
+func (e *HTTPEmbedder) Name() string {
+	return "http:" + e.model
+}
+
+func (e *HTTPEmbedder) Embed(texts []string) ([][]float32, error) {
+	body, _ := json.Marshal(map[string]interface{}{"model": e.model, "input": texts})
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding server returned %s", resp.Status)
+	}
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if len(out.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding server returned %d vectors for %d inputs", len(out.Data), len(texts))
+	}
+	vecs := make([][]float32, len(texts))
+	for i, d := range out.Data {
+		idx := d.Index
+		if idx < 0 || idx >= len(vecs) {
+			idx = i
+		}
+		vecs[idx] = Normalize(d.Embedding)
+	}
+	return vecs, nil
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"prompt-share-backend/config"
 	"strings"
@@ -19,39 +20,79 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
-		// 必须是 Bearer token
-		parts := strings.SplitN(auth, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
-			return
-		}
-		tokenStr := parts[1]
-
-		// 解析 token
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.Cfg.JWT.Secret), nil
-		}, jwt.WithValidMethods([]string{"HS256"}))
-		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		uid, err := parseUserID(auth)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
 		}
 
-		// 取出用户 ID
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
-			return
+		// 设置到 gin 上下文，后续 handler 可以拿 user_id
+		c.Set("user_id", uid)
+
+		c.Next()
+	}
+}
+
+// OptionalJWTAuth 可选认证中间件：携带合法 token 时设置 user_id，否则按匿名用户继续
+func OptionalJWTAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if auth := c.GetHeader("Authorization"); auth != "" {
+			if uid, err := parseUserID(auth); err == nil {
+				c.Set("user_id", uid)
+			}
 		}
+		c.Next()
+	}
+}
 
-		uidFloat, ok := claims["uid"].(float64)
-		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid uid in token"})
-			return
+// StreamAuth 事件流的可选认证：EventSource 与浏览器 WebSocket 无法设置请求头，
+// 因此除 Authorization 头外也接受 access_token 查询参数
+func StreamAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := c.GetHeader("Authorization")
+		if auth == "" {
+			if token := c.Query("access_token"); token != "" {
+				auth = "Bearer " + token
+			}
+		}
+		if auth != "" {
+			uid, err := parseUserID(auth)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			c.Set("user_id", uid)
 		}
+		c.Next()
+	}
+}
 
-		// 设置到 gin 上下文，后续 handler 可以拿 user_id
-		c.Set("user_id", uint(uidFloat))
+// parseUserID 解析 Authorization 头并取出用户 ID
+func parseUserID(auth string) (uint, error) {
+	// 必须是 Bearer token
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return 0, errors.New("invalid authorization header format")
+	}
+	tokenStr := parts[1]
 
-		c.Next()
+	// 解析 token
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.Cfg.JWT.Secret), nil
+	}, jwt.WithValidMethods([]string{"HS256"}))
+	if err != nil || !token.Valid {
+		return 0, errors.New("invalid or expired token")
+	}
+
+	// 取出用户 ID
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, errors.New("invalid token claims")
+	}
+
+	uidFloat, ok := claims["uid"].(float64)
+	if !ok {
+		return 0, errors.New("invalid uid in token")
 	}
+	return uint(uidFloat), nil
 }
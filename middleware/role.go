@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole 角色校验中间件，需在 JWTAuth 之后使用
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uidRaw, _ := c.Get("user_id")
+		uid, _ := uidRaw.(uint)
+		if uid == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "login required"})
+			return
+		}
+
+		var u model.User
+		if err := database.DB.Select("id", "role").First(&u, uid).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+			return
+		}
+		for _, r := range roles {
+			if u.Role == r {
+				c.Set("role", u.Role)
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+	}
+}
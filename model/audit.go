@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// 审计动作
+const (
+	AuditRegister    = "auth.register"
+	AuditLogin       = "auth.login"
+	AuditLoginFailed = "auth.login_failed"
+	AuditRoleChange  = "user.role"
+
+	AuditPromptDelete     = "prompt.delete"
+	AuditPromptNSFW       = "prompt.nsfw"
+	AuditCommentDelete    = "comment.delete"
+	AuditFileDelete       = "file.delete"
+	AuditCollectionDelete = "collection.delete"
+	AuditWebhookDelete    = "webhook.delete"
+
+	AuditTrashRestore = "trash.restore" // 从回收站恢复，对象类型为 prompt、comment 或 file
+	AuditTrashPurge   = "trash.purge"   // 在保留期结束前永久删除
+
+	AuditModeration = "moderation" // 实际动作为 moderation.hide、moderation.delete 等
+
+	AuditContentRuleCreate = "content_rule.create"
+	AuditContentRuleUpdate = "content_rule.update"
+	AuditContentRuleDelete = "content_rule.delete"
+)
+
+// 审计对象类型，prompt 与 comment 见 TargetPrompt、TargetComment
+const (
+	TargetUser        = "user"
+	TargetFile        = "file"
+	TargetCollection  = "collection"
+	TargetWebhook     = "webhook"
+	TargetContentRule = "content_rule"
+)
+
+// AuditLog 安全与管理操作的审计记录，只追加，除按保留期清理外不修改、不删除
+type AuditLog struct {
+	ID         uint                   `gorm:"primaryKey" json:"id"`
+	ActorID    uint                   `gorm:"index" json:"actor_id"` // 0 表示未登录
+	Action     string                 `gorm:"size:50;index" json:"action"`
+	TargetType string                 `gorm:"size:30;index:idx_audit_target" json:"target_type"`
+	TargetID   uint                   `gorm:"index:idx_audit_target" json:"target_id"`
+	Before     map[string]interface{} `gorm:"type:text;serializer:json" json:"before"` // 变化前的字段，创建时为空
+	After      map[string]interface{} `gorm:"type:text;serializer:json" json:"after"`  // 变化后的字段，删除时为空
+	IP         string                 `gorm:"size:64" json:"ip"`
+	UserAgent  string                 `gorm:"size:255" json:"user_agent"`
+	RequestID  string                 `gorm:"size:64;index" json:"request_id"`
+	CreatedAt  time.Time              `gorm:"index" json:"created_at"`
+
+	Actor *UserBrief `gorm:"-" json:"actor,omitempty"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// 收藏夹可见性
+const (
+	VisibilityPrivate  = "private"  // 仅自己可见
+	VisibilityUnlisted = "unlisted" // 持有分享链接可见
+	VisibilityPublic   = "public"   // 所有人可见
+)
+
+// Collection 用户创建的收藏夹（画板），用于按项目组织 prompt
+type Collection struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uint      `gorm:"index;not null" json:"user_id"`
+	Name          string    `gorm:"size:100;not null" json:"name"`
+	Description   string    `gorm:"size:500" json:"description"`
+	Visibility    string    `gorm:"size:20;default:'private'" json:"visibility"`
+	ShareToken    string    `gorm:"size:64;index" json:"share_token,omitempty"` // unlisted 访问凭证，仅所有者可见
+	CoverImgID    uint      `json:"cover_img_id"`                               // 封面，取自成员 prompt 的 PromptImg
+	CoverUrl      string    `gorm:"size:255" json:"cover_url"`
+	ItemCount     int64     `gorm:"default:0" json:"item_count"`
+	FollowerCount int64     `gorm:"default:0" json:"follower_count"`
+	ClonedFromID  uint      `json:"cloned_from_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	FollowedByMe bool             `gorm:"-" json:"followed_by_me"`
+	Items        []CollectionItem `gorm:"-" json:"items,omitempty"`
+}
+
+// CollectionItem 收藏夹成员，按 Position 升序排列
+type CollectionItem struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CollectionID uint      `gorm:"uniqueIndex:idx_collection_item;not null" json:"collection_id"`
+	PromptID     uint      `gorm:"uniqueIndex:idx_collection_item;not null" json:"prompt_id"`
+	Position     int       `gorm:"default:0" json:"position"`
+	Note         string    `gorm:"size:500" json:"note"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	Prompt *Prompt `gorm:"-" json:"prompt,omitempty"`
+}
+
+// CollectionFollow 用户关注的公开收藏夹
+type CollectionFollow struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"uniqueIndex:idx_collection_follow;not null" json:"user_id"`
+	CollectionID uint      `gorm:"uniqueIndex:idx_collection_follow;index;not null" json:"collection_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
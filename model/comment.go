@@ -2,10 +2,52 @@ package model
 
 import "time"
 
+// MaxCommentDepth 回复嵌套的最大深度，顶层评论深度为 0
+const MaxCommentDepth = 5
+
 type Comment struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index" json:"user_id"`
+	PromptID    uint       `gorm:"index" json:"prompt_id"`
+	ParentID    uint       `gorm:"default:0;index" json:"parent_id"` // 被回复的评论，0 表示顶层
+	RootID      uint       `gorm:"default:0;index" json:"root_id"`   // 所在楼的顶层评论，顶层评论为自身
+	Depth       int        `gorm:"default:0" json:"depth"`
+	Content     string     `gorm:"type:text" json:"content"`                // Markdown 原文
+	ContentHTML string     `gorm:"type:text" json:"content_html"`           // 渲染并净化后的 HTML
+	ReplyCount  int64      `gorm:"default:0" json:"reply_count"`            // 直接回复数，含已删除的占位
+	EditedAt    *time.Time `json:"edited_at"`                               // 非空表示内容被作者修改过
+	DeletedAt   *time.Time `gorm:"index" json:"deleted_at"`                 // 软删除，保留占位维持楼层结构，保留期内可恢复
+	DeletedBy   uint       `json:"-"`                                       // 删除评论的用户，作者本人或版主
+	Status      string     `gorm:"size:20;default:'visible'" json:"status"` // 审核状态，非 visible 时对他人显示为占位
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Author    *UserBrief      `gorm:"-" json:"author"`
+	Mentions  []UserBrief     `gorm:"-" json:"mentions"`
+	Reactions []ReactionCount `gorm:"-" json:"reactions"`
+	Replies   []Comment       `gorm:"-" json:"replies,omitempty"`
+}
+
+// CommentMention 评论中 @ 提及并解析到的用户，(comment_id, user_id) 唯一
+type CommentMention struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CommentID uint      `gorm:"uniqueIndex:idx_comment_mention;not null" json:"comment_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_comment_mention;index;not null" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CommentReaction 评论的表情回应，(user_id, comment_id, emoji) 唯一
+type CommentReaction struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `json:"user_id"`
-	PromptID  uint      `json:"prompt_id"`
-	Content   string    `gorm:"type:text" json:"content"`
+	UserID    uint      `gorm:"uniqueIndex:idx_comment_reaction;not null" json:"user_id"`
+	CommentID uint      `gorm:"uniqueIndex:idx_comment_reaction;index;not null" json:"comment_id"`
+	Emoji     string    `gorm:"uniqueIndex:idx_comment_reaction;size:32;not null" json:"emoji"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// ReactionCount 某个表情的回应数，Reacted 表示当前用户是否回应过
+type ReactionCount struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// 内容规则的匹配方式
+const (
+	RuleKeyword = "keyword" // 任一关键词出现即命中
+	RuleRegex   = "regex"   // 任一正则匹配即命中
+)
+
+// 规则命中后的处理
+const (
+	RuleReject = "reject" // 拒绝保存
+	RuleReview = "review" // 保存为待审并进入审核队列
+	RuleNSFW   = "nsfw"   // 自动添加 nsfw 标签；评论没有标签，按 review 处理
+)
+
+// ContentRule 管理员配置的内容过滤规则，作用于 prompt 文本和评论
+// 关键词与正则都匹配归一化后的文本（全角转半角、小写、形近字母替换），见 utils.NormalizeText
+type ContentRule struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Name        string     `gorm:"size:100;not null" json:"name"`
+	Description string     `gorm:"size:500" json:"description"`
+	Kind        string     `gorm:"size:20;not null" json:"kind"`              // keyword 或 regex
+	Patterns    []string   `gorm:"type:text;serializer:json" json:"patterns"` // 关键词列表或正则列表
+	Scopes      []string   `gorm:"type:text;serializer:json" json:"scopes"`   // prompt、comment，空表示全部
+	Action      string     `gorm:"size:20;not null" json:"action"`            // reject、review 或 nsfw
+	Reason      string     `gorm:"size:20;default:'other'" json:"reason"`     // 进入审核队列时的举报原因
+	Enabled     bool       `gorm:"default:true" json:"enabled"`
+	HitCount    int64      `gorm:"default:0" json:"hit_count"`
+	LastHitAt   *time.Time `json:"last_hit_at"`
+	CreatedBy   uint       `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type File struct {
 	ID         uint      `gorm:"primaryKey" json:"id"`
@@ -11,4 +15,19 @@ type File struct {
 	Type       string    `gorm:"size:100" json:"type"`
 	CreatedAt  time.Time `json:"created_at"`
 	Thumbnail  string    `gorm:"blob" json:"thumbnail"`
+	Blurred    string    `gorm:"blob" json:"-"`              // 模糊后的缩略图，nsfw 图片首次按 blur 偏好输出时生成
+	PHash      string    `gorm:"size:16;index" json:"phash"` // 图片感知哈希，16 位十六进制，非图片为空
+
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"` // 移入回收站的时间，存储中的文件在清理时才删除
+	DeletedBy uint           `json:"-"`              // 移入回收站的用户
+
+	NearDuplicates []FileMatch `gorm:"-" json:"near_duplicates,omitempty"` // 上传时发现的近似重复图片
+}
+
+// FileMatch 感知哈希相近的图片
+type FileMatch struct {
+	FileID     uint   `json:"file_id"`
+	Name       string `json:"name"`
+	UploaderID uint   `json:"uploader_id"`
+	Distance   int    `json:"distance"` // 汉明距离，0 表示几乎相同
 }
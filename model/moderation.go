@@ -0,0 +1,84 @@
+package model
+
+import "time"
+
+// 内容状态，prompt 与评论共用；非 visible 的内容只有作者本人和版主可见
+const (
+	StatusVisible = "visible"
+	StatusHidden  = "hidden"  // 版主隐藏
+	StatusPending = "pending" // 被多人举报，等待审核
+)
+
+// 举报对象类型
+const (
+	TargetPrompt  = "prompt"
+	TargetComment = "comment"
+)
+
+// 举报原因
+const (
+	ReasonSpam      = "spam"
+	ReasonAbuse     = "abuse"     // 辱骂、骚扰
+	ReasonNSFW      = "nsfw"      // 色情低俗
+	ReasonIllegal   = "illegal"   // 违法内容
+	ReasonCopyright = "copyright" // 侵权
+	ReasonOther     = "other"
+)
+
+// ReportReasons 全部举报原因
+var ReportReasons = []string{ReasonSpam, ReasonAbuse, ReasonNSFW, ReasonIllegal, ReasonCopyright, ReasonOther}
+
+// 举报处理状态
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"  // 已采取处理措施
+	ReportDismissed = "dismissed" // 驳回，内容无问题
+)
+
+// 版主操作
+const (
+	ModerationHide    = "hide"
+	ModerationRestore = "restore"
+	ModerationDelete  = "delete"
+	ModerationWarn    = "warn"    // 警告作者，内容不变
+	ModerationDismiss = "dismiss" // 驳回举报，待审内容恢复可见
+)
+
+// ModerationActions 全部版主操作
+var ModerationActions = []string{ModerationHide, ModerationRestore, ModerationDelete, ModerationWarn, ModerationDismiss}
+
+// Report 用户对 prompt 或评论的举报，同一用户对同一对象只保留一条
+type Report struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	TargetType string     `gorm:"size:20;uniqueIndex:idx_report_target;not null" json:"target_type"`
+	TargetID   uint       `gorm:"uniqueIndex:idx_report_target;not null" json:"target_id"`
+	ReporterID uint       `gorm:"uniqueIndex:idx_report_target;not null" json:"reporter_id"`
+	Reason     string     `gorm:"size:20;not null" json:"reason"`
+	Detail     string     `gorm:"size:1000" json:"detail"`
+	Status     string     `gorm:"size:20;default:'open';index" json:"status"`
+	ResolvedBy uint       `json:"resolved_by"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	Resolution string     `gorm:"size:20" json:"resolution"` // 处理时采取的版主操作
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	Reporter *UserBrief `gorm:"-" json:"reporter,omitempty"`
+	Prompt   *Prompt    `gorm:"-" json:"prompt,omitempty"`  // 举报对象，已被删除时为空
+	Comment  *Comment   `gorm:"-" json:"comment,omitempty"` // 举报对象，已被删除时为空
+}
+
+// ModerationAction 版主操作记录
+type ModerationAction struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ModeratorID  uint      `gorm:"index;not null" json:"moderator_id"`
+	TargetType   string    `gorm:"size:20;index:idx_moderation_target;not null" json:"target_type"`
+	TargetID     uint      `gorm:"index:idx_moderation_target;not null" json:"target_id"`
+	TargetUserID uint      `gorm:"index" json:"target_user_id"` // 内容作者
+	Action       string    `gorm:"size:20;not null" json:"action"`
+	Reason       string    `gorm:"size:20" json:"reason"`
+	Note         string    `gorm:"size:1000" json:"note"`
+	Reports      int64     `json:"reports"` // 本次处理关闭的举报数
+	CreatedAt    time.Time `json:"created_at"`
+
+	Moderator *UserBrief `gorm:"-" json:"moderator,omitempty"`
+}
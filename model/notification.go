@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// 通知类型
+const (
+	NotifyLike     = "like"     // prompt 被点赞
+	NotifyFavorite = "favorite" // prompt 被收藏
+	NotifyComment  = "comment"  // prompt 收到评论
+	NotifyReply    = "reply"    // 评论收到回复
+	NotifyMention  = "mention"  // 在评论中被 @
+	NotifyFork     = "fork"     // prompt 被 fork
+)
+
+// NotificationTypes 全部通知类型，偏好设置按此校验
+var NotificationTypes = []string{NotifyLike, NotifyFavorite, NotifyComment, NotifyReply, NotifyMention, NotifyFork}
+
+// Notification 站内通知，同一接收者、类型和 prompt 的未读通知合并为一条
+type Notification struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index:idx_notification_group;not null" json:"user_id"` // 接收者
+	Type       string     `gorm:"size:16;index:idx_notification_group;not null" json:"type"`
+	PromptID   uint       `gorm:"index:idx_notification_group" json:"prompt_id"`
+	CommentID  uint       `json:"comment_id"`                   // 最近一次触发的评论，评论、回复、提及类通知有效
+	ActorID    uint       `json:"actor_id"`                     // 最近一次触发的用户
+	ActorCount int64      `gorm:"default:1" json:"actor_count"` // 合并的不同用户数
+	ReadAt     *time.Time `gorm:"index" json:"read_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"index" json:"updated_at"` // 最近一次合并的时间
+
+	Actors      []UserBrief `gorm:"-" json:"actors"` // 最近的几位触发者
+	PromptTitle string      `gorm:"-" json:"prompt_title"`
+}
+
+// NotificationActor 合并进通知的触发者，(notification_id, actor_id) 唯一
+type NotificationActor struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	NotificationID uint      `gorm:"uniqueIndex:idx_notification_actor;not null" json:"notification_id"`
+	ActorID        uint      `gorm:"uniqueIndex:idx_notification_actor;not null" json:"actor_id"`
+	CommentID      uint      `json:"comment_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -1,21 +1,54 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Prompt struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	Title      string    `gorm:"size:255;not null" json:"title"`
-	Content    string    `gorm:"type:text;not null" json:"content"`
-	Tags       string    `gorm:"size:255" json:"tags"` // comma separated
-	UserID     uint      `json:"user_id"`
-	AuthorName string    `gorm:"size:100" json:"author_name"`
-	LikeCount  int64     `gorm:"default:0" json:"like_count"`
-	FavCount   int64     `gorm:"default:0" json:"fav_count"`
-	SourceBy   string    `gorm:"size:100" json:"source_by"`
-	SourceURL  string    `gorm:"size:255" json:"source_url"`
-	SourceTags string    `gorm:"size:100" json:"source_tags"` // comma separated
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-
-	Images []PromptImg `gorm:"-" json:"images"` // 忽略该字段
+	ID           uint             `gorm:"primaryKey" json:"id"`
+	Title        string           `gorm:"size:255;not null" json:"title"`
+	Content      string           `gorm:"type:text;not null" json:"content"`
+	Tags         string           `gorm:"size:255" json:"tags"` // comma separated
+	UserID       uint             `json:"user_id"`
+	AuthorName   string           `gorm:"size:100" json:"author_name"`
+	LikeCount    int64            `gorm:"default:0" json:"like_count"`
+	FavCount     int64            `gorm:"default:0" json:"fav_count"`
+	ForkCount    int64            `gorm:"default:0" json:"fork_count"`
+	CommentCount int64            `gorm:"default:0" json:"comment_count"`
+	SourceBy     string           `gorm:"size:100" json:"source_by"`
+	SourceURL    string           `gorm:"size:255" json:"source_url"`
+	SourceTags   string           `gorm:"size:100" json:"source_tags"`                // comma separated
+	Version      int              `gorm:"default:1;not null" json:"version"`          // 当前版本号，每次修改递增
+	Variables    []PromptVariable `gorm:"type:text;serializer:json" json:"variables"` // 模板变量声明
+
+	NegativePrompt string                 `gorm:"type:text" json:"negative_prompt"`
+	ModelFamily    string                 `gorm:"size:50;index" json:"model_family"`       // 目标模型家族，如 sdxl、midjourney
+	Params         map[string]interface{} `gorm:"type:text;serializer:json" json:"params"` // 推荐参数，按模型家族 schema 校验
+
+	ForkedFromID uint `gorm:"index" json:"forked_from_id"` // 派生来源 prompt，0 表示原创
+
+	Status string `gorm:"size:20;default:'visible';index" json:"status"` // visible、hidden、pending，见 StatusVisible
+
+	NSFW       bool `gorm:"default:false;index" json:"nsfw"`  // 成人内容，按浏览者偏好展示、模糊或隐藏
+	NSFWLocked bool `gorm:"default:false" json:"nsfw_locked"` // 由版主标记，作者不能取消
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"` // 移入回收站的时间，保留期内可恢复，见 service.PurgeTrash
+	DeletedBy uint           `json:"-"`              // 移入回收站的用户，作者本人或版主
+
+	Images    []PromptImg `gorm:"-" json:"images"`      // 忽略该字段
+	LikedByMe bool        `gorm:"-" json:"liked_by_me"` // 当前用户是否已点赞
+	FavedByMe bool        `gorm:"-" json:"faved_by_me"` // 当前用户是否已收藏
+
+	Highlight *SearchHighlight `gorm:"-" json:"highlight,omitempty"` // 全文检索命中高亮，仅搜索时返回
+	Score     *float32         `gorm:"-" json:"score,omitempty"`     // 语义相似度，仅相似检索时返回
+}
+
+// SearchHighlight 搜索命中高亮，命中词以 <mark> 包裹，其余文本已做 HTML 转义
+type SearchHighlight struct {
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"`
 }
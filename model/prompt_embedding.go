@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// PromptEmbedding prompt 的语义向量，Model 为生成向量的 embedder，TextHash 用于判断内容是否变化
+type PromptEmbedding struct {
+	PromptID  uint      `gorm:"primaryKey" json:"prompt_id"`
+	Model     string    `gorm:"size:100;not null" json:"model"`
+	TextHash  string    `gorm:"size:64;not null" json:"text_hash"`
+	Vector    []byte    `gorm:"not null" json:"-"` // little-endian float32，已归一化
+	UpdatedAt time.Time `json:"updated_at"`
+}
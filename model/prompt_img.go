@@ -6,4 +6,7 @@ type PromptImg struct {
 	FileId   uint   `json:"file_id"`
 	Tags     string `gorm:"size:255" json:"tags"` // comma separated
 	FileUrl  string `gorm:"size:255" json:"file_url"`
+
+	NSFW       bool `gorm:"default:false" json:"nsfw"`        // 单张图片为成人内容，prompt 标记为 nsfw 时全部图片都按 nsfw 处理
+	NSFWLocked bool `gorm:"default:false" json:"nsfw_locked"` // 由版主标记，作者不能取消
 }
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// PromptLike 用户点赞记录，(user_id, prompt_id) 唯一
+type PromptLike struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_prompt_like_user_prompt;not null" json:"user_id"`
+	PromptID  uint      `gorm:"uniqueIndex:idx_prompt_like_user_prompt;index;not null" json:"prompt_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// PromptRevision prompt 的不可变历史版本，每次修改生成一条
+type PromptRevision struct {
+	ID        uint             `gorm:"primaryKey" json:"id"`
+	PromptID  uint             `gorm:"uniqueIndex:idx_prompt_revision;not null" json:"prompt_id"`
+	Version   int              `gorm:"uniqueIndex:idx_prompt_revision;not null" json:"version"`
+	Title     string           `gorm:"size:255" json:"title"`
+	Content   string           `gorm:"type:text" json:"content"`
+	Tags      string           `gorm:"size:255" json:"tags"` // comma separated
+	Variables []PromptVariable `gorm:"type:text;serializer:json" json:"variables"`
+
+	NegativePrompt string                 `gorm:"type:text" json:"negative_prompt"`
+	ModelFamily    string                 `gorm:"size:50" json:"model_family"`
+	Params         map[string]interface{} `gorm:"type:text;serializer:json" json:"params"`
+
+	ImagesJSON string    `gorm:"column:images;type:text" json:"-"`
+	EditorID   uint      `json:"editor_id"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	Images []PromptImg `gorm:"-" json:"images"` // 由 ImagesJSON 解析
+}
@@ -0,0 +1,19 @@
+package model
+
+// 模板变量类型
+const (
+	VarTypeString  = "string"
+	VarTypeNumber  = "number"
+	VarTypeInteger = "integer"
+	VarTypeBoolean = "boolean"
+	VarTypeEnum    = "enum"
+)
+
+// PromptVariable prompt 模板中声明的变量，content 中以 {{name}} 引用
+type PromptVariable struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default,omitempty"`
+	Options     []string    `json:"options,omitempty"` // 仅 enum 类型使用
+	Description string      `json:"description,omitempty"`
+}
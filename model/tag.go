@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// prompt 与标签关联的类型
+const (
+	TagKindPrompt = "tag"    // Prompt.Tags
+	TagKindSource = "source" // Prompt.SourceTags
+)
+
+// Tag 规范化标签，Slug 为大小写折叠后的唯一标识
+type Tag struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Slug       string    `gorm:"size:64;uniqueIndex;not null" json:"slug"`
+	Name       string    `gorm:"size:64;not null" json:"name"` // 展示名，取首次出现时的写法
+	UsageCount int64     `gorm:"default:0;index" json:"usage_count"`
+	ParentID   uint      `gorm:"index" json:"parent_id"`      // 上级标签，过滤上级时包含全部下级
+	Banned     bool      `gorm:"default:false" json:"banned"` // 禁用标签不能用于新建或修改
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	Aliases []string `gorm:"-" json:"aliases,omitempty"`
+}
+
+// TagAlias 标签别名/同义词，解析时映射到规范标签
+type TagAlias struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Alias     string    `gorm:"size:64;uniqueIndex;not null" json:"alias"` // 同样为 slug 形式
+	TagID     uint      `gorm:"index;not null" json:"tag_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PromptTag prompt 与标签的关联
+type PromptTag struct {
+	PromptID uint   `gorm:"primaryKey;autoIncrement:false" json:"prompt_id"`
+	TagID    uint   `gorm:"primaryKey;autoIncrement:false;index" json:"tag_id"`
+	Kind     string `gorm:"primaryKey;size:16" json:"kind"`
+	Position int    `json:"position"`
+}
+
+// PromptImgTag prompt 图片与标签的关联
+type PromptImgTag struct {
+	PromptImgID uint `gorm:"primaryKey;autoIncrement:false" json:"prompt_img_id"`
+	TagID       uint `gorm:"primaryKey;autoIncrement:false;index" json:"tag_id"`
+	Position    int  `json:"position"`
+}
@@ -3,11 +3,38 @@ package model
 import "time"
 
 type User struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	Username     string    `gorm:"size:100;uniqueIndex;not null" json:"username"`
-	Email        string    `gorm:"size:200;uniqueIndex" json:"email"`
-	PasswordHash string    `gorm:"size:255;not null" json:"-"`
-	Role         string    `gorm:"size:50;default:'user'" json:"role"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	Username           string    `gorm:"size:100;uniqueIndex;not null" json:"username"`
+	Email              string    `gorm:"size:200;uniqueIndex" json:"email"`
+	PasswordHash       string    `gorm:"size:255;not null" json:"-"`
+	Role               string    `gorm:"size:50;default:'user'" json:"role"`
+	Avatar             string    `gorm:"size:512" json:"avatar"`         // 头像图片地址
+	MutedNotifications string    `gorm:"size:255" json:"-"`              // 关闭的通知类型，逗号分隔
+	NSFWPreference     string    `gorm:"size:10" json:"nsfw_preference"` // show、blur、hide，空表示使用站点默认值
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
+
+// UserBrief 嵌入在其他资源中的用户公开信息
+type UserBrief struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar"`
+}
+
+// 用户角色
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// nsfw 内容展示偏好
+const (
+	NSFWShow = "show" // 正常展示
+	NSFWBlur = "blur" // 列表照常返回，图片输出模糊的缩略图
+	NSFWHide = "hide" // 不出现在列表中，详情与文件不可访问
+)
+
+// NSFWPreferences 可选的 nsfw 偏好
+var NSFWPreferences = []string{NSFWShow, NSFWBlur, NSFWHide}
@@ -0,0 +1,67 @@
+package model
+
+import "time"
+
+// Webhook 事件类型，Webhook.Events 为空表示订阅全部
+const (
+	WebhookPromptCreated  = "prompt.created"
+	WebhookPromptUpdated  = "prompt.updated"
+	WebhookPromptDeleted  = "prompt.deleted"
+	WebhookCommentCreated = "comment.created"
+	WebhookCommentUpdated = "comment.updated"
+	WebhookCommentDeleted = "comment.deleted"
+	WebhookFileUploaded   = "file.uploaded"
+	WebhookFileDeleted    = "file.deleted"
+	WebhookPing           = "ping" // 手动测试，总是发送
+)
+
+// WebhookEvents 可订阅的事件类型
+var WebhookEvents = []string{
+	WebhookPromptCreated, WebhookPromptUpdated, WebhookPromptDeleted,
+	WebhookCommentCreated, WebhookCommentUpdated, WebhookCommentDeleted,
+	WebhookFileUploaded, WebhookFileDeleted,
+}
+
+// Webhook 外发事件的订阅地址
+// 普通用户的 webhook 只接收与自己相关的事件（自己的 prompt、文件及其上的评论），Global 的接收全站事件，仅管理员可设置
+type Webhook struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"index;not null" json:"user_id"`
+	URL            string     `gorm:"size:1024;not null" json:"url"`
+	Secret         string     `gorm:"size:128;not null" json:"secret"` // HMAC-SHA256 签名密钥，仅所有者和管理员可见
+	Events         string     `gorm:"size:512" json:"events"`          // 订阅的事件类型，逗号分隔，空表示全部
+	Description    string     `gorm:"size:255" json:"description"`
+	Global         bool       `gorm:"default:false" json:"global"`
+	Active         bool       `gorm:"default:true;index" json:"active"`
+	FailureCount   int        `gorm:"default:0" json:"failure_count"` // 连续投递失败次数，成功后清零
+	DisabledAt     *time.Time `json:"disabled_at"`                    // 连续失败过多被自动停用的时间
+	DisabledReason string     `gorm:"size:255" json:"disabled_reason"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// 投递状态
+const (
+	DeliveryPending = "pending" // 等待首次投递或重试
+	DeliverySuccess = "success"
+	DeliveryFailed  = "failed" // 重试耗尽或 webhook 已停用
+)
+
+// WebhookDelivery 一次事件投递及其最后一次尝试的结果
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	WebhookID      uint       `gorm:"index;not null" json:"webhook_id"`
+	Event          string     `gorm:"size:32;not null" json:"event"`
+	Payload        string     `gorm:"type:text" json:"payload"`
+	Status         string     `gorm:"size:16;index;not null" json:"status"`
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus int        `json:"response_status"`
+	ResponseBody   string     `gorm:"type:text" json:"response_body"` // 截断保存
+	Error          string     `gorm:"size:512" json:"error"`
+	DurationMs     int64      `json:"duration_ms"`
+	RedeliveryOf   uint       `gorm:"default:0" json:"redelivery_of"` // 手动重新投递时为原投递 id
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
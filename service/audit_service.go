@@ -0,0 +1,179 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"prompt-share-backend/config"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// defaultAuditRetentionDays 未配置时审计日志的保留天数
+const defaultAuditRetentionDays = 365
+
+// auditPurgeInterval 清理过期审计日志的间隔
+const auditPurgeInterval = 24 * time.Hour
+
+// auditOmit 不写入审计记录的字段：密钥等敏感信息，以及缩略图等大字段
+var auditOmit = map[string]bool{"secret": true, "password": true, "token": true, "thumbnail": true}
+
+// AuditMeta 发起操作的请求信息
+type AuditMeta struct {
+	ActorID   uint
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// InitAudit 禁止修改已写入的审计记录，并定期清理超过保留期的记录
+func InitAudit() error {
+	if err := database.DB.Exec(`CREATE TRIGGER IF NOT EXISTS audit_logs_append_only
+		BEFORE UPDATE ON audit_logs BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END`).Error; err != nil {
+		return err
+	}
+	go func() {
+		for ; ; time.Sleep(auditPurgeInterval) {
+			if n, err := PurgeAuditLogs(); err != nil {
+				log.Println("purge audit logs failed:", err)
+			} else if n > 0 {
+				log.Printf("purged %d audit logs", n)
+			}
+		}
+	}()
+	return nil
+}
+
+// PurgeAuditLogs 删除超过保留期的审计记录，返回删除条数
+func PurgeAuditLogs() (int64, error) {
+	days := config.Cfg.Audit.RetentionDays
+	if days < 0 {
+		return 0, nil
+	}
+	if days == 0 {
+		days = defaultAuditRetentionDays
+	}
+	res := database.DB.Where("julianday(created_at) < julianday(?)", time.Now().AddDate(0, 0, -days)).Delete(&model.AuditLog{})
+	return res.RowsAffected, res.Error
+}
+
+// Audit 追加一条审计记录，失败只记录日志，不影响已完成的操作
+// before、after 为对象变化前后的状态，nil 表示不存在（创建或删除）；两者都有时只保留变化的字段
+func Audit(meta AuditMeta, action, targetType string, targetID uint, before, after interface{}) {
+	entry := model.AuditLog{
+		ActorID:    meta.ActorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         meta.IP,
+		UserAgent:  truncate(meta.UserAgent, 255),
+		RequestID:  meta.RequestID,
+	}
+	entry.Before, entry.After = auditDiff(auditSnapshot(before), auditSnapshot(after))
+	if err := database.DB.Create(&entry).Error; err != nil {
+		log.Printf("write audit log %s %s#%d failed: %v", action, targetType, targetID, err)
+	}
+}
+
+// auditSnapshot 将对象按 JSON 字段转为字段表，去掉空值与 auditOmit 中的字段
+func auditSnapshot(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return nil
+	}
+	for k, value := range fields {
+		if value == nil || auditOmit[k] {
+			delete(fields, k)
+		}
+	}
+	return fields
+}
+
+// auditDiff 前后都存在时只保留发生变化的字段，updated_at 不计入
+func auditDiff(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if before == nil || after == nil {
+		return before, after
+	}
+	b, a := map[string]interface{}{}, map[string]interface{}{}
+	for k, v := range before {
+		if k != "updated_at" && !reflect.DeepEqual(v, after[k]) {
+			b[k] = v
+		}
+	}
+	for k, v := range after {
+		if _, ok := before[k]; !ok || (k != "updated_at" && !reflect.DeepEqual(v, before[k])) {
+			a[k] = v
+		}
+	}
+	delete(a, "updated_at")
+	return b, a
+}
+
+// AuditFilter 审计记录查询条件
+type AuditFilter struct {
+	ActorID    uint
+	Action     string // 完整动作，或前缀如 moderation、auth
+	TargetType string
+	TargetID   uint
+	IP         string
+	RequestID  string
+	From       time.Time // 零值不限
+	To         time.Time // 零值不限
+}
+
+// ListAuditLogs 分页查询审计记录，新记录在前
+func ListAuditLogs(f AuditFilter, req PageRequest) ([]model.AuditLog, PageInfo, error) {
+	db := database.DB.Model(&model.AuditLog{})
+	if f.ActorID != 0 {
+		db = db.Where("actor_id = ?", f.ActorID)
+	}
+	if f.Action != "" {
+		db = db.Where("(action = ? OR action LIKE ?)", f.Action, strings.TrimSuffix(f.Action, ".")+".%")
+	}
+	if f.TargetType != "" {
+		db = db.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != 0 {
+		db = db.Where("target_id = ?", f.TargetID)
+	}
+	if f.IP != "" {
+		db = db.Where("ip = ?", f.IP)
+	}
+	if f.RequestID != "" {
+		db = db.Where("request_id = ?", f.RequestID)
+	}
+	if !f.From.IsZero() {
+		db = db.Where("julianday(created_at) >= julianday(?)", f.From)
+	}
+	if !f.To.IsZero() {
+		db = db.Where("julianday(created_at) < julianday(?)", f.To)
+	}
+	ks := keyset{scope: "audit", columns: []string{"created_at desc", "id desc"}}
+	list, info, err := paginate(db, req, ks, func(l *model.AuditLog) []interface{} {
+		return []interface{}{l.CreatedAt, l.ID}
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	ids := make([]uint, 0, len(list))
+	for _, l := range list {
+		ids = append(ids, l.ActorID)
+	}
+	users, err := userBriefs(ids)
+	if err != nil {
+		return nil, info, err
+	}
+	for i := range list {
+		list[i].Actor = users[list[i].ActorID]
+	}
+	return list, info, nil
+}
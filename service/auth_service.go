@@ -5,6 +5,8 @@ import (
 	"prompt-share-backend/database"
 	"prompt-share-backend/model"
 	"prompt-share-backend/utils"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -41,3 +43,62 @@ func Login(username, password string) (string, *model.User, error) {
 	ts, err := token.SignedString([]byte(config.Cfg.JWT.Secret))
 	return ts, &u, err
 }
+
+// GetUser 查询用户
+func GetUser(id uint) (*model.User, error) {
+	var u model.User
+	if err := database.DB.First(&u, id).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// ProfileInput 可修改的个人资料，nil 表示不修改
+type ProfileInput struct {
+	Avatar         *string `json:"avatar"`
+	NSFWPreference *string `json:"nsfw_preference"` // show、blur、hide，空字符串恢复站点默认值
+}
+
+// UpdateProfile 修改个人资料
+func UpdateProfile(id uint, in ProfileInput) (*model.User, error) {
+	if p := in.NSFWPreference; p != nil {
+		if *p != "" && !slices.Contains(model.NSFWPreferences, *p) {
+			return nil, utils.FieldErrors{"nsfw_preference": "must be one of " + strings.Join(model.NSFWPreferences, ", ") + " or empty"}
+		}
+		if err := database.DB.Model(&model.User{}).Where("id = ?", id).UpdateColumn("nsfw_preference", *p).Error; err != nil {
+			return nil, err
+		}
+	}
+	if avatar := in.Avatar; avatar != nil {
+		a := strings.TrimSpace(*avatar)
+		if a != "" && !utils.IsHTTPURL(a) && !strings.HasPrefix(a, "/") {
+			return nil, utils.FieldErrors{"avatar": "must be an http(s) URL or a site path"}
+		}
+		if err := database.DB.Model(&model.User{}).Where("id = ?", id).UpdateColumn("avatar", a).Error; err != nil {
+			return nil, err
+		}
+	}
+	return GetUser(id)
+}
+
+// userRoles 可分配的角色
+var userRoles = []string{model.RoleUser, model.RoleModerator, model.RoleAdmin}
+
+// SetUserRole 管理员修改用户角色，不能修改自己的角色，同时返回修改前的用户
+func SetUserRole(adminID, id uint, role string) (before, after *model.User, err error) {
+	if !slices.Contains(userRoles, role) {
+		return nil, nil, utils.FieldErrors{"role": "must be one of " + strings.Join(userRoles, ", ")}
+	}
+	if id == adminID {
+		return nil, nil, utils.FieldErrors{"role": "cannot change your own role"}
+	}
+	before, err = GetUser(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := database.DB.Model(&model.User{}).Where("id = ?", id).UpdateColumn("role", role).Error; err != nil {
+		return nil, nil, err
+	}
+	after, err = GetUser(id)
+	return before, after, err
+}
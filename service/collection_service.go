@@ -0,0 +1,551 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CollectionUpdate 收藏夹可修改字段，nil 表示不修改
+type CollectionUpdate struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Visibility  *string `json:"visibility"`
+	CoverImgID  *uint   `json:"cover_img_id"`
+}
+
+// 收藏夹字段长度限制（字符），与表结构一致
+const (
+	MaxCollectionNameLen        = 100
+	MaxCollectionDescriptionLen = 500
+)
+
+// collectionVisibilities 收藏夹可选的可见性
+var collectionVisibilities = []string{model.VisibilityPrivate, model.VisibilityUnlisted, model.VisibilityPublic}
+
+// CreateCollection 创建收藏夹
+func CreateCollection(col *model.Collection) error {
+	col.Name = strings.TrimSpace(col.Name)
+	if col.Visibility == "" {
+		col.Visibility = model.VisibilityPrivate
+	}
+	errs := utils.FieldErrors{}
+	if col.Name == "" {
+		errs.Add("name", "is required")
+	}
+	utils.CheckLen(errs, "name", col.Name, MaxCollectionNameLen)
+	utils.CheckLen(errs, "description", col.Description, MaxCollectionDescriptionLen)
+	checkOneOf(errs, "visibility", col.Visibility, collectionVisibilities...)
+	if err := errs.Err(); err != nil {
+		return err
+	}
+	col.ID = 0
+	col.ShareToken = uuid.New().String()
+	col.CoverImgID, col.CoverUrl = 0, ""
+	col.ItemCount, col.FollowerCount = 0, 0
+	return database.DB.Create(col).Error
+}
+
+// findCollection 查询收藏夹并转换 not found 错误
+func findCollection(tx *gorm.DB, id uint) (*model.Collection, error) {
+	var col model.Collection
+	if err := tx.First(&col, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &col, nil
+}
+
+// ownedCollection 查询收藏夹并校验所有者
+func ownedCollection(tx *gorm.DB, id, userID uint) (*model.Collection, error) {
+	col, err := findCollection(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if col.UserID != userID {
+		return nil, ErrForbidden
+	}
+	return col, nil
+}
+
+// canViewCollection 所有者可见全部；public 所有人可见；unlisted 需携带分享 token
+func canViewCollection(col *model.Collection, viewerID uint, token string) bool {
+	switch {
+	case viewerID != 0 && col.UserID == viewerID:
+		return true
+	case col.Visibility == model.VisibilityPublic:
+		return true
+	case col.Visibility == model.VisibilityUnlisted:
+		return token != "" && token == col.ShareToken
+	}
+	return false
+}
+
+// GetCollection 收藏夹详情，包含按顺序排列的成员 prompt
+func GetCollection(id, viewerID uint, token string) (*model.Collection, error) {
+	col, err := findCollection(database.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canViewCollection(col, viewerID, token) {
+		// 不暴露私有收藏夹是否存在
+		return nil, ErrNotFound
+	}
+
+	var items []model.CollectionItem
+	if err := database.DB.Where("collection_id = ?", id).Order("position asc, id asc").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if len(items) > 0 {
+		ids := make([]uint, len(items))
+		for i, it := range items {
+			ids[i] = it.PromptID
+		}
+		nsfw, err := NSFWPreference(viewerID)
+		if err != nil {
+			return nil, err
+		}
+		var prompts []model.Prompt
+		if err := hideNSFW(database.DB.Where("id IN ? AND status = ?", ids, model.StatusVisible), nsfw).
+			Find(&prompts).Error; err != nil {
+			return nil, err
+		}
+		if err := FillPromptImages(prompts); err != nil {
+			return nil, err
+		}
+		prompts = ApplyNSFWPreference(nsfw, prompts)
+		if err := FillUserMarks(viewerID, prompts); err != nil {
+			return nil, err
+		}
+		promptMap := make(map[uint]*model.Prompt, len(prompts))
+		for i := range prompts {
+			promptMap[prompts[i].ID] = &prompts[i]
+		}
+		for i := range items {
+			items[i].Prompt = promptMap[items[i].PromptID]
+		}
+	}
+	col.Items = items
+
+	list := []model.Collection{*col}
+	if err := prepareCollections(viewerID, list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// ListUserCollections 查询某用户的收藏夹，非本人只能看到 public
+func ListUserCollections(ownerID, viewerID uint, req PageRequest) ([]model.Collection, PageInfo, error) {
+	db := database.DB.Model(&model.Collection{}).Where("user_id = ?", ownerID)
+	if ownerID != viewerID {
+		db = db.Where("visibility = ?", model.VisibilityPublic)
+	}
+	return pageCollections(db, viewerID, "collections:user", req)
+}
+
+// ListPublicCollections 查询所有公开收藏夹
+func ListPublicCollections(viewerID uint, req PageRequest) ([]model.Collection, PageInfo, error) {
+	db := database.DB.Model(&model.Collection{}).Where("visibility = ?", model.VisibilityPublic)
+	return pageCollections(db, viewerID, "collections:public", req)
+}
+
+// ListFollowedCollections 查询用户关注的收藏夹（仍需为 public），按关注时间倒序
+func ListFollowedCollections(userID uint, req PageRequest) ([]model.Collection, PageInfo, error) {
+	db := database.DB.Model(&model.CollectionFollow{}).
+		Joins("JOIN collections ON collections.id = collection_follows.collection_id").
+		Where("collection_follows.user_id = ? AND collections.visibility = ?", userID, model.VisibilityPublic)
+	ks := keyset{scope: "collections:following", selects: "collection_follows.*",
+		columns: []string{"collection_follows.created_at desc", "collection_follows.id desc"}}
+	follows, info, err := paginate(db, req, ks, func(f *model.CollectionFollow) []interface{} {
+		return []interface{}{f.CreatedAt, f.ID}
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	ids := make([]uint, len(follows))
+	for i := range follows {
+		ids[i] = follows[i].CollectionID
+	}
+	var cols []model.Collection
+	if err := database.DB.Where("id IN ?", ids).Find(&cols).Error; err != nil {
+		return nil, info, err
+	}
+	byID := make(map[uint]model.Collection, len(cols))
+	for _, col := range cols {
+		byID[col.ID] = col
+	}
+	list := make([]model.Collection, 0, len(follows))
+	for _, id := range ids {
+		if col, ok := byID[id]; ok {
+			list = append(list, col)
+		}
+	}
+	if err := prepareCollections(userID, list); err != nil {
+		return nil, info, err
+	}
+	return list, info, nil
+}
+
+// pageCollections 按更新时间倒序分页
+func pageCollections(db *gorm.DB, viewerID uint, scope string, req PageRequest) ([]model.Collection, PageInfo, error) {
+	ks := keyset{scope: scope, columns: []string{"updated_at desc", "id desc"}}
+	list, info, err := paginate(db, req, ks, func(c *model.Collection) []interface{} {
+		return []interface{}{c.UpdatedAt, c.ID}
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	if err := prepareCollections(viewerID, list); err != nil {
+		return nil, info, err
+	}
+	return list, info, nil
+}
+
+// prepareCollections 填充默认封面、关注状态，并隐藏非所有者的分享 token
+func prepareCollections(viewerID uint, list []model.Collection) error {
+	ids := make([]uint, 0, len(list))
+	for i := range list {
+		ids = append(ids, list[i].ID)
+		if list[i].UserID != viewerID || viewerID == 0 {
+			list[i].ShareToken = ""
+		}
+		if list[i].CoverImgID == 0 && list[i].ItemCount > 0 {
+			// 未指定封面时取第一个成员的第一张图片
+			var img model.PromptImg
+			err := database.DB.Model(&model.PromptImg{}).
+				Joins("JOIN collection_items ON collection_items.prompt_id = prompt_imgs.prompt_id").
+				Where("collection_items.collection_id = ?", list[i].ID).
+				Order("collection_items.position asc, prompt_imgs.id asc").
+				Limit(1).Find(&img).Error
+			if err != nil {
+				return err
+			}
+			list[i].CoverUrl = img.FileUrl
+		}
+	}
+	if viewerID == 0 || len(ids) == 0 {
+		return nil
+	}
+
+	var followed []uint
+	if err := database.DB.Model(&model.CollectionFollow{}).
+		Where("user_id = ? AND collection_id IN ?", viewerID, ids).
+		Pluck("collection_id", &followed).Error; err != nil {
+		return err
+	}
+	followedSet := make(map[uint]bool, len(followed))
+	for _, id := range followed {
+		followedSet[id] = true
+	}
+	for i := range list {
+		list[i].FollowedByMe = followedSet[list[i].ID]
+	}
+	return nil
+}
+
+// UpdateCollection 修改收藏夹信息，仅所有者可操作
+func UpdateCollection(id, userID uint, in CollectionUpdate) (*model.Collection, error) {
+	var col *model.Collection
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if col, err = ownedCollection(tx, id, userID); err != nil {
+			return err
+		}
+		errs := utils.FieldErrors{}
+		if in.Name != nil {
+			col.Name = strings.TrimSpace(*in.Name)
+			if col.Name == "" {
+				errs.Add("name", "is required")
+			}
+			utils.CheckLen(errs, "name", col.Name, MaxCollectionNameLen)
+		}
+		if in.Description != nil {
+			col.Description = *in.Description
+			utils.CheckLen(errs, "description", col.Description, MaxCollectionDescriptionLen)
+		}
+		if in.Visibility != nil {
+			col.Visibility = *in.Visibility
+			if col.Visibility == "" {
+				errs.Add("visibility", "is required")
+			}
+			checkOneOf(errs, "visibility", col.Visibility, collectionVisibilities...)
+		}
+		if err := errs.Err(); err != nil {
+			return err
+		}
+		if in.CoverImgID != nil {
+			if *in.CoverImgID == 0 {
+				col.CoverImgID, col.CoverUrl = 0, ""
+			} else {
+				// 封面必须是收藏夹成员的图片
+				var img model.PromptImg
+				err := tx.Model(&model.PromptImg{}).
+					Joins("JOIN collection_items ON collection_items.prompt_id = prompt_imgs.prompt_id").
+					Where("collection_items.collection_id = ? AND prompt_imgs.id = ?", id, *in.CoverImgID).
+					First(&img).Error
+				if err != nil {
+					if errors.Is(err, gorm.ErrRecordNotFound) {
+						return utils.FieldErrors{"cover_img_id": "must be an image of a prompt in this collection"}
+					}
+					return err
+				}
+				col.CoverImgID, col.CoverUrl = img.ID, img.FileUrl
+			}
+		}
+		return tx.Save(col).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return col, nil
+}
+
+// DeleteCollection 删除收藏夹及其成员、关注记录，返回删除前的收藏夹
+func DeleteCollection(id, userID uint) (*model.Collection, error) {
+	var col *model.Collection
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if col, err = ownedCollection(tx, id, userID); err != nil {
+			return err
+		}
+		if err := tx.Where("collection_id = ?", id).Delete(&model.CollectionItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("collection_id = ?", id).Delete(&model.CollectionFollow{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Collection{}, id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return col, nil
+}
+
+// AddCollectionItem 向收藏夹追加 prompt，已存在时只更新备注
+func AddCollectionItem(id, userID, promptID uint, note string) (*model.CollectionItem, error) {
+	var item model.CollectionItem
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := ownedCollection(tx, id, userID); err != nil {
+			return err
+		}
+		var p model.Prompt
+		if err := tx.Select("id").First(&p, promptID).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("collection_id = ? AND prompt_id = ?", id, promptID).First(&item).Error
+		if err == nil {
+			item.Note = note
+			return tx.Save(&item).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var maxPos *int
+		if err := tx.Model(&model.CollectionItem{}).Where("collection_id = ?", id).
+			Select("MAX(position)").Scan(&maxPos).Error; err != nil {
+			return err
+		}
+		item = model.CollectionItem{CollectionID: id, PromptID: promptID, Note: note}
+		if maxPos != nil {
+			item.Position = *maxPos + 1
+		}
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Collection{}).Where("id = ?", id).
+			UpdateColumn("item_count", gorm.Expr("item_count + ?", 1)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// UpdateCollectionItem 修改成员备注
+func UpdateCollectionItem(id, userID, promptID uint, note string) (*model.CollectionItem, error) {
+	var item model.CollectionItem
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := ownedCollection(tx, id, userID); err != nil {
+			return err
+		}
+		if err := tx.Where("collection_id = ? AND prompt_id = ?", id, promptID).First(&item).Error; err != nil {
+			return err
+		}
+		item.Note = note
+		return tx.Save(&item).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// RemoveCollectionItem 从收藏夹移除 prompt，若其图片为封面则清空封面
+func RemoveCollectionItem(id, userID, promptID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		col, err := ownedCollection(tx, id, userID)
+		if err != nil {
+			return err
+		}
+		res := tx.Where("collection_id = ? AND prompt_id = ?", id, promptID).Delete(&model.CollectionItem{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		updates := map[string]interface{}{"item_count": gorm.Expr("item_count - ?", 1)}
+		if col.CoverImgID != 0 {
+			var count int64
+			if err := tx.Model(&model.PromptImg{}).
+				Where("id = ? AND prompt_id = ?", col.CoverImgID, promptID).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				updates["cover_img_id"] = 0
+				updates["cover_url"] = ""
+			}
+		}
+		return tx.Model(&model.Collection{}).Where("id = ?", id).UpdateColumns(updates).Error
+	})
+}
+
+// ReorderCollection 按给定 prompt id 顺序重排，必须包含全部成员
+func ReorderCollection(id, userID uint, promptIDs []uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := ownedCollection(tx, id, userID); err != nil {
+			return err
+		}
+		var existing []uint
+		if err := tx.Model(&model.CollectionItem{}).Where("collection_id = ?", id).
+			Pluck("prompt_id", &existing).Error; err != nil {
+			return err
+		}
+		if len(existing) != len(promptIDs) {
+			return utils.FieldErrors{"prompt_ids": "must list every item exactly once"}
+		}
+		existingSet := make(map[uint]bool, len(existing))
+		for _, pid := range existing {
+			existingSet[pid] = true
+		}
+		for pos, pid := range promptIDs {
+			if !existingSet[pid] {
+				return utils.FieldErrors{"prompt_ids": "must list every item exactly once"}
+			}
+			delete(existingSet, pid)
+			if err := tx.Model(&model.CollectionItem{}).
+				Where("collection_id = ? AND prompt_id = ?", id, pid).
+				UpdateColumn("position", pos).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&model.Collection{}).Where("id = ?", id).Update("updated_at", time.Now()).Error
+	})
+}
+
+// FollowCollection 关注公开收藏夹，返回最新关注数
+func FollowCollection(id, userID uint) (int64, error) {
+	return toggleCollectionFollow(id, userID, true)
+}
+
+// UnfollowCollection 取消关注，返回最新关注数
+func UnfollowCollection(id, userID uint) (int64, error) {
+	return toggleCollectionFollow(id, userID, false)
+}
+
+func toggleCollectionFollow(id, userID uint, on bool) (int64, error) {
+	var count int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		col, err := findCollection(tx, id)
+		if err != nil {
+			return err
+		}
+		if on && (col.Visibility != model.VisibilityPublic || col.UserID == userID) {
+			return fmt.Errorf("%w: only other users' public collections can be followed", ErrInvalidParam)
+		}
+
+		var res *gorm.DB
+		if on {
+			res = tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&model.CollectionFollow{UserID: userID, CollectionID: id})
+		} else {
+			res = tx.Where("user_id = ? AND collection_id = ?", userID, id).Delete(&model.CollectionFollow{})
+		}
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			delta := 1
+			if !on {
+				delta = -1
+			}
+			if err := tx.Model(&model.Collection{}).Where("id = ?", id).
+				UpdateColumn("follower_count", gorm.Expr("follower_count + ?", delta)).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&model.Collection{}).Where("id = ?", id).Pluck("follower_count", &count).Error
+	})
+	return count, err
+}
+
+// CloneCollection 复制一个可见的收藏夹到当前用户名下，新收藏夹默认私有
+func CloneCollection(id, userID uint, token string) (*model.Collection, error) {
+	var clone model.Collection
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		src, err := findCollection(tx, id)
+		if err != nil {
+			return err
+		}
+		if !canViewCollection(src, userID, token) {
+			return ErrNotFound
+		}
+
+		clone = model.Collection{
+			UserID:       userID,
+			Name:         src.Name,
+			Description:  src.Description,
+			Visibility:   model.VisibilityPrivate,
+			ShareToken:   uuid.New().String(),
+			CoverImgID:   src.CoverImgID,
+			CoverUrl:     src.CoverUrl,
+			ItemCount:    src.ItemCount,
+			ClonedFromID: src.ID,
+		}
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+
+		var items []model.CollectionItem
+		if err := tx.Where("collection_id = ?", id).Order("position asc, id asc").Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for i := range items {
+			items[i].ID = 0
+			items[i].CollectionID = clone.ID
+			items[i].Position = i
+		}
+		return tx.Create(&items).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"errors"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"strings"
+	"testing"
+)
+
+func TestCollectionFieldErrors(t *testing.T) {
+	setupTestDB(t)
+	u := createTestUser(t, "alice")
+	long := func(n int) *string { s := strings.Repeat("收", n); return &s }
+
+	cases := []struct {
+		name   string
+		col    model.Collection
+		fields []string
+	}{
+		{"empty name", model.Collection{Name: "  "}, []string{"name"}},
+		{"bad visibility", model.Collection{Name: "x", Visibility: "friends"}, []string{"visibility"}},
+		{"long name", model.Collection{Name: *long(MaxCollectionNameLen + 1)}, []string{"name"}},
+		{"long description", model.Collection{Name: "x", Description: *long(MaxCollectionDescriptionLen + 1)}, []string{"description"}},
+	}
+	for _, c := range cases {
+		col := c.col
+		col.UserID = u.ID
+		var fe utils.FieldErrors
+		if err := CreateCollection(&col); !errors.As(err, &fe) || len(fe) != len(c.fields) || fe[c.fields[0]] == "" {
+			t.Errorf("%s: err = %v, want field errors on %v", c.name, err, c.fields)
+		}
+	}
+
+	col := &model.Collection{UserID: u.ID, Name: *long(MaxCollectionNameLen), Description: *long(MaxCollectionDescriptionLen)}
+	if err := CreateCollection(col); err != nil {
+		t.Fatalf("name and description at the limit: %v", err)
+	}
+	var fe utils.FieldErrors
+	_, err := UpdateCollection(col.ID, u.ID, CollectionUpdate{Name: long(MaxCollectionNameLen + 1), Description: long(MaxCollectionDescriptionLen + 1)})
+	if !errors.As(err, &fe) || fe["name"] == "" || fe["description"] == "" {
+		t.Errorf("update too long: err = %v, want name and description errors", err)
+	}
+}
@@ -1,18 +1,578 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"prompt-share-backend/database"
 	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// MaxCommentLen 评论内容最大长度（字符）
+const MaxCommentLen = 5000
+
+// maxEmbeddedReplies 列表中每个顶层评论最多内嵌的回复数，其余通过 ListReplies 分页获取
+const maxEmbeddedReplies = 100
+
+// maxMentions 单条评论最多解析的 @ 提及数，超出部分按普通文本显示
+const maxMentions = 20
+
+// maxReactionKinds 单条评论最多允许的不同表情数
+const maxReactionKinds = 20
+
+func checkCommentContent(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", utils.FieldErrors{"content": "is required"}
+	}
+	if len([]rune(content)) > MaxCommentLen {
+		return "", utils.FieldErrors{"content": fmt.Sprintf("must be at most %d characters", MaxCommentLen)}
+	}
+	return content, nil
+}
+
+// CreateComment 发表评论或回复，ParentID 非 0 时为回复
+// 命中 review 内容规则时保存为待审，不计入评论数，也不发送通知和事件
 func CreateComment(c *model.Comment) error {
-	return database.DB.Create(c).Error
+	content, err := checkCommentContent(c.Content)
+	if err != nil {
+		return err
+	}
+	c.Content = content
+	verdict, err := checkContent(model.TargetComment, map[string]string{"content": c.Content})
+	if err != nil {
+		return err
+	}
+	if err := CheckPromptVisible(c.PromptID, c.UserID); err != nil {
+		return err
+	}
+	var prompt model.Prompt
+	var parentAuthor uint
+	var mentioned []uint
+	var commentCount int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id", "user_id").First(&prompt, c.PromptID).Error; err != nil {
+			return err
+		}
+		c.ID, c.RootID, c.Depth, c.ReplyCount, c.EditedAt, c.DeletedAt = 0, 0, 0, 0, nil, nil
+		c.Status = model.StatusVisible
+		if verdict.review {
+			c.Status = model.StatusPending
+		}
+		if c.ParentID != 0 {
+			var parent model.Comment
+			if err := tx.First(&parent, c.ParentID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return utils.FieldErrors{"parent_id": "comment not found"}
+				}
+				return err
+			}
+			switch {
+			case parent.PromptID != c.PromptID:
+				return utils.FieldErrors{"parent_id": "comment belongs to another prompt"}
+			case parent.DeletedAt != nil:
+				return utils.FieldErrors{"parent_id": "comment has been deleted"}
+			case parent.Status != model.StatusVisible:
+				return utils.FieldErrors{"parent_id": "comment is not visible"}
+			case parent.Depth >= model.MaxCommentDepth:
+				return utils.FieldErrors{"parent_id": "maximum reply depth reached"}
+			}
+			c.RootID, c.Depth = parent.RootID, parent.Depth+1
+			parentAuthor = parent.UserID
+			if err := tx.Model(&parent).UpdateColumn("reply_count", gorm.Expr("reply_count + 1")).Error; err != nil {
+				return err
+			}
+		}
+		var err error
+		if mentioned, err = renderComment(tx, c); err != nil {
+			return err
+		}
+		if err := tx.Create(c).Error; err != nil {
+			return err
+		}
+		if c.RootID == 0 {
+			c.RootID = c.ID
+			if err := tx.Model(c).UpdateColumn("root_id", c.ID).Error; err != nil {
+				return err
+			}
+		}
+		if err := saveMentions(tx, c.ID, mentioned); err != nil {
+			return err
+		}
+		if _, err := flagForReview(tx, model.TargetComment, c.ID, verdict); err != nil {
+			return err
+		}
+		if c.Status != model.StatusVisible {
+			return nil
+		}
+		return changeCommentCount(tx, c.PromptID, 1, &commentCount)
+	})
+	if err != nil {
+		return err
+	}
+	if err := prepareComments([]*model.Comment{c}, c.UserID); err != nil {
+		return err
+	}
+	if c.Status != model.StatusVisible {
+		return nil
+	}
+	notify(commentEvents(c, prompt.UserID, parentAuthor, mentioned)...)
+	publish(EventCommentCreated, c, PromptTopic(c.PromptID))
+	queueWebhooks(model.WebhookCommentCreated, c, prompt.UserID, c.UserID)
+	publishPrompt(EventPromptCounts, c.PromptID, map[string]interface{}{"id": c.PromptID, "comment_count": commentCount})
+	return nil
+}
+
+// commentEvents 新评论需要发出的通知，每个接收者只收到一条，优先级为回复、提及、评论
+func commentEvents(c *model.Comment, promptAuthor, parentAuthor uint, mentioned []uint) []notifyEvent {
+	var events []notifyEvent
+	seen := map[uint]bool{}
+	add := func(typ string, recipient uint) {
+		if recipient != 0 && !seen[recipient] {
+			seen[recipient] = true
+			events = append(events, notifyEvent{Type: typ, Recipient: recipient, ActorID: c.UserID,
+				PromptID: c.PromptID, CommentID: c.ID})
+		}
+	}
+	add(model.NotifyReply, parentAuthor)
+	for _, id := range mentioned {
+		add(model.NotifyMention, id)
+	}
+	add(model.NotifyComment, promptAuthor)
+	return events
+}
+
+// UpdateComment 作者修改评论内容
+func UpdateComment(id, userID uint, content string) (*model.Comment, error) {
+	content, err := checkCommentContent(content)
+	if err != nil {
+		return nil, err
+	}
+	verdict, err := checkContent(model.TargetComment, map[string]string{"content": content})
+	if err != nil {
+		return nil, err
+	}
+	c, err := ownComment(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	c.Content, c.EditedAt = content, &now
+	var previous, mentioned []uint
+	var change *statusChange
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.CommentMention{}).Where("comment_id = ?", c.ID).Pluck("user_id", &previous).Error; err != nil {
+			return err
+		}
+		var err error
+		if mentioned, err = renderComment(tx, c); err != nil {
+			return err
+		}
+		if err := tx.Model(c).Updates(map[string]interface{}{
+			"content": c.Content, "content_html": c.ContentHTML, "edited_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := saveMentions(tx, c.ID, mentioned); err != nil {
+			return err
+		}
+		change, err = flagForReview(tx, model.TargetComment, c.ID, verdict)
+		if change != nil {
+			c.Status = change.status
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c.Status != model.StatusVisible {
+		if err := prepareComments([]*model.Comment{c}, userID); err != nil {
+			return nil, err
+		}
+		change.publish()
+		return c, nil
+	}
+	// 只通知编辑后新增的提及
+	for _, id := range mentioned {
+		if !slices.Contains(previous, id) {
+			notify(notifyEvent{Type: model.NotifyMention, Recipient: id, ActorID: userID, PromptID: c.PromptID, CommentID: c.ID})
+		}
+	}
+	if err := prepareComments([]*model.Comment{c}, userID); err != nil {
+		return nil, err
+	}
+	publish(EventCommentUpdated, c, PromptTopic(c.PromptID))
+	queueWebhooks(model.WebhookCommentUpdated, c, promptOwner(c.PromptID), c.UserID)
+	return c, nil
+}
+
+// renderComment 解析 @ 提及并渲染 ContentHTML，返回按出现顺序解析到的用户 id
+func renderComment(tx *gorm.DB, c *model.Comment) ([]uint, error) {
+	names := utils.ExtractMentions(c.Content)
+	if len(names) > maxMentions {
+		names = names[:maxMentions]
+	}
+	resolved := make(map[string]uint, len(names))
+	var ids []uint
+	if len(names) > 0 {
+		var users []model.User
+		if err := tx.Select("id", "username").Where("username IN ?", names).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		byName := make(map[string]uint, len(users))
+		for _, u := range users {
+			byName[u.Username] = u.ID
+		}
+		for _, n := range names {
+			if id, ok := byName[n]; ok {
+				resolved[n] = id
+				ids = append(ids, id)
+			}
+		}
+	}
+	c.ContentHTML = utils.RenderMarkdown(c.Content, resolved)
+	return ids, nil
+}
+
+// saveMentions 用 userIDs 覆盖评论的提及记录
+func saveMentions(tx *gorm.DB, commentID uint, userIDs []uint) error {
+	if err := tx.Where("comment_id = ?", commentID).Delete(&model.CommentMention{}).Error; err != nil {
+		return err
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+	rows := make([]model.CommentMention, len(userIDs))
+	for i, id := range userIDs {
+		rows[i] = model.CommentMention{CommentID: commentID, UserID: id}
+	}
+	return tx.Create(&rows).Error
+}
+
+// DeleteComment 作者删除评论，保留占位以维持回复结构，返回删除前的评论；已删除时返回 nil
+func DeleteComment(id, userID uint) (*model.Comment, error) {
+	if _, err := ownComment(id, userID); err != nil {
+		return nil, err
+	}
+	var c *model.Comment
+	var commentCount int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		c, err = removeCommentTx(tx, id, userID, &commentCount)
+		return err
+	})
+	if err != nil || c == nil {
+		return nil, err
+	}
+	commentRemoved(c, commentCount)
+	return c, nil
+}
+
+// removeCommentTx 在事务中软删除评论，公开的评论同时减少 prompt 的评论数并读出最新值
+// 评论已被删除时返回 nil；保留期内可以恢复，见 RestoreTrash
+func removeCommentTx(tx *gorm.DB, id, deletedBy uint, count *int64) (*model.Comment, error) {
+	var c model.Comment
+	if err := tx.Select("id", "user_id", "prompt_id", "parent_id", "content", "status").First(&c, id).Error; err != nil {
+		return nil, err
+	}
+	res := tx.Model(&model.Comment{}).Where("id = ? AND deleted_at IS NULL", c.ID).UpdateColumns(map[string]interface{}{"deleted_at": time.Now(), "deleted_by": deletedBy})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, res.Error
+	}
+	delta := 0
+	if c.Status == model.StatusVisible {
+		delta = -1
+	}
+	return &c, changeCommentCount(tx, c.PromptID, delta, count)
+}
+
+// commentRemoved 删除事务提交后推送事件
+func commentRemoved(c *model.Comment, commentCount int64) {
+	publish(EventCommentDeleted, map[string]uint{"id": c.ID, "prompt_id": c.PromptID}, PromptTopic(c.PromptID))
+	queueWebhooks(model.WebhookCommentDeleted, map[string]uint{"id": c.ID, "prompt_id": c.PromptID, "user_id": c.UserID},
+		promptOwner(c.PromptID), c.UserID)
+	publishPrompt(EventPromptCounts, c.PromptID, map[string]interface{}{"id": c.PromptID, "comment_count": commentCount})
+}
+
+// promptOwner prompt 作者 id，prompt 不存在时为 0
+func promptOwner(id uint) uint {
+	var p model.Prompt
+	database.DB.Select("id", "user_id").Limit(1).Find(&p, id)
+	return p.UserID
+}
+
+// changeCommentCount 调整 prompt 的评论数（不低于 0），并读出最新值
+func changeCommentCount(tx *gorm.DB, promptID uint, delta int, count *int64) error {
+	if err := tx.Model(&model.Prompt{}).Where("id = ?", promptID).
+		UpdateColumn("comment_count", gorm.Expr("max(comment_count + ?, 0)", delta)).Error; err != nil {
+		return err
+	}
+	return tx.Model(&model.Prompt{}).Where("id = ?", promptID).Pluck("comment_count", count).Error
 }
 
-func ListComments(promptID uint) ([]model.Comment, error) {
-	var list []model.Comment
-	if err := database.DB.Where("prompt_id = ?", promptID).Order("created_at desc").Find(&list).Error; err != nil {
+// ownComment 查询未删除的评论并校验作者
+func ownComment(id, userID uint) (*model.Comment, error) {
+	var c model.Comment
+	if err := database.DB.Where("deleted_at IS NULL").First(&c, id).Error; err != nil {
 		return nil, err
 	}
-	return list, nil
+	if c.UserID != userID {
+		return nil, ErrForbidden
+	}
+	return &c, nil
+}
+
+// ListComments 分页查询顶层评论（新的在前），每条内嵌按时间正序排列的回复树
+func ListComments(promptID, viewerID uint, req PageRequest) ([]model.Comment, PageInfo, error) {
+	db := database.DB.Model(&model.Comment{}).Where("prompt_id = ? AND parent_id = 0", promptID)
+	ks := keyset{scope: "comments", columns: []string{"created_at desc", "id desc"}}
+	list, info, err := paginate(db, req, ks, func(c *model.Comment) []interface{} {
+		return []interface{}{c.CreatedAt, c.ID}
+	})
+	if err != nil || len(list) == 0 {
+		return list, info, err
+	}
+
+	rootIDs := make([]uint, len(list))
+	for i := range list {
+		rootIDs[i] = list[i].ID
+	}
+	var replies []model.Comment
+	if err := database.DB.Raw(`SELECT * FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY root_id ORDER BY created_at, id) AS rn
+			FROM comments WHERE root_id IN ? AND parent_id <> 0
+		) WHERE rn <= ? ORDER BY created_at, id`, rootIDs, maxEmbeddedReplies).Scan(&replies).Error; err != nil {
+		return nil, info, err
+	}
+
+	all := make([]*model.Comment, 0, len(list)+len(replies))
+	for i := range list {
+		all = append(all, &list[i])
+	}
+	for i := range replies {
+		all = append(all, &replies[i])
+	}
+	if err := prepareComments(all, viewerID); err != nil {
+		return nil, info, err
+	}
+	for i := range list {
+		list[i].Replies = buildReplyTree(list[i].ID, replies)
+	}
+	return list, info, nil
+}
+
+// ListReplies 分页查询某条评论的直接回复，按时间正序
+func ListReplies(commentID, viewerID uint, req PageRequest) ([]model.Comment, PageInfo, error) {
+	var parent model.Comment
+	if err := database.DB.Select("id", "prompt_id").First(&parent, commentID).Error; err != nil {
+		return nil, PageInfo{}, err
+	}
+	if err := CheckPromptVisible(parent.PromptID, viewerID); err != nil {
+		return nil, PageInfo{}, err
+	}
+	db := database.DB.Model(&model.Comment{}).Where("parent_id = ?", commentID)
+	ks := keyset{scope: "replies", columns: []string{"created_at asc", "id asc"}}
+	list, info, err := paginate(db, req, ks, func(c *model.Comment) []interface{} {
+		return []interface{}{c.CreatedAt, c.ID}
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	ptrs := make([]*model.Comment, len(list))
+	for i := range list {
+		ptrs[i] = &list[i]
+	}
+	return list, info, prepareComments(ptrs, viewerID)
+}
+
+// buildReplyTree 将按时间排序的回复挂到各自的父评论下
+func buildReplyTree(parentID uint, replies []model.Comment) []model.Comment {
+	var out []model.Comment
+	for _, r := range replies {
+		if r.ParentID == parentID {
+			r.Replies = buildReplyTree(r.ID, replies)
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// prepareComments 填充作者、提及的用户和表情回应，已删除的评论以及他人被隐藏、待审的评论隐藏内容、作者和提及
+// viewerID 为当前用户，用于标记其回应过的表情，未登录传 0；版主可以看到隐藏的内容
+func prepareComments(list []*model.Comment, viewerID uint) error {
+	moderator, err := canModerate(viewerID)
+	if err != nil {
+		return err
+	}
+	commentIDs := make([]uint, 0, len(list))
+	userIDs := make([]uint, 0, len(list))
+	for _, c := range list {
+		commentIDs = append(commentIDs, c.ID)
+		if c.DeletedAt == nil {
+			userIDs = append(userIDs, c.UserID)
+		}
+	}
+	var mentions []model.CommentMention
+	if err := database.DB.Where("comment_id IN ?", commentIDs).Order("id").Find(&mentions).Error; err != nil {
+		return err
+	}
+	for _, m := range mentions {
+		userIDs = append(userIDs, m.UserID)
+	}
+	users, err := userBriefs(userIDs)
+	if err != nil {
+		return err
+	}
+	mentioned := make(map[uint][]model.UserBrief)
+	for _, m := range mentions {
+		if u := users[m.UserID]; u != nil {
+			mentioned[m.CommentID] = append(mentioned[m.CommentID], *u)
+		}
+	}
+	reactions, err := reactionCounts(commentIDs, viewerID)
+	if err != nil {
+		return err
+	}
+	for _, c := range list {
+		c.Mentions, c.Reactions = []model.UserBrief{}, []model.ReactionCount{}
+		if c.DeletedAt != nil || (c.Status != model.StatusVisible && c.UserID != viewerID && !moderator) {
+			c.Content, c.ContentHTML, c.UserID, c.Author = "", "", 0, nil
+			continue
+		}
+		c.Author = users[c.UserID]
+		if m := mentioned[c.ID]; m != nil {
+			c.Mentions = m
+		}
+		if r := reactions[c.ID]; r != nil {
+			c.Reactions = r
+		}
+	}
+	return nil
+}
+
+// reactionCounts 批量统计评论的表情回应，按各表情首次出现的顺序排列
+func reactionCounts(commentIDs []uint, viewerID uint) (map[uint][]model.ReactionCount, error) {
+	out := make(map[uint][]model.ReactionCount)
+	if len(commentIDs) == 0 {
+		return out, nil
+	}
+	var rows []struct {
+		CommentID uint
+		model.ReactionCount
+	}
+	if err := database.DB.Model(&model.CommentReaction{}).
+		Select("comment_id, emoji, count(*) AS count, max(user_id = ?) AS reacted", viewerID).
+		Where("comment_id IN ?", commentIDs).
+		Group("comment_id, emoji").Order("min(id)").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.CommentID] = append(out[r.CommentID], r.ReactionCount)
+	}
+	return out, nil
+}
+
+// AddReaction 对评论添加表情回应，重复添加不会重复计数，返回该评论最新的回应统计
+func AddReaction(commentID, userID uint, emoji string) ([]model.ReactionCount, error) {
+	return toggleReaction(commentID, userID, emoji, true)
+}
+
+// RemoveReaction 取消表情回应，未回应时不做任何修改，返回该评论最新的回应统计
+func RemoveReaction(commentID, userID uint, emoji string) ([]model.ReactionCount, error) {
+	return toggleReaction(commentID, userID, emoji, false)
+}
+
+func toggleReaction(commentID, userID uint, emoji string, on bool) ([]model.ReactionCount, error) {
+	if !utils.IsEmoji(emoji) {
+		return nil, utils.FieldErrors{"emoji": "must be a single emoji"}
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id").Where("deleted_at IS NULL AND status = ?", model.StatusVisible).
+			First(&model.Comment{}, commentID).Error; err != nil {
+			return err
+		}
+		if !on {
+			return tx.Where("user_id = ? AND comment_id = ? AND emoji = ?", userID, commentID, emoji).
+				Delete(&model.CommentReaction{}).Error
+		}
+		var kinds int64
+		if err := tx.Model(&model.CommentReaction{}).Where("comment_id = ? AND emoji <> ?", commentID, emoji).
+			Distinct("emoji").Count(&kinds).Error; err != nil {
+			return err
+		}
+		if kinds >= maxReactionKinds {
+			return utils.FieldErrors{"emoji": fmt.Sprintf("a comment can have at most %d different reactions", maxReactionKinds)}
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.CommentReaction{UserID: userID, CommentID: commentID, Emoji: emoji}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	counts, err := reactionCounts([]uint{commentID}, userID)
+	if err != nil {
+		return nil, err
+	}
+	if counts[commentID] == nil {
+		return []model.ReactionCount{}, nil
+	}
+	return counts[commentID], nil
+}
+
+// userBriefs 批量查询用户公开信息
+func userBriefs(ids []uint) (map[uint]*model.UserBrief, error) {
+	out := make(map[uint]*model.UserBrief, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var users []model.User
+	if err := database.DB.Select("id", "username", "avatar").Where("id IN ?", uniqueIDs(ids)).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		out[u.ID] = &model.UserBrief{ID: u.ID, Username: u.Username, Avatar: u.Avatar}
+	}
+	return out, nil
+}
+
+// MigrateComments 回填历史评论的楼层字段、提及与渲染后的 HTML，
+// 以及新增 comment_count 列之前已有评论的 prompt 的评论数，可重复执行
+func MigrateComments() error {
+	if err := database.DB.Exec(`UPDATE comments SET root_id = id WHERE parent_id = 0 AND (root_id = 0 OR root_id IS NULL)`).Error; err != nil {
+		return err
+	}
+	var pending []model.Comment
+	err := database.DB.Where("(content_html = '' OR content_html IS NULL) AND content <> '' AND deleted_at IS NULL").
+		FindInBatches(&pending, 200, func(_ *gorm.DB, _ int) error {
+			return database.DB.Transaction(func(tx *gorm.DB) error {
+				for i := range pending {
+					c := &pending[i]
+					mentioned, err := renderComment(tx, c)
+					if err != nil {
+						return err
+					}
+					if err := tx.Model(c).UpdateColumn("content_html", c.ContentHTML).Error; err != nil {
+						return err
+					}
+					if err := saveMentions(tx, c.ID, mentioned); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}).Error
+	if err != nil {
+		return err
+	}
+	return database.DB.Exec(`UPDATE prompts SET comment_count =
+		(SELECT count(*) FROM comments WHERE comments.prompt_id = prompts.id AND comments.deleted_at IS NULL
+			AND comments.status = 'visible')
+		WHERE comment_count = 0 AND id IN (SELECT prompt_id FROM comments)`).Error
 }
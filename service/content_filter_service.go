@@ -0,0 +1,402 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 内容规则限制
+const (
+	maxRulePatterns = 1000
+	maxPatternLen   = 256
+)
+
+// contentRuleRefresh 定期从数据库重新加载规则，使直接改库或其他实例的修改也能生效
+const contentRuleRefresh = time.Minute
+
+// nsfwTag nsfw 规则自动添加的标签
+const nsfwTag = "nsfw"
+
+// compiledRule 预处理后的规则
+type compiledRule struct {
+	model.ContentRule
+	keywords []string
+	regexps  []*regexp.Regexp
+}
+
+// contentRules 当前生效的规则，整体替换实现热加载
+var contentRules atomic.Pointer[[]compiledRule]
+
+// InitContentFilter 加载内容规则并定期刷新
+func InitContentFilter() {
+	if err := ReloadContentRules(); err != nil {
+		log.Println("load content rules failed:", err)
+	}
+	go func() {
+		for range time.Tick(contentRuleRefresh) {
+			if err := ReloadContentRules(); err != nil {
+				log.Println("reload content rules failed:", err)
+			}
+		}
+	}()
+}
+
+// ReloadContentRules 从数据库重新加载启用的规则，无法编译的规则跳过并记录日志
+func ReloadContentRules() error {
+	var rules []model.ContentRule
+	if err := database.DB.Where("enabled = ?", true).Order("id").Find(&rules).Error; err != nil {
+		return err
+	}
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, r := range rules {
+		c, err := compileRule(r)
+		if err != nil {
+			log.Printf("content rule %d skipped: %v", r.ID, err)
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+	contentRules.Store(&compiled)
+	return nil
+}
+
+func compileRule(r model.ContentRule) (compiledRule, error) {
+	c := compiledRule{ContentRule: r}
+	for _, p := range r.Patterns {
+		if r.Kind == model.RuleRegex {
+			re, err := regexp.Compile("(?i)" + p)
+			if err != nil {
+				return c, err
+			}
+			c.regexps = append(c.regexps, re)
+		} else if k := strings.TrimSpace(utils.NormalizeText(p)); k != "" {
+			c.keywords = append(c.keywords, k)
+		}
+	}
+	return c, nil
+}
+
+// match 返回文本中第一处命中的内容
+func (c *compiledRule) match(text string) (string, bool) {
+	for _, k := range c.keywords {
+		if strings.Contains(text, k) {
+			return k, true
+		}
+	}
+	for _, re := range c.regexps {
+		if m := re.FindString(text); m != "" {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+func (c *compiledRule) appliesTo(scope string) bool {
+	return len(c.Scopes) == 0 || slices.Contains(c.Scopes, scope)
+}
+
+// ContentMatch 一条规则在某个字段上的命中
+type ContentMatch struct {
+	RuleID   uint   `json:"rule_id"`
+	RuleName string `json:"rule_name"`
+	Action   string `json:"action"`
+	Field    string `json:"field"`
+	Match    string `json:"match"` // 归一化后的命中文本
+
+	reason string
+}
+
+// matchContent 对各字段执行适用于 scope 的规则，每条规则在每个字段上最多命中一次
+func matchContent(scope string, fields map[string]string) []ContentMatch {
+	rules := contentRules.Load()
+	if rules == nil || len(*rules) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(fields))
+	for f := range fields {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+	var out []ContentMatch
+	for _, f := range names {
+		if fields[f] == "" {
+			continue
+		}
+		text := utils.NormalizeText(fields[f])
+		for i := range *rules {
+			r := &(*rules)[i]
+			if !r.appliesTo(scope) {
+				continue
+			}
+			if m, ok := r.match(text); ok {
+				out = append(out, ContentMatch{RuleID: r.ID, RuleName: r.Name, Action: r.Action, Field: f, Match: m,
+					reason: r.Reason})
+			}
+		}
+	}
+	return out
+}
+
+// contentVerdict 内容过滤结果
+type contentVerdict struct {
+	review bool
+	reason string   // 进入审核队列时的举报原因，取第一条命中的 review 规则
+	rules  []string // 触发审核的规则名
+	nsfw   bool
+}
+
+// checkContent 执行内容规则并累计命中次数；命中 reject 规则时返回对应字段的校验错误
+// 评论没有标签，nsfw 规则对评论按 review 处理
+func checkContent(scope string, fields map[string]string) (*contentVerdict, error) {
+	matches := matchContent(scope, fields)
+	v := &contentVerdict{}
+	if len(matches) == 0 {
+		return v, nil
+	}
+	errs := utils.FieldErrors{}
+	ids := make([]uint, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.RuleID)
+		action := m.Action
+		if action == model.RuleNSFW && scope != model.TargetPrompt {
+			action = model.RuleReview
+		}
+		switch action {
+		case model.RuleReject:
+			errs.Add(m.Field, "contains disallowed content")
+		case model.RuleNSFW:
+			v.nsfw = true
+		case model.RuleReview:
+			if !v.review {
+				v.review, v.reason = true, m.reason
+			}
+			if !slices.Contains(v.rules, m.RuleName) {
+				v.rules = append(v.rules, m.RuleName)
+			}
+		}
+	}
+	recordRuleHits(uniqueIDs(ids))
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// checkPromptContent 对 prompt 的文本字段执行内容规则，命中 nsfw 规则时将其标记为 nsfw 并添加 nsfw 标签
+func checkPromptContent(p *model.Prompt) (*contentVerdict, error) {
+	v, err := checkContent(model.TargetPrompt, map[string]string{
+		"title": p.Title, "content": p.Content, "negative_prompt": p.NegativePrompt, "tags": p.Tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if v.nsfw {
+		p.NSFW = true
+	}
+	if v.nsfw && !slices.ContainsFunc(SplitTags(p.Tags), func(t string) bool { return utils.Slugify(t) == nsfwTag }) {
+		p.Tags = strings.Trim(p.Tags+","+nsfwTag, ",")
+	}
+	return v, nil
+}
+
+// flagForReview 命中 review 规则时将公开的内容转为待审，并以系统身份（reporter_id 为 0）写入举报
+func flagForReview(tx *gorm.DB, targetType string, id uint, v *contentVerdict) (*statusChange, error) {
+	if v == nil || !v.review {
+		return nil, nil
+	}
+	reason := v.reason
+	if reason == "" {
+		reason = model.ReasonOther
+	}
+	detail := "content rule: " + strings.Join(v.rules, ", ")
+	r := model.Report{TargetType: targetType, TargetID: id, Reason: reason, Detail: detail, Status: model.ReportOpen}
+	if err := upsertReport(tx, &r); err != nil {
+		return nil, err
+	}
+	return setContentStatus(tx, targetType, id, model.StatusPending, model.StatusVisible)
+}
+
+// recordRuleHits 累计规则命中次数，失败只记录日志
+func recordRuleHits(ids []uint) {
+	if len(ids) == 0 {
+		return
+	}
+	if err := database.DB.Model(&model.ContentRule{}).Where("id IN ?", ids).UpdateColumns(map[string]interface{}{
+		"hit_count": gorm.Expr("hit_count + 1"), "last_hit_at": time.Now(),
+	}).Error; err != nil {
+		log.Println("record content rule hits failed:", err)
+	}
+}
+
+// ContentRuleInput 规则可修改字段，nil 表示不修改
+type ContentRuleInput struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Kind        *string   `json:"kind"`     // keyword 或 regex
+	Patterns    *[]string `json:"patterns"` // 关键词或正则，正则为 RE2 语法且不区分大小写
+	Scopes      *[]string `json:"scopes"`   // prompt、comment，空表示全部
+	Action      *string   `json:"action"`   // reject、review 或 nsfw
+	Reason      *string   `json:"reason"`   // review 时的举报原因，默认 other
+	Enabled     *bool     `json:"enabled"`
+}
+
+// applyContentRuleInput 校验并写入字段
+func applyContentRuleInput(r *model.ContentRule, in ContentRuleInput) error {
+	if in.Name != nil {
+		r.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.Description != nil {
+		r.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.Kind != nil {
+		r.Kind = *in.Kind
+	}
+	if in.Patterns != nil {
+		r.Patterns = r.Patterns[:0]
+		for _, p := range *in.Patterns {
+			if p = strings.TrimSpace(p); p != "" && !slices.Contains(r.Patterns, p) {
+				r.Patterns = append(r.Patterns, p)
+			}
+		}
+	}
+	if in.Scopes != nil {
+		r.Scopes = *in.Scopes
+	}
+	if in.Action != nil {
+		r.Action = *in.Action
+	}
+	if in.Reason != nil {
+		r.Reason = *in.Reason
+	}
+	if in.Enabled != nil {
+		r.Enabled = *in.Enabled
+	}
+
+	errs := utils.FieldErrors{}
+	if r.Name == "" {
+		errs.Add("name", "is required")
+	} else if len([]rune(r.Name)) > 100 {
+		errs.Add("name", "must be at most 100 characters")
+	}
+	if len([]rune(r.Description)) > 500 {
+		errs.Add("description", "must be at most 500 characters")
+	}
+	if r.Kind != model.RuleKeyword && r.Kind != model.RuleRegex {
+		errs.Add("kind", "must be keyword or regex")
+	}
+	switch {
+	case len(r.Patterns) == 0:
+		errs.Add("patterns", "is required")
+	case len(r.Patterns) > maxRulePatterns:
+		errs.Add("patterns", fmt.Sprintf("at most %d patterns", maxRulePatterns))
+	}
+	for i, p := range r.Patterns {
+		if len(p) > maxPatternLen {
+			errs.Add("patterns", fmt.Sprintf("pattern %d is longer than %d bytes", i+1, maxPatternLen))
+			break
+		}
+		if r.Kind == model.RuleRegex {
+			if _, err := regexp.Compile("(?i)" + p); err != nil {
+				errs.Add("patterns", fmt.Sprintf("pattern %d: %v", i+1, err))
+				break
+			}
+		}
+	}
+	for _, s := range r.Scopes {
+		if s != model.TargetPrompt && s != model.TargetComment {
+			errs.Add("scopes", "must contain only prompt or comment")
+			break
+		}
+	}
+	checkOneOf(errs, "action", r.Action, model.RuleReject, model.RuleReview, model.RuleNSFW)
+	if r.Action == "" {
+		errs.Add("action", "is required")
+	}
+	if r.Reason == "" {
+		r.Reason = model.ReasonOther
+	}
+	checkOneOf(errs, "reason", r.Reason, model.ReportReasons...)
+	return errs.Err()
+}
+
+// ListContentRules 全部规则，按 id 排列
+func ListContentRules() ([]model.ContentRule, error) {
+	var list []model.ContentRule
+	if err := database.DB.Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CreateContentRule 新建规则，立即生效
+func CreateContentRule(adminID uint, in ContentRuleInput) (*model.ContentRule, error) {
+	r := model.ContentRule{Enabled: true, CreatedBy: adminID}
+	if err := applyContentRuleInput(&r, in); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(&r).Error; err != nil {
+		return nil, err
+	}
+	// gorm 对零值的 false 会使用默认值 true
+	if !r.Enabled {
+		if err := database.DB.Model(&r).UpdateColumn("enabled", false).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &r, ReloadContentRules()
+}
+
+// UpdateContentRule 修改规则，立即生效，同时返回修改前的规则
+func UpdateContentRule(id uint, in ContentRuleInput) (before, after *model.ContentRule, err error) {
+	var r model.ContentRule
+	if err := database.DB.First(&r, id).Error; err != nil {
+		return nil, nil, err
+	}
+	old := r
+	if err := applyContentRuleInput(&r, in); err != nil {
+		return nil, nil, err
+	}
+	if err := database.DB.Select("name", "description", "kind", "patterns", "scopes", "action", "reason", "enabled").
+		Updates(&r).Error; err != nil {
+		return nil, nil, err
+	}
+	return &old, &r, ReloadContentRules()
+}
+
+// DeleteContentRule 删除规则，立即生效，返回删除前的规则
+func DeleteContentRule(id uint) (*model.ContentRule, error) {
+	var r model.ContentRule
+	if err := database.DB.Limit(1).Find(&r, id).Error; err != nil {
+		return nil, err
+	}
+	if r.ID == 0 {
+		return nil, ErrNotFound
+	}
+	if err := database.DB.Delete(&model.ContentRule{}, id).Error; err != nil {
+		return nil, err
+	}
+	return &r, ReloadContentRules()
+}
+
+// TestContentRules 用当前生效的规则检查一段文本，不保存也不计入命中次数
+func TestContentRules(scope, text string) ([]ContentMatch, error) {
+	if scope != model.TargetPrompt && scope != model.TargetComment {
+		return nil, utils.FieldErrors{"scope": "must be prompt or comment"}
+	}
+	matches := matchContent(scope, map[string]string{"text": text})
+	if matches == nil {
+		matches = []ContentMatch{}
+	}
+	return matches, nil
+}
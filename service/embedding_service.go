@@ -0,0 +1,270 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"prompt-share-backend/config"
+	"prompt-share-backend/database"
+	"prompt-share-backend/embedding"
+	"prompt-share-backend/model"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// 语义检索：prompt 变更后异步计算向量写入 prompt_embeddings，同时维护进程内索引做余弦相似度检索
+
+// Embedder 当前使用的向量模型，InitEmbedding 根据配置选择
+var Embedder embedding.Embedder
+
+// embedBatchSize 单次调用 embedder 的文本数
+const embedBatchSize = 32
+
+// vectorIndex 进程内向量索引，暴力扫描，适合数万条以内的规模
+type vectorIndex struct {
+	mu   sync.RWMutex
+	vecs map[uint][]float32
+}
+
+var semanticIndex = &vectorIndex{vecs: map[uint][]float32{}}
+
+func (idx *vectorIndex) set(id uint, v []float32) {
+	idx.mu.Lock()
+	idx.vecs[id] = v
+	idx.mu.Unlock()
+}
+
+func (idx *vectorIndex) remove(id uint) {
+	idx.mu.Lock()
+	delete(idx.vecs, id)
+	idx.mu.Unlock()
+}
+
+func (idx *vectorIndex) get(id uint) []float32 {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.vecs[id]
+}
+
+// SemanticHit 相似度检索结果
+type SemanticHit struct {
+	PromptID uint
+	Score    float32
+}
+
+// search 返回与 q 最相似的 limit 条，exclude 不参与排序
+func (idx *vectorIndex) search(q []float32, limit int, minScore float32, exclude uint) []SemanticHit {
+	idx.mu.RLock()
+	hits := make([]SemanticHit, 0, len(idx.vecs))
+	for id, v := range idx.vecs {
+		if id == exclude {
+			continue
+		}
+		if s := embedding.Dot(q, v); s >= minScore {
+			hits = append(hits, SemanticHit{PromptID: id, Score: s})
+		}
+	}
+	idx.mu.RUnlock()
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].PromptID > hits[j].PromptID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// embedQueue 待计算向量的 prompt，合并短时间内的重复变更
+type embedQueue struct {
+	mu      sync.Mutex
+	pending map[uint]bool
+	wake    chan struct{}
+}
+
+var embeddings = &embedQueue{pending: map[uint]bool{}, wake: make(chan struct{}, 1)}
+
+// queueEmbeddings 在事务提交后调用，异步重新计算向量
+func queueEmbeddings(ids ...uint) {
+	if Embedder == nil || len(ids) == 0 {
+		return
+	}
+	embeddings.mu.Lock()
+	for _, id := range ids {
+		embeddings.pending[id] = true
+	}
+	embeddings.mu.Unlock()
+	select {
+	case embeddings.wake <- struct{}{}:
+	default:
+	}
+}
+
+// InitEmbedding 按配置创建 embedder，加载已有向量，并在后台补算缺失或过期的向量
+func InitEmbedding() error {
+	cfg := config.Cfg.Embedding
+	switch cfg.Provider {
+	case "http":
+		Embedder = embedding.NewHTTPEmbedder(cfg.URL, cfg.Model, time.Duration(cfg.TimeoutSeconds)*time.Second)
+	default:
+		Embedder = embedding.NewHashEmbedder(cfg.Dim)
+	}
+
+	var rows []model.PromptEmbedding
+	if err := database.DB.Where("model = ?", Embedder.Name()).Find(&rows).Error; err != nil {
+		return err
+	}
+	hashes := make(map[uint]string, len(rows))
+	for _, r := range rows {
+		semanticIndex.set(r.PromptID, embedding.Decode(r.Vector))
+		hashes[r.PromptID] = r.TextHash
+	}
+
+	var prompts []model.Prompt
+	if err := database.DB.Select("id", "title", "content", "tags").Find(&prompts).Error; err != nil {
+		return err
+	}
+	var stale []uint
+	for i := range prompts {
+		if hashes[prompts[i].ID] != embeddingTextHash(&prompts[i]) {
+			stale = append(stale, prompts[i].ID)
+		}
+	}
+	go embedWorker()
+	queueEmbeddings(stale...)
+	return nil
+}
+
+func embeddingText(p *model.Prompt) string {
+	return p.Title + "\n" + p.Tags + "\n" + p.Content
+}
+
+func embeddingTextHash(p *model.Prompt) string {
+	sum := sha256.Sum256([]byte(embeddingText(p)))
+	return hex.EncodeToString(sum[:])
+}
+
+func embedWorker() {
+	for range embeddings.wake {
+		for {
+			embeddings.mu.Lock()
+			ids := make([]uint, 0, embedBatchSize)
+			for id := range embeddings.pending {
+				if len(ids) == embedBatchSize {
+					break
+				}
+				ids = append(ids, id)
+				delete(embeddings.pending, id)
+			}
+			embeddings.mu.Unlock()
+			if len(ids) == 0 {
+				break
+			}
+			if err := embedPrompts(ids); err != nil {
+				log.Println("embed prompts failed:", err)
+			}
+		}
+	}
+}
+
+// embedPrompts 计算并保存向量，已删除的 prompt 移出索引
+func embedPrompts(ids []uint) error {
+	var list []model.Prompt
+	if err := database.DB.Select("id", "title", "content", "tags").Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return err
+	}
+	found := make(map[uint]bool, len(list))
+	texts := make([]string, len(list))
+	for i := range list {
+		found[list[i].ID] = true
+		texts[i] = embeddingText(&list[i])
+	}
+	for _, id := range ids {
+		if !found[id] {
+			removeEmbedding(id)
+		}
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	vecs, err := Embedder.Embed(texts)
+	if err != nil {
+		return err
+	}
+	rows := make([]model.PromptEmbedding, len(list))
+	for i := range list {
+		rows[i] = model.PromptEmbedding{
+			PromptID:  list[i].ID,
+			Model:     Embedder.Name(),
+			TextHash:  embeddingTextHash(&list[i]),
+			Vector:    embedding.Encode(vecs[i]),
+			UpdatedAt: time.Now(),
+		}
+	}
+	if err := database.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&rows).Error; err != nil {
+		return err
+	}
+	for i := range list {
+		semanticIndex.set(list[i].ID, vecs[i])
+	}
+	return nil
+}
+
+// removeEmbedding 删除 prompt 的向量
+func removeEmbedding(id uint) {
+	semanticIndex.remove(id)
+	if err := database.DB.Delete(&model.PromptEmbedding{}, id).Error; err != nil {
+		log.Println("delete prompt embedding failed:", err)
+	}
+}
+
+// SimilarPrompts 与指定 prompt 语义最相近的 prompt
+func SimilarPrompts(id uint, limit int) ([]model.Prompt, error) {
+	if _, err := GetPromptByID(id); err != nil {
+		return nil, err
+	}
+	v := semanticIndex.get(id)
+	if v == nil {
+		// 向量尚未计算完成
+		return []model.Prompt{}, nil
+	}
+	return loadSemanticHits(semanticIndex.search(v, limit, 0, id))
+}
+
+// SemanticSearch 按自然语言描述检索语义相近的 prompt
+func SemanticSearch(text string, limit int, minScore float32) ([]model.Prompt, error) {
+	vecs, err := Embedder.Embed([]string{text})
+	if err != nil {
+		return nil, err
+	}
+	return loadSemanticHits(semanticIndex.search(vecs[0], limit, minScore, 0))
+}
+
+func loadSemanticHits(hits []SemanticHit) ([]model.Prompt, error) {
+	ids := make([]uint, len(hits))
+	for i, h := range hits {
+		ids[i] = h.PromptID
+	}
+	var prompts []model.Prompt
+	if err := database.DB.Where("id IN ? AND status = ?", ids, model.StatusVisible).Find(&prompts).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]model.Prompt, len(prompts))
+	for _, p := range prompts {
+		byID[p.ID] = p
+	}
+	list := make([]model.Prompt, 0, len(hits))
+	for _, h := range hits {
+		if p, ok := byID[h.PromptID]; ok {
+			score := h.Score
+			p.Score = &score
+			list = append(list, p)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 后台向量 worker 写库时，请求事务不应因 "database is locked" 失败
+func TestCreatePromptWhileEmbeddingWorkerDrains(t *testing.T) {
+	setupTestDB(t)
+	if err := InitEmbedding(); err != nil {
+		t.Fatal(err)
+	}
+	u := createTestUser(t, "alice")
+
+	const writers, perWriter = 4, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				p := &model.Prompt{
+					UserID:  u.ID,
+					Title:   fmt.Sprintf("prompt %d-%d", w, i),
+					Content: fmt.Sprintf("a watercolor painting of a lighthouse, variant %d-%d", w, i),
+					Tags:    "watercolor,lighthouse",
+				}
+				if err := CreatePrompt(p); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("CreatePrompt: %v", err)
+	}
+
+	// 等待 worker 处理完队列，全部 prompt 都应有向量
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var n int64
+		if err := database.DB.Model(&model.PromptEmbedding{}).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		if n == writers*perWriter {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("embedded %d of %d prompts", n, writers*perWriter)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
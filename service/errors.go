@@ -0,0 +1,19 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrForbidden    = errors.New("permission denied")
+	ErrNotFound     = errors.New("not found")
+	ErrInvalidParam = errors.New("invalid parameter")
+	ErrConflict     = errors.New("version conflict")
+
+	// ErrNSFWHidden 浏览者的 nsfw 偏好为 hide
+	ErrNSFWHidden = fmt.Errorf("%w: nsfw content is hidden by your preference", ErrForbidden)
+
+	// ErrPromptTrashed 评论所属的 prompt 在回收站中，需先恢复 prompt
+	ErrPromptTrashed = fmt.Errorf("%w: the prompt of this comment is in the trash", ErrNotFound)
+)
@@ -39,7 +39,7 @@ func SaveUploadedFile(fh *multipart.FileHeader, prefix string, uploaderID uint)
 	// 2. 生成缩略图
 	// 判断是否为图片
 	contentType := fh.Header.Get("Content-Type")
-	var thumbnail string
+	var thumbnail, phash string
 	if utils.IsImage(contentType) {
 		reader, err := GetFileReader(path)
 		if err != nil {
@@ -53,6 +53,9 @@ func SaveUploadedFile(fh *multipart.FileHeader, prefix string, uploaderID uint)
 		if err != nil {
 			return nil, err
 		}
+		if h, err := utils.PerceptualHash(rawImageData); err == nil {
+			phash = utils.FormatHash(h)
+		}
 	}
 
 	fi := &model.File{
@@ -62,10 +65,25 @@ func SaveUploadedFile(fh *multipart.FileHeader, prefix string, uploaderID uint)
 		Size:       fh.Size,
 		Type:       contentType,
 		Thumbnail:  thumbnail,
+		PHash:      phash,
 	}
 	if err := database.DB.Create(fi).Error; err != nil {
 		return nil, err
 	}
+	if fi.PHash != "" {
+		pref, err := NSFWPreference(uploaderID)
+		if err != nil {
+			return nil, err
+		}
+		matches, err := nearDuplicates(fi.PHash, NearDuplicateDistance, fi.ID, pref)
+		if err != nil {
+			return nil, err
+		}
+		fi.NearDuplicates = matches
+		addImageHash(fi.PHash, fi.ID)
+	}
+	publish(EventFileProcessed, fi, UserTopic(uploaderID))
+	queueWebhooks(model.WebhookFileUploaded, fi, uploaderID)
 	return fi, nil
 }
 
@@ -94,10 +112,12 @@ func GetFileReader(path string) (io.ReadCloser, error) {
 	return Store.Open(path)
 }
 
-func QueryFiles(q string, tag string, page int, pageSize int) ([]model.File, int64, error) {
-	var list []model.File
-	var total int64
+// QueryFiles 分页查询文件，nsfw 为浏览者的偏好：hide 时排除被标记的图片，blur 时输出模糊的缩略图
+func QueryFiles(q string, tag string, nsfw string, req PageRequest) ([]model.File, PageInfo, error) {
 	db := database.DB.Model(&model.File{})
+	if nsfw == model.NSFWHide {
+		db = db.Where("id NOT IN (?)", nsfwFiles())
+	}
 
 	if q != "" {
 		db = db.Where("name LIKE ?", "%"+q+"%")
@@ -105,30 +125,38 @@ func QueryFiles(q string, tag string, page int, pageSize int) ([]model.File, int
 	if tag != "" {
 		db = db.Where("tags LIKE ?", "%"+tag+"%")
 	}
-	if err := db.Count(&total).Error; err != nil {
-		return nil, 0, err
-	}
-	offset := (page - 1) * pageSize
-	if err := db.Order("created_at desc").Limit(pageSize).Offset(offset).Find(&list).Error; err != nil {
-		return nil, 0, err
+	ks := keyset{scope: "files", columns: []string{"created_at desc", "id desc"}}
+	list, info, err := paginate(db, req, ks, func(f *model.File) []interface{} {
+		return []interface{}{f.CreatedAt, f.ID}
+	})
+	if err != nil {
+		return nil, info, err
 	}
-	return list, total, nil
+	return list, info, applyFileNSFW(nsfw, list)
 }
 
-// DeleteFile 删除文件
-func DeleteFile(id uint) error {
+// DeleteFile 上传者或版主将文件移入回收站，返回删除前的记录
+func DeleteFile(id, userID uint) (*model.File, error) {
+	moderator, err := canModerate(userID)
+	if err != nil {
+		return nil, err
+	}
 	var f model.File
 	if err := database.DB.First(&f, id).Error; err != nil {
-		return err
+		return nil, err
 	}
-
-	// 删除文件存储中的实际文件
-	if err := Store.Delete(f.Path); err != nil {
-		return err
+	if f.UploaderID != userID && !moderator {
+		return nil, ErrForbidden
 	}
 
-	// 从数据库中删除记录
-	return database.DB.Delete(&f).Error
+	// 移入回收站，存储中的文件在保留期结束后清理
+	if err := database.DB.Model(&f).
+		UpdateColumns(map[string]interface{}{"deleted_at": time.Now(), "deleted_by": userID}).Error; err != nil {
+		return nil, err
+	}
+	removeImageHash(f.PHash, f.ID)
+	queueWebhooks(model.WebhookFileDeleted, map[string]interface{}{"id": f.ID, "name": f.Name, "uploader_id": f.UploaderID}, f.UploaderID)
+	return &f, nil
 }
 
 func IsFileUsed(id uint) bool {
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxLineageNodes 谱系树最多返回的节点数，防止热门 prompt 的派生树过大
+const maxLineageNodes = 500
+
+// LineageNode 谱系树节点
+type LineageNode struct {
+	ID           uint           `json:"id"`
+	Title        string         `json:"title"`
+	UserID       uint           `json:"user_id"`
+	AuthorName   string         `json:"author_name"`
+	ForkedFromID uint           `json:"forked_from_id"`
+	ForkCount    int64          `json:"fork_count"`
+	CreatedAt    time.Time      `json:"created_at"`
+	Children     []*LineageNode `json:"children"`
+}
+
+// Lineage prompt 的祖先链与以最早祖先为根的派生树
+type Lineage struct {
+	PromptID  uint           `json:"prompt_id"`
+	Ancestors []*LineageNode `json:"ancestors"` // 从根到直接父级
+	Tree      *LineageNode   `json:"tree"`
+	Truncated bool           `json:"truncated"`
+}
+
+// ForkPrompt 复制 prompt 到当前用户名下，记录派生来源并在 source_by 中署名原作者
+// 只能 fork 公开的 prompt（版主除外），副本同样经过内容规则检查
+func ForkPrompt(id, userID uint) (*model.Prompt, error) {
+	var fork, src model.Prompt
+	var forkCount int64
+	if err := CheckPromptVisible(id, userID); err != nil {
+		return nil, err
+	}
+	moderator, err := canModerate(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.DB.First(&src, id).Error; err != nil {
+		return nil, err
+	}
+	// 作者能看到自己被隐藏或待审的 prompt，但不能借 fork 绕过审核
+	if src.Status != model.StatusVisible && !moderator {
+		return nil, fmt.Errorf("%w: only visible prompts can be forked", ErrForbidden)
+	}
+	var u model.User
+	if err := database.DB.Select("id", "username").First(&u, userID).Error; err != nil {
+		return nil, err
+	}
+
+	// 原 prompt 已有署名时沿用，保证最初作者一直被保留
+	credit := src.SourceBy
+	if credit == "" {
+		credit = src.AuthorName
+	}
+	fork = model.Prompt{
+		Title:          src.Title,
+		Content:        src.Content,
+		Tags:           src.Tags,
+		UserID:         userID,
+		AuthorName:     u.Username,
+		SourceBy:       credit,
+		SourceURL:      src.SourceURL,
+		SourceTags:     src.SourceTags,
+		Variables:      src.Variables,
+		NegativePrompt: src.NegativePrompt,
+		ModelFamily:    src.ModelFamily,
+		Params:         src.Params,
+		ForkedFromID:   src.ID,
+		NSFW:           src.NSFW,
+		NSFWLocked:     src.NSFWLocked,
+	}
+	// 原 prompt 创建后新增的规则同样适用于副本
+	verdict, err := checkPromptContent(&fork)
+	if err != nil {
+		return nil, err
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		set, err := resolvePromptTags(tx, &fork)
+		if err != nil {
+			return err
+		}
+		if err := set.checkBanned(); err != nil {
+			return err
+		}
+		if err := createPromptTx(tx, &fork); err != nil {
+			return err
+		}
+		if err := set.save(tx, fork.ID); err != nil {
+			return err
+		}
+
+		var images []model.PromptImg
+		if err := tx.Where("prompt_id = ?", src.ID).Order("id asc").Find(&images).Error; err != nil {
+			return err
+		}
+		if len(images) > 0 {
+			for i := range images {
+				images[i].ID = 0
+				images[i].PromptID = fork.ID
+			}
+			if err := tx.Create(&images).Error; err != nil {
+				return err
+			}
+			if err := syncImageTags(tx, images, true); err != nil {
+				return err
+			}
+			fork.Images = images
+		}
+
+		if err := tx.Model(&model.Prompt{}).Where("id = ?", src.ID).
+			UpdateColumn("fork_count", gorm.Expr("fork_count + ?", 1)).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Prompt{}).Where("id = ?", src.ID).Pluck("fork_count", &forkCount).Error; err != nil {
+			return err
+		}
+		if err := recordRevision(tx, &fork, userID); err != nil {
+			return err
+		}
+		change, err := flagForReview(tx, model.TargetPrompt, fork.ID, verdict)
+		if change != nil {
+			fork.Status = change.status
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	queueEmbeddings(fork.ID)
+	publishPrompt(EventPromptCounts, src.ID, map[string]interface{}{"id": src.ID, "fork_count": forkCount})
+	// 待审的副本不对外推送
+	if fork.Status == model.StatusVisible {
+		publishPrompt(EventPromptCreated, fork.ID, &fork)
+		queueWebhooks(model.WebhookPromptCreated, &fork, fork.UserID)
+		notify(notifyEvent{Type: model.NotifyFork, Recipient: src.UserID, ActorID: userID, PromptID: id})
+	}
+	return &fork, nil
+}
+
+// ListForks 分页查询直接派生自该 prompt 的副本，nsfw 为浏览者的偏好
+func ListForks(id uint, nsfw string, req PageRequest) ([]model.Prompt, PageInfo, error) {
+	db := hideNSFW(database.DB.Model(&model.Prompt{}).Where("forked_from_id = ? AND status = ?", id, model.StatusVisible), nsfw)
+	ks := keyset{scope: "forks", columns: []string{"created_at desc", "id desc"}}
+	return paginate(db, req, ks, func(p *model.Prompt) []interface{} {
+		return []interface{}{p.CreatedAt, p.ID}
+	})
+}
+
+func lineageNode(p *model.Prompt) *LineageNode {
+	return &LineageNode{
+		ID:           p.ID,
+		Title:        p.Title,
+		UserID:       p.UserID,
+		AuthorName:   p.AuthorName,
+		ForkedFromID: p.ForkedFromID,
+		ForkCount:    p.ForkCount,
+		CreatedAt:    p.CreatedAt,
+		Children:     []*LineageNode{},
+	}
+}
+
+// GetLineage 查询祖先链，并以最早仍存在的祖先为根构建派生树
+// 隐藏和待审的 prompt 视同已删除，其后代也不在树中出现
+func GetLineage(id uint) (*Lineage, error) {
+	lineageFields := []string{"id", "title", "user_id", "author_name", "forked_from_id", "fork_count", "created_at"}
+
+	var cur model.Prompt
+	if err := database.DB.Select(lineageFields).First(&cur, id).Error; err != nil {
+		return nil, err
+	}
+
+	// 1. 向上追溯祖先，来源已被删除时停止
+	var ancestors []*LineageNode
+	root := cur
+	seen := map[uint]bool{cur.ID: true}
+	for root.ForkedFromID != 0 && !seen[root.ForkedFromID] {
+		var parent model.Prompt
+		err := database.DB.Select(lineageFields).Where("status = ?", model.StatusVisible).
+			First(&parent, root.ForkedFromID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		seen[parent.ID] = true
+		ancestors = append([]*LineageNode{lineageNode(&parent)}, ancestors...)
+		root = parent
+	}
+
+	// 2. 递归查询根的全部后代
+	var descendants []model.Prompt
+	err := database.DB.Raw(`
+		WITH RECURSIVE tree(id, depth) AS (
+			SELECT id, 0 FROM prompts WHERE id = ?
+			UNION ALL
+			SELECT p.id, tree.depth + 1 FROM prompts p JOIN tree ON p.forked_from_id = tree.id
+			WHERE tree.depth < 64 AND p.status = ? AND p.deleted_at IS NULL
+		)
+		SELECT id, title, user_id, author_name, forked_from_id, fork_count, created_at
+		FROM prompts WHERE id IN (SELECT id FROM tree) AND id <> ?
+		ORDER BY created_at ASC, id ASC LIMIT ?`, root.ID, model.StatusVisible, root.ID, maxLineageNodes+1).
+		Scan(&descendants).Error
+	if err != nil {
+		return nil, err
+	}
+
+	lineage := &Lineage{PromptID: id, Ancestors: ancestors}
+	if ancestors == nil {
+		lineage.Ancestors = []*LineageNode{}
+	}
+	if len(descendants) > maxLineageNodes {
+		descendants = descendants[:maxLineageNodes]
+		lineage.Truncated = true
+	}
+
+	// 3. 组装树，父节点先于子节点创建，按创建时间顺序即可挂载
+	rootNode := lineageNode(&root)
+	nodes := map[uint]*LineageNode{root.ID: rootNode}
+	for i := range descendants {
+		n := lineageNode(&descendants[i])
+		nodes[n.ID] = n
+	}
+	for i := range descendants {
+		n := nodes[descendants[i].ID]
+		if parent, ok := nodes[n.ForkedFromID]; ok {
+			parent.Children = append(parent.Children, n)
+		}
+	}
+	lineage.Tree = rootNode
+	return lineage, nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"io"
+	"log"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"sort"
+	"sync"
+)
+
+// 感知哈希近似重复检测：进程内 BK 树索引所有图片的 pHash
+
+const (
+	// NearDuplicateDistance 上传时提示近似重复的汉明距离阈值
+	NearDuplicateDistance = 10
+	// MaxSimilarDistance 相似图片查询允许的最大距离
+	MaxSimilarDistance = 24
+)
+
+// hashableTypes 能解码并计算感知哈希的图片类型
+var hashableTypes = []string{"image/jpeg", "image/jpg", "image/png", "image/gif"}
+
+var imageHashes struct {
+	mu   sync.RWMutex
+	tree utils.BKTree
+}
+
+func addImageHash(phash string, id uint) {
+	h, err := utils.ParseHash(phash)
+	if err != nil {
+		return
+	}
+	imageHashes.mu.Lock()
+	imageHashes.tree.Add(h, id)
+	imageHashes.mu.Unlock()
+}
+
+func removeImageHash(phash string, id uint) {
+	h, err := utils.ParseHash(phash)
+	if err != nil {
+		return
+	}
+	imageHashes.mu.Lock()
+	imageHashes.tree.Remove(h, id)
+	imageHashes.mu.Unlock()
+}
+
+// InitImageHashes 加载已有图片的感知哈希，并在后台为历史图片补算
+func InitImageHashes() error {
+	var files []model.File
+	if err := database.DB.Select("id", "p_hash").Where("p_hash <> ''").Find(&files).Error; err != nil {
+		return err
+	}
+	for _, f := range files {
+		addImageHash(f.PHash, f.ID)
+	}
+	go backfillImageHashes()
+	return nil
+}
+
+func backfillImageHashes() {
+	var files []model.File
+	if err := database.DB.Select("id", "path").
+		Where("(p_hash IS NULL OR p_hash = '') AND type IN ?", hashableTypes).
+		Find(&files).Error; err != nil {
+		log.Println("load files for phash failed:", err)
+		return
+	}
+	failed := 0
+	for _, f := range files {
+		phash, err := computeFileHash(f.Path)
+		if err == nil {
+			err = database.DB.Model(&model.File{}).Where("id = ?", f.ID).UpdateColumn("p_hash", phash).Error
+		}
+		if err != nil {
+			failed++
+			continue
+		}
+		addImageHash(phash, f.ID)
+	}
+	if failed > 0 {
+		log.Printf("phash backfill: %d of %d images failed (missing or undecodable)", failed, len(files))
+	}
+}
+
+func computeFileHash(path string) (string, error) {
+	reader, err := GetFileReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	h, err := utils.PerceptualHash(data)
+	if err != nil {
+		return "", err
+	}
+	return utils.FormatHash(h), nil
+}
+
+// SimilarFiles 查询与指定图片感知哈希距离不超过 maxDist 的图片，按距离升序
+// 与文件列表一致：回收站中的文件不出现，hide 偏好下被标记为 nsfw 的图片不可查询也不出现在结果中
+func SimilarFiles(id, viewerID uint, pref string, maxDist int) ([]model.FileMatch, error) {
+	var f model.File
+	if err := database.DB.Select("id", "uploader_id", "p_hash").First(&f, id).Error; err != nil {
+		return nil, err
+	}
+	if _, _, err := NSFWFileView(&f, viewerID, pref, false); err != nil {
+		return nil, err
+	}
+	if f.PHash == "" {
+		return []model.FileMatch{}, nil
+	}
+	return nearDuplicates(f.PHash, min(maxDist, MaxSimilarDistance), id, pref)
+}
+
+// nearDuplicates 检索相近的图片，exclude 为自身，pref 为浏览者的 nsfw 偏好
+func nearDuplicates(phash string, maxDist int, exclude uint, pref string) ([]model.FileMatch, error) {
+	h, err := utils.ParseHash(phash)
+	if err != nil {
+		return nil, err
+	}
+	imageHashes.mu.RLock()
+	found := imageHashes.tree.Search(h, maxDist)
+	imageHashes.mu.RUnlock()
+
+	dist := make(map[uint]int, len(found))
+	ids := make([]uint, 0, len(found))
+	for _, m := range found {
+		if m.ID != exclude {
+			dist[m.ID] = m.Distance
+			ids = append(ids, m.ID)
+		}
+	}
+	matches := []model.FileMatch{}
+	if len(ids) == 0 {
+		return matches, nil
+	}
+	// 默认查询条件已排除回收站中的文件
+	db := database.DB.Select("id", "name", "uploader_id").Where("id IN ?", ids)
+	if pref == model.NSFWHide {
+		db = db.Where("id NOT IN (?)", nsfwFiles())
+	}
+	var files []model.File
+	if err := db.Find(&files).Error; err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		matches = append(matches, model.FileMatch{FileID: f.ID, Name: f.Name, UploaderID: f.UploaderID, Distance: dist[f.ID]})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].FileID < matches[j].FileID
+	})
+	return matches, nil
+}
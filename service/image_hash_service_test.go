@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+	"prompt-share-backend/model"
+	"slices"
+	"testing"
+)
+
+func TestSimilarFilesVisibility(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestUser(t, "alice")
+	bob := createTestUser(t, "bob")
+	a := createTestFile(t, alice.ID, 0xf0f0)
+	flagged := createTestFile(t, bob.ID, 0xf0f1)
+	trashed := createTestFile(t, bob.ID, 0xf0f3)
+	plain := createTestFile(t, bob.ID, 0xf0f7)
+
+	p := &model.Prompt{UserID: bob.ID, Title: "t", Content: "c", Images: []model.PromptImg{{FileId: flagged.ID, NSFW: true}}}
+	if err := CreatePrompt(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeleteFile(trashed.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		pref string
+		want []uint
+	}{
+		{model.NSFWShow, []uint{flagged.ID, plain.ID}},
+		{model.NSFWBlur, []uint{flagged.ID, plain.ID}},
+		{model.NSFWHide, []uint{plain.ID}},
+	}
+	for _, c := range cases {
+		matches, err := SimilarFiles(a.ID, alice.ID, c.pref, NearDuplicateDistance)
+		if err != nil {
+			t.Fatalf("%s: %v", c.pref, err)
+		}
+		var got []uint
+		for _, m := range matches {
+			got = append(got, m.FileID)
+		}
+		if !slices.Equal(got, c.want) {
+			t.Errorf("%s: similar = %v, want %v", c.pref, got, c.want)
+		}
+	}
+
+	// 被标记的图片本身在 hide 偏好下不可查询，上传者本人除外
+	if _, err := SimilarFiles(flagged.ID, alice.ID, model.NSFWHide, NearDuplicateDistance); !errors.Is(err, ErrNSFWHidden) {
+		t.Errorf("hidden source: err = %v, want ErrNSFWHidden", err)
+	}
+	if _, err := SimilarFiles(flagged.ID, bob.ID, model.NSFWHide, NearDuplicateDistance); err != nil {
+		t.Errorf("uploader querying own file: %v", err)
+	}
+	if _, err := SimilarFiles(trashed.ID, bob.ID, model.NSFWShow, NearDuplicateDistance); err == nil {
+		t.Error("trashed source file was queryable")
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LikePrompt 点赞，重复点赞不会重复计数，返回最新点赞数
+func LikePrompt(userID, promptID uint) (int64, error) {
+	return togglePromptMark(&model.PromptLike{UserID: userID, PromptID: promptID}, userID, promptID, "like_count", model.NotifyLike, true)
+}
+
+// UnlikePrompt 取消点赞，未点赞时不做任何修改，返回最新点赞数
+func UnlikePrompt(userID, promptID uint) (int64, error) {
+	return togglePromptMark(&model.PromptLike{UserID: userID, PromptID: promptID}, userID, promptID, "like_count", model.NotifyLike, false)
+}
+
+// FavoritePrompt 收藏，重复收藏不会重复计数，返回最新收藏数
+func FavoritePrompt(userID, promptID uint) (int64, error) {
+	return togglePromptMark(&model.PromptFavorite{UserID: userID, PromptID: promptID}, userID, promptID, "fav_count", model.NotifyFavorite, true)
+}
+
+// UnfavoritePrompt 取消收藏，未收藏时不做任何修改，返回最新收藏数
+func UnfavoritePrompt(userID, promptID uint) (int64, error) {
+	return togglePromptMark(&model.PromptFavorite{UserID: userID, PromptID: promptID}, userID, promptID, "fav_count", model.NotifyFavorite, false)
+}
+
+// togglePromptMark 在同一事务中写入/删除标记记录并维护 prompt 上的计数列
+// mark 必须是 *model.PromptLike 或 *model.PromptFavorite；状态变化后通知或撤回通知 prompt 作者
+func togglePromptMark(mark interface{}, userID, promptID uint, column, kind string, on bool) (int64, error) {
+	var count int64
+	var p model.Prompt
+	changed := false
+	if on {
+		if err := CheckPromptVisible(promptID, userID); err != nil {
+			return 0, err
+		}
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id", "user_id").First(&p, promptID).Error; err != nil {
+			return err
+		}
+
+		var res *gorm.DB
+		if on {
+			res = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(mark)
+		} else {
+			res = tx.Where("user_id = ? AND prompt_id = ?", userID, promptID).Delete(mark)
+		}
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected > 0 {
+			changed = true
+			delta := 1
+			if !on {
+				delta = -1
+			}
+			if err := tx.Model(&model.Prompt{}).Where("id = ?", promptID).
+				UpdateColumn(column, gorm.Expr(column+" + ?", delta)).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&model.Prompt{}).Where("id = ?", promptID).Pluck(column, &count).Error
+	})
+	if err != nil || !changed {
+		return count, err
+	}
+	publishPrompt(EventPromptCounts, promptID, map[string]interface{}{"id": promptID, column: count})
+	e := notifyEvent{Type: kind, Recipient: p.UserID, ActorID: userID, PromptID: promptID}
+	if on {
+		notify(e)
+	} else {
+		retractNotification(e)
+	}
+	return count, nil
+}
+
+// FillUserMarks 为列表填充当前用户的点赞/收藏状态
+func FillUserMarks(userID uint, list []model.Prompt) error {
+	if userID == 0 || len(list) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(list))
+	for i, v := range list {
+		ids[i] = v.ID
+	}
+
+	var liked, faved []uint
+	if err := database.DB.Model(&model.PromptLike{}).
+		Where("user_id = ? AND prompt_id IN ?", userID, ids).
+		Pluck("prompt_id", &liked).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Model(&model.PromptFavorite{}).
+		Where("user_id = ? AND prompt_id IN ?", userID, ids).
+		Pluck("prompt_id", &faved).Error; err != nil {
+		return err
+	}
+
+	likedSet := make(map[uint]bool, len(liked))
+	for _, id := range liked {
+		likedSet[id] = true
+	}
+	favedSet := make(map[uint]bool, len(faved))
+	for _, id := range faved {
+		favedSet[id] = true
+	}
+	for i := range list {
+		list[i].LikedByMe = likedSet[list[i].ID]
+		list[i].FavedByMe = favedSet[list[i].ID]
+	}
+	return nil
+}
+
+// ListFavoritePrompts 分页查询用户收藏的 prompt，按收藏时间倒序，nsfw 为用户的偏好
+func ListFavoritePrompts(userID uint, nsfw string, req PageRequest) ([]model.Prompt, PageInfo, error) {
+	db := hideNSFW(database.DB.Model(&model.PromptFavorite{}).
+		Joins("JOIN prompts ON prompts.id = prompt_favorites.prompt_id").
+		Where("prompt_favorites.user_id = ? AND prompts.status = ? AND prompts.deleted_at IS NULL", userID, model.StatusVisible), nsfw)
+	ks := keyset{scope: "favorites", columns: []string{"prompt_favorites.created_at desc", "prompt_favorites.id desc"},
+		selects: "prompt_favorites.*"}
+	favs, info, err := paginate(db, req, ks, func(f *model.PromptFavorite) []interface{} {
+		return []interface{}{f.CreatedAt, f.ID}
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	ids := make([]uint, len(favs))
+	for i := range favs {
+		ids[i] = favs[i].PromptID
+	}
+	var prompts []model.Prompt
+	if err := database.DB.Where("id IN ?", ids).Find(&prompts).Error; err != nil {
+		return nil, info, err
+	}
+	byID := make(map[uint]model.Prompt, len(prompts))
+	for _, p := range prompts {
+		byID[p.ID] = p
+	}
+	list := make([]model.Prompt, 0, len(favs))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			list = append(list, p)
+		}
+	}
+	return list, info, nil
+}
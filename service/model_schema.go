@@ -0,0 +1,274 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"prompt-share-backend/database"
+	"prompt-share-backend/utils"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 参数类型
+const (
+	ParamInteger = "integer"
+	ParamNumber  = "number"
+	ParamString  = "string"
+	ParamEnum    = "enum"
+	ParamBoolean = "boolean"
+)
+
+// ParamSpec 单个推荐参数的约束
+type ParamSpec struct {
+	Type        string   `json:"type"`
+	Min         *float64 `json:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty"`
+	MultipleOf  float64  `json:"multiple_of,omitempty"`
+	Options     []string `json:"options,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Description string   `json:"description,omitempty"`
+
+	pattern *regexp.Regexp // Pattern 编译结果，由 InitModelSchemas 填充
+}
+
+// ModelSchema 某一模型家族支持的推荐参数
+type ModelSchema struct {
+	Family      string               `json:"family"`
+	Name        string               `json:"name"`
+	Params      map[string]ParamSpec `json:"params"`
+	AllowExtras bool                 `json:"allow_extras"` // 是否允许未声明的参数
+}
+
+func bound(v float64) *float64 { return &v }
+
+const aspectRatioPattern = `^[1-9][0-9]{0,2}:[1-9][0-9]{0,2}$`
+
+var (
+	sdSamplers = []string{
+		"Euler", "Euler a", "Heun", "DPM2", "DPM2 a", "DPM++ 2S a", "DPM++ 2M", "DPM++ SDE",
+		"DPM++ 2M SDE", "DPM++ 2M Karras", "DPM++ SDE Karras", "DPM++ 2M SDE Karras",
+		"LMS", "DDIM", "PLMS", "UniPC", "LCM",
+	}
+
+	sdParams = map[string]ParamSpec{
+		"sampler":      {Type: ParamEnum, Options: sdSamplers},
+		"steps":        {Type: ParamInteger, Min: bound(1), Max: bound(150)},
+		"cfg_scale":    {Type: ParamNumber, Min: bound(1), Max: bound(30)},
+		"seed":         {Type: ParamInteger, Min: bound(-1), Max: bound(math.MaxUint32)},
+		"width":        {Type: ParamInteger, Min: bound(64), Max: bound(4096), MultipleOf: 8},
+		"height":       {Type: ParamInteger, Min: bound(64), Max: bound(4096), MultipleOf: 8},
+		"clip_skip":    {Type: ParamInteger, Min: bound(1), Max: bound(12)},
+		"denoise":      {Type: ParamNumber, Min: bound(0), Max: bound(1)},
+		"aspect_ratio": {Type: ParamString, Pattern: aspectRatioPattern},
+		"checkpoint":   {Type: ParamString},
+	}
+
+	// modelSchemas 已知模型家族，key 为 family
+	modelSchemas = map[string]ModelSchema{
+		"sd15": {Family: "sd15", Name: "Stable Diffusion 1.5", Params: sdParams},
+		"sdxl": {Family: "sdxl", Name: "Stable Diffusion XL", Params: sdParams},
+		"flux": {Family: "flux", Name: "FLUX", Params: map[string]ParamSpec{
+			"steps":        {Type: ParamInteger, Min: bound(1), Max: bound(100)},
+			"guidance":     {Type: ParamNumber, Min: bound(0), Max: bound(20)},
+			"seed":         {Type: ParamInteger, Min: bound(-1), Max: bound(math.MaxUint32)},
+			"width":        {Type: ParamInteger, Min: bound(64), Max: bound(4096), MultipleOf: 16},
+			"height":       {Type: ParamInteger, Min: bound(64), Max: bound(4096), MultipleOf: 16},
+			"aspect_ratio": {Type: ParamString, Pattern: aspectRatioPattern},
+			"sampler":      {Type: ParamString},
+		}},
+		"midjourney": {Family: "midjourney", Name: "Midjourney", Params: map[string]ParamSpec{
+			"version":      {Type: ParamString, Pattern: `^(niji )?[0-9]+(\.[0-9]+)?$`},
+			"aspect_ratio": {Type: ParamString, Pattern: aspectRatioPattern},
+			"stylize":      {Type: ParamInteger, Min: bound(0), Max: bound(1000)},
+			"chaos":        {Type: ParamInteger, Min: bound(0), Max: bound(100)},
+			"weird":        {Type: ParamInteger, Min: bound(0), Max: bound(3000)},
+			"quality":      {Type: ParamNumber, Min: bound(0.25), Max: bound(4)},
+			"seed":         {Type: ParamInteger, Min: bound(0), Max: bound(math.MaxUint32)},
+			"style":        {Type: ParamEnum, Options: []string{"raw", "cute", "expressive", "original", "scenic"}},
+			"tile":         {Type: ParamBoolean},
+		}},
+		"dall-e": {Family: "dall-e", Name: "DALL·E", Params: map[string]ParamSpec{
+			"size":    {Type: ParamEnum, Options: []string{"256x256", "512x512", "1024x1024", "1792x1024", "1024x1792"}},
+			"quality": {Type: ParamEnum, Options: []string{"standard", "hd"}},
+			"style":   {Type: ParamEnum, Options: []string{"vivid", "natural"}},
+		}},
+		"llm": {Family: "llm", Name: "Text / Chat LLM", Params: map[string]ParamSpec{
+			"temperature": {Type: ParamNumber, Min: bound(0), Max: bound(2)},
+			"top_p":       {Type: ParamNumber, Min: bound(0), Max: bound(1)},
+			"max_tokens":  {Type: ParamInteger, Min: bound(1), Max: bound(1000000)},
+			"model":       {Type: ParamString},
+		}},
+		"other": {Family: "other", Name: "Other", Params: map[string]ParamSpec{}, AllowExtras: true},
+	}
+
+	paramKeyRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+)
+
+const (
+	maxParamCount       = 30
+	maxParamStringRunes = 200
+)
+
+// InitModelSchemas 启动时编译所有参数的正则约束，非法的 pattern 直接报错而不是在校验请求时 panic
+func InitModelSchemas() error {
+	return compileModelSchemas(modelSchemas)
+}
+
+func compileModelSchemas(schemas map[string]ModelSchema) error {
+	for family, schema := range schemas {
+		for key, spec := range schema.Params {
+			if spec.Pattern == "" || spec.pattern != nil {
+				continue
+			}
+			re, err := regexp.Compile(spec.Pattern)
+			if err != nil {
+				return fmt.Errorf("model %s param %s: invalid pattern: %w", family, key, err)
+			}
+			spec.pattern = re
+			schema.Params[key] = spec
+		}
+	}
+	return nil
+}
+
+// MigrateModelParams 将历史数据中以字符串 "true"/"false" 保存的布尔参数转换为 JSON 布尔值，可重复执行
+func MigrateModelParams() error {
+	for family, schema := range modelSchemas {
+		for key, spec := range schema.Params {
+			if spec.Type != ParamBoolean {
+				continue
+			}
+			path := "$." + key
+			for _, table := range []string{"prompts", "prompt_revisions"} {
+				if err := database.DB.Exec(`UPDATE `+table+` SET params = json_set(params, ?, json(json_extract(params, ?)))
+					WHERE model_family = ? AND json_valid(params) AND json_type(params, ?) = 'text'
+					AND json_extract(params, ?) IN ('true', 'false')`, path, path, family, path, path).Error; err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ListModelSchemas 返回全部模型家族及其参数约束，按 family 排序
+func ListModelSchemas() []ModelSchema {
+	list := make([]ModelSchema, 0, len(modelSchemas))
+	for _, s := range modelSchemas {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Family < list[j].Family })
+	return list
+}
+
+// IsModelFamily 是否为已知模型家族
+func IsModelFamily(family string) bool {
+	_, ok := modelSchemas[family]
+	return ok
+}
+
+// IsParamKey 参数名是否合法，也用于拼接 JSON 路径前的校验
+func IsParamKey(key string) bool {
+	return paramKeyRe.MatchString(key)
+}
+
+// CheckModelParams 校验推荐参数是否符合模型家族的 schema
+func CheckModelParams(family string, params map[string]interface{}) utils.FieldErrors {
+	errs := utils.FieldErrors{}
+	if family == "" {
+		if len(params) > 0 {
+			errs.Add("model_family", "is required when params are set")
+		}
+		return errs
+	}
+	schema, ok := modelSchemas[family]
+	if !ok {
+		errs.Add("model_family", "unknown model family")
+		return errs
+	}
+	if len(params) > maxParamCount {
+		errs.Add("params", fmt.Sprintf("must have at most %d entries", maxParamCount))
+		return errs
+	}
+
+	for key, value := range params {
+		field := "params." + key
+		if !IsParamKey(key) {
+			errs.Add(field, "name must be lowercase letters, digits and underscores")
+			continue
+		}
+		spec, ok := schema.Params[key]
+		if !ok {
+			if !schema.AllowExtras {
+				errs.Add(field, fmt.Sprintf("is not supported by %s", schema.Name))
+				continue
+			}
+			// 自由参数只允许标量
+			switch v := value.(type) {
+			case string:
+				if len([]rune(v)) > maxParamStringRunes {
+					errs.Add(field, fmt.Sprintf("must be at most %d characters", maxParamStringRunes))
+				}
+			case float64, bool:
+			default:
+				errs.Add(field, "must be a string, number or boolean")
+			}
+			continue
+		}
+		if msg := checkParamValue(spec, value); msg != "" {
+			errs.Add(field, msg)
+		}
+	}
+	return errs
+}
+
+func checkParamValue(spec ParamSpec, value interface{}) string {
+	switch spec.Type {
+	case ParamInteger, ParamNumber:
+		f, ok := value.(float64)
+		if !ok {
+			return "must be a number"
+		}
+		if spec.Type == ParamInteger && f != math.Trunc(f) {
+			return "must be an integer"
+		}
+		if spec.Min != nil && f < *spec.Min {
+			return fmt.Sprintf("must be >= %v", *spec.Min)
+		}
+		if spec.Max != nil && f > *spec.Max {
+			return fmt.Sprintf("must be <= %v", *spec.Max)
+		}
+		if spec.MultipleOf > 0 && math.Mod(f, spec.MultipleOf) != 0 {
+			return fmt.Sprintf("must be a multiple of %v", spec.MultipleOf)
+		}
+	case ParamBoolean:
+		if _, ok := value.(bool); !ok {
+			return "must be a boolean"
+		}
+	case ParamEnum:
+		s, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		for _, o := range spec.Options {
+			if o == s {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(spec.Options, ", ")
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		if len([]rune(s)) > maxParamStringRunes {
+			return fmt.Sprintf("must be at most %d characters", maxParamStringRunes)
+		}
+		// 未编译的 pattern 按不匹配处理，不放过未校验的值
+		if spec.Pattern != "" && (spec.pattern == nil || !spec.pattern.MatchString(s)) {
+			return "has an invalid format"
+		}
+	}
+	return ""
+}
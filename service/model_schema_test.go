@@ -0,0 +1,85 @@
+package service
+
+import (
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"strings"
+	"testing"
+)
+
+func TestCheckModelParams(t *testing.T) {
+	if err := InitModelSchemas(); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		family string
+		params map[string]interface{}
+		errs   map[string]string // 期望的字段错误，空表示通过
+	}{
+		{"sdxl", map[string]interface{}{"steps": 30.0, "sampler": "Euler a", "aspect_ratio": "16:9"}, nil},
+		{"sdxl", map[string]interface{}{"aspect_ratio": "16x9"}, map[string]string{"params.aspect_ratio": "has an invalid format"}},
+		{"sdxl", map[string]interface{}{"aspect_ratio": 1.5}, map[string]string{"params.aspect_ratio": "must be a string"}},
+		{"sdxl", map[string]interface{}{"steps": 30.5}, map[string]string{"params.steps": "must be an integer"}},
+		{"sdxl", map[string]interface{}{"width": 1004.0}, map[string]string{"params.width": "must be a multiple of 8"}},
+		{"midjourney", map[string]interface{}{"version": "niji 6"}, nil},
+		{"midjourney", map[string]interface{}{"version": "6.1"}, nil},
+		{"midjourney", map[string]interface{}{"version": "v6"}, map[string]string{"params.version": "has an invalid format"}},
+		{"midjourney", map[string]interface{}{"tile": true}, nil},
+		{"midjourney", map[string]interface{}{"tile": "true"}, map[string]string{"params.tile": "must be a boolean"}},
+		{"other", map[string]interface{}{"anything": "x"}, nil},
+		{"", map[string]interface{}{"steps": 1.0}, map[string]string{"model_family": "is required when params are set"}},
+	}
+	for _, c := range cases {
+		errs := CheckModelParams(c.family, c.params)
+		if len(errs) != len(c.errs) {
+			t.Errorf("CheckModelParams(%q, %v) = %v, want %v", c.family, c.params, errs, c.errs)
+			continue
+		}
+		for field, msg := range c.errs {
+			if errs[field] != msg {
+				t.Errorf("CheckModelParams(%q, %v)[%s] = %q, want %q", c.family, c.params, field, errs[field], msg)
+			}
+		}
+	}
+}
+
+func TestCompileModelSchemasRejectsBadPattern(t *testing.T) {
+	schemas := map[string]ModelSchema{
+		"bad": {Family: "bad", Params: map[string]ParamSpec{"x": {Type: ParamString, Pattern: `^(unclosed$`}}},
+	}
+	err := compileModelSchemas(schemas)
+	if err == nil || !strings.Contains(err.Error(), "model bad param x") {
+		t.Fatalf("compileModelSchemas err = %v, want invalid pattern error", err)
+	}
+
+	// 未编译的 pattern 不放行
+	spec := ParamSpec{Type: ParamString, Pattern: `^a$`}
+	if msg := checkParamValue(spec, "a"); msg == "" {
+		t.Error("uncompiled pattern accepted the value")
+	}
+}
+
+func TestMigrateModelParams(t *testing.T) {
+	setupTestDB(t)
+	u := createTestUser(t, "alice")
+	legacy := &model.Prompt{UserID: u.ID, Title: "t", Content: "c", Version: 1, Status: model.StatusVisible,
+		ModelFamily: "midjourney", Params: map[string]interface{}{"tile": "true", "stylize": 100.0}}
+	if err := database.DB.Create(legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := MigrateModelParams(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var p model.Prompt
+	if err := database.DB.First(&p, legacy.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if p.Params["tile"] != true || p.Params["stylize"] != 100.0 {
+		t.Fatalf("params after migration = %#v", p.Params)
+	}
+	if errs := CheckModelParams(p.ModelFamily, p.Params); len(errs) > 0 {
+		t.Errorf("migrated params invalid: %v", errs)
+	}
+}
@@ -0,0 +1,599 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reportThreshold 同一内容的未处理举报达到该数量时自动转为待审，对他人隐藏
+const reportThreshold = 3
+
+// maxModerationNote 举报说明与处理备注的最大长度（字符）
+const maxModerationNote = 1000
+
+// canModerate 用户是否为版主或管理员，未登录返回 false
+func canModerate(userID uint) (bool, error) {
+	if userID == 0 {
+		return false, nil
+	}
+	var u model.User
+	if err := database.DB.Select("id", "role").First(&u, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return u.Role == model.RoleModerator || u.Role == model.RoleAdmin, nil
+}
+
+// CheckPromptVisible 校验当前用户能否看到该 prompt：公开的所有人可见，隐藏和待审的仅作者与版主可见
+// 不可见时与不存在一样返回 gorm.ErrRecordNotFound
+func CheckPromptVisible(id, viewerID uint) error {
+	var p model.Prompt
+	if err := database.DB.Select("id", "user_id", "status").First(&p, id).Error; err != nil {
+		return err
+	}
+	return checkVisible(p.Status, p.UserID, viewerID)
+}
+
+// GetVisiblePrompt 查询当前用户可见的 prompt，规则见 CheckPromptVisible
+func GetVisiblePrompt(id, viewerID uint) (*model.Prompt, error) {
+	p, err := GetPromptByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVisible(p.Status, p.UserID, viewerID); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func checkVisible(status string, authorID, viewerID uint) error {
+	if status == model.StatusVisible || (viewerID != 0 && viewerID == authorID) {
+		return nil
+	}
+	ok, err := canModerate(viewerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ReportInput 举报内容
+type ReportInput struct {
+	Reason string `json:"reason"` // spam、abuse、nsfw、illegal、copyright、other
+	Detail string `json:"detail"` // 补充说明，reason 为 other 时必填
+}
+
+func (in *ReportInput) validate() error {
+	errs := utils.FieldErrors{}
+	in.Detail = strings.TrimSpace(in.Detail)
+	if !slices.Contains(model.ReportReasons, in.Reason) {
+		errs.Add("reason", "must be one of "+strings.Join(model.ReportReasons, ", "))
+	}
+	if in.Reason == model.ReasonOther && in.Detail == "" {
+		errs.Add("detail", "is required when reason is other")
+	}
+	if len([]rune(in.Detail)) > maxModerationNote {
+		errs.Add("detail", fmt.Sprintf("must be at most %d characters", maxModerationNote))
+	}
+	return errs.Err()
+}
+
+// ReportPrompt 举报 prompt，重复举报时更新原因并重新打开
+func ReportPrompt(promptID, userID uint, in ReportInput) (*model.Report, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	if err := CheckPromptVisible(promptID, userID); err != nil {
+		return nil, err
+	}
+	return fileReport(model.TargetPrompt, promptID, userID, in)
+}
+
+// ReportComment 举报评论，重复举报时更新原因并重新打开
+func ReportComment(commentID, userID uint, in ReportInput) (*model.Report, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	var c model.Comment
+	if err := database.DB.Where("deleted_at IS NULL").First(&c, commentID).Error; err != nil {
+		return nil, err
+	}
+	if err := checkVisible(c.Status, c.UserID, userID); err != nil {
+		return nil, err
+	}
+	if err := CheckPromptVisible(c.PromptID, userID); err != nil {
+		return nil, err
+	}
+	return fileReport(model.TargetComment, commentID, userID, in)
+}
+
+// fileReport 写入举报，未处理举报达到阈值时将公开内容转为待审
+func fileReport(targetType string, targetID, userID uint, in ReportInput) (*model.Report, error) {
+	r := model.Report{TargetType: targetType, TargetID: targetID, ReporterID: userID,
+		Reason: in.Reason, Detail: in.Detail, Status: model.ReportOpen}
+	var change *statusChange
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := upsertReport(tx, &r); err != nil {
+			return err
+		}
+		var open int64
+		if err := tx.Model(&model.Report{}).Where("target_type = ? AND target_id = ? AND status = ?",
+			targetType, targetID, model.ReportOpen).Count(&open).Error; err != nil {
+			return err
+		}
+		if open < reportThreshold {
+			return nil
+		}
+		var err error
+		change, err = setContentStatus(tx, targetType, targetID, model.StatusPending, model.StatusVisible)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	change.publish()
+	return &r, nil
+}
+
+// upsertReport 写入举报，同一举报人对同一内容已有举报时更新原因并重新打开
+func upsertReport(tx *gorm.DB, r *model.Report) error {
+	if err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "target_type"}, {Name: "target_id"}, {Name: "reporter_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"reason": r.Reason, "detail": r.Detail, "status": model.ReportOpen,
+			"resolved_by": 0, "resolved_at": nil, "resolution": "", "updated_at": time.Now(),
+		}),
+	}).Create(r).Error; err != nil {
+		return err
+	}
+	return tx.Where("target_type = ? AND target_id = ? AND reporter_id = ?", r.TargetType, r.TargetID, r.ReporterID).
+		First(r).Error
+}
+
+// statusChange 一次内容状态变化，用于事务提交后推送事件
+type statusChange struct {
+	targetType string
+	id         uint
+	promptID   uint
+	status     string
+	count      *int64 // 评论数有变化时为 prompt 的最新评论数
+}
+
+func (s *statusChange) publish() {
+	if s == nil {
+		return
+	}
+	if s.targetType == model.TargetPrompt {
+		publishPrompt(EventPromptStatus, s.id, map[string]interface{}{"id": s.id, "status": s.status})
+		return
+	}
+	publish(EventCommentStatus, map[string]interface{}{"id": s.id, "prompt_id": s.promptID, "status": s.status},
+		PromptTopic(s.promptID))
+	if s.count != nil {
+		publishPrompt(EventPromptCounts, s.promptID, map[string]interface{}{"id": s.promptID, "comment_count": *s.count})
+	}
+}
+
+// setContentStatus 修改 prompt 或评论的状态，from 非空时只修改处于这些状态的内容
+// 评论在公开与非公开之间切换时同步调整 prompt 的评论数；状态未变化时返回 nil
+func setContentStatus(tx *gorm.DB, targetType string, id uint, status string, from ...string) (*statusChange, error) {
+	var table interface{}
+	var current string
+	var promptID uint
+	switch targetType {
+	case model.TargetPrompt:
+		var p model.Prompt
+		if err := tx.Select("id", "status").First(&p, id).Error; err != nil {
+			return nil, err
+		}
+		table, current, promptID = &model.Prompt{}, p.Status, p.ID
+	default:
+		var c model.Comment
+		if err := tx.Select("id", "prompt_id", "status", "deleted_at").First(&c, id).Error; err != nil {
+			return nil, err
+		}
+		if c.DeletedAt != nil {
+			return nil, nil
+		}
+		table, current, promptID = &model.Comment{}, c.Status, c.PromptID
+	}
+	if current == status || (len(from) > 0 && !slices.Contains(from, current)) {
+		return nil, nil
+	}
+	if err := tx.Model(table).Where("id = ?", id).UpdateColumn("status", status).Error; err != nil {
+		return nil, err
+	}
+	change := &statusChange{targetType: targetType, id: id, promptID: promptID, status: status}
+	if targetType == model.TargetComment && (current == model.StatusVisible) != (status == model.StatusVisible) {
+		delta := 1
+		if status != model.StatusVisible {
+			delta = -1
+		}
+		change.count = new(int64)
+		if err := changeCommentCount(tx, promptID, delta, change.count); err != nil {
+			return nil, err
+		}
+	}
+	return change, nil
+}
+
+// ModerateInput 版主对内容的处理
+type ModerateInput struct {
+	TargetType string `json:"target_type"` // prompt 或 comment
+	TargetID   uint   `json:"target_id"`
+	Action     string `json:"action"` // hide、restore、delete、warn、dismiss
+	Reason     string `json:"reason"` // 可选，取值同举报原因
+	Note       string `json:"note"`   // 处理说明，警告时会展示给作者
+}
+
+func (in *ModerateInput) validate() error {
+	errs := utils.FieldErrors{}
+	in.Note = strings.TrimSpace(in.Note)
+	if in.TargetType != model.TargetPrompt && in.TargetType != model.TargetComment {
+		errs.Add("target_type", "must be prompt or comment")
+	}
+	if in.TargetID == 0 {
+		errs.Add("target_id", "is required")
+	}
+	if !slices.Contains(model.ModerationActions, in.Action) {
+		errs.Add("action", "must be one of "+strings.Join(model.ModerationActions, ", "))
+	}
+	if in.Reason != "" && !slices.Contains(model.ReportReasons, in.Reason) {
+		errs.Add("reason", "must be one of "+strings.Join(model.ReportReasons, ", "))
+	}
+	if len([]rune(in.Note)) > maxModerationNote {
+		errs.Add("note", fmt.Sprintf("must be at most %d characters", maxModerationNote))
+	}
+	return errs.Err()
+}
+
+// Moderate 执行版主操作，关闭该内容全部未处理的举报并记录操作
+func Moderate(moderatorID uint, in ModerateInput) (*model.ModerationAction, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	action := model.ModerationAction{ModeratorID: moderatorID, TargetType: in.TargetType, TargetID: in.TargetID,
+		Action: in.Action, Reason: in.Reason, Note: in.Note}
+	var change *statusChange
+	var deletedPrompt model.Prompt
+	var deletedComment *model.Comment
+	var commentCount int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if action.TargetUserID, err = contentAuthor(tx, in.TargetType, in.TargetID); err != nil {
+			return err
+		}
+		switch in.Action {
+		case model.ModerationHide:
+			change, err = setContentStatus(tx, in.TargetType, in.TargetID, model.StatusHidden)
+		case model.ModerationRestore:
+			change, err = setContentStatus(tx, in.TargetType, in.TargetID, model.StatusVisible)
+		case model.ModerationDismiss:
+			change, err = setContentStatus(tx, in.TargetType, in.TargetID, model.StatusVisible, model.StatusPending)
+		case model.ModerationDelete:
+			if in.TargetType == model.TargetPrompt {
+				deletedPrompt, err = deletePromptTx(tx, in.TargetID, moderatorID)
+			} else {
+				deletedComment, err = removeCommentTx(tx, in.TargetID, moderatorID, &commentCount)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		resolution := model.ReportResolved
+		if in.Action == model.ModerationDismiss || in.Action == model.ModerationRestore {
+			resolution = model.ReportDismissed
+		}
+		res := tx.Model(&model.Report{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", in.TargetType, in.TargetID, model.ReportOpen).
+			Updates(map[string]interface{}{"status": resolution, "resolved_by": moderatorID,
+				"resolved_at": time.Now(), "resolution": in.Action})
+		if res.Error != nil {
+			return res.Error
+		}
+		action.Reports = res.RowsAffected
+		return tx.Create(&action).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	change.publish()
+	if in.Action == model.ModerationDelete && in.TargetType == model.TargetPrompt {
+		promptDeleted(in.TargetID, deletedPrompt)
+	}
+	if deletedComment != nil {
+		commentRemoved(deletedComment, commentCount)
+	}
+	if action.TargetUserID != 0 && in.Action != model.ModerationDismiss {
+		publish(EventModeration, authorView(action), UserTopic(action.TargetUserID))
+	}
+	return &action, nil
+}
+
+// contentAuthor 查询被处理内容的作者，内容不存在时返回 gorm.ErrRecordNotFound
+func contentAuthor(tx *gorm.DB, targetType string, id uint) (uint, error) {
+	if targetType == model.TargetPrompt {
+		var p model.Prompt
+		err := tx.Select("id", "user_id").First(&p, id).Error
+		return p.UserID, err
+	}
+	var c model.Comment
+	err := tx.Select("id", "user_id").Where("deleted_at IS NULL").First(&c, id).Error
+	return c.UserID, err
+}
+
+// authorView 展示给作者的处理记录，不暴露处理人
+func authorView(a model.ModerationAction) model.ModerationAction {
+	a.ModeratorID, a.Moderator = 0, nil
+	return a
+}
+
+// ReportFilter 举报列表筛选条件
+type ReportFilter struct {
+	Status     string // 为空表示全部
+	TargetType string
+	TargetID   uint
+	Reason     string
+}
+
+// ListReports 分页查询举报，新的在前，附带举报人与举报对象
+func ListReports(moderatorID uint, f ReportFilter, req PageRequest) ([]model.Report, PageInfo, error) {
+	errs := utils.FieldErrors{}
+	checkOneOf(errs, "status", f.Status, model.ReportOpen, model.ReportResolved, model.ReportDismissed)
+	checkOneOf(errs, "target_type", f.TargetType, model.TargetPrompt, model.TargetComment)
+	checkOneOf(errs, "reason", f.Reason, model.ReportReasons...)
+	if err := errs.Err(); err != nil {
+		return nil, PageInfo{}, err
+	}
+	db := database.DB.Model(&model.Report{})
+	if f.Status != "" {
+		db = db.Where("status = ?", f.Status)
+	}
+	if f.TargetType != "" {
+		db = db.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != 0 {
+		db = db.Where("target_id = ?", f.TargetID)
+	}
+	if f.Reason != "" {
+		db = db.Where("reason = ?", f.Reason)
+	}
+	ks := keyset{scope: "reports", columns: []string{"created_at desc", "id desc"}}
+	list, info, err := paginate(db, req, ks, func(r *model.Report) []interface{} {
+		return []interface{}{r.CreatedAt, r.ID}
+	})
+	if err != nil || len(list) == 0 {
+		return list, info, err
+	}
+	reporterIDs := make([]uint, len(list))
+	targets := make([]moderationTarget, len(list))
+	for i, r := range list {
+		reporterIDs[i] = r.ReporterID
+		targets[i] = moderationTarget{Type: r.TargetType, ID: r.TargetID}
+	}
+	users, err := userBriefs(reporterIDs)
+	if err != nil {
+		return nil, info, err
+	}
+	prompts, comments, err := loadModerationTargets(targets, moderatorID)
+	if err != nil {
+		return nil, info, err
+	}
+	for i := range list {
+		list[i].Reporter = users[list[i].ReporterID]
+		if list[i].TargetType == model.TargetPrompt {
+			list[i].Prompt = prompts[list[i].TargetID]
+		} else {
+			list[i].Comment = comments[list[i].TargetID]
+		}
+	}
+	return list, info, nil
+}
+
+// QueueItem 审核队列中的一项：一个有未处理举报的内容
+type QueueItem struct {
+	TargetType    string           `json:"target_type"`
+	TargetID      uint             `json:"target_id"`
+	ReportCount   int64            `json:"report_count"`
+	FirstReportID uint             `json:"first_report_id"`
+	Reasons       map[string]int64 `gorm:"-" json:"reasons"` // 各原因的举报数
+	Prompt        *model.Prompt    `gorm:"-" json:"prompt,omitempty"`
+	Comment       *model.Comment   `gorm:"-" json:"comment,omitempty"`
+}
+
+// moderationTarget 被举报或处理的内容
+type moderationTarget struct {
+	Type string
+	ID   uint
+}
+
+// ModerationQueue 按内容聚合未处理的举报，举报多的在前，同数量时先举报的在前
+// reason 非空时只包含有该原因举报的内容
+func ModerationQueue(moderatorID uint, targetType, reason string, req PageRequest) ([]QueueItem, PageInfo, error) {
+	errs := utils.FieldErrors{}
+	checkOneOf(errs, "target_type", targetType, model.TargetPrompt, model.TargetComment)
+	checkOneOf(errs, "reason", reason, model.ReportReasons...)
+	if err := errs.Err(); err != nil {
+		return nil, PageInfo{}, err
+	}
+	open := database.DB.Model(&model.Report{}).
+		Select("target_type, target_id, count(*) AS report_count, min(id) AS first_report_id").
+		Where("status = ?", model.ReportOpen).Group("target_type, target_id")
+	if targetType != "" {
+		open = open.Where("target_type = ?", targetType)
+	}
+	if reason != "" {
+		open = open.Having("sum(reason = ?) > 0", reason)
+	}
+	db := database.DB.Table("(?) AS queue", open)
+	ks := keyset{scope: "moderation_queue", columns: []string{"report_count desc", "first_report_id asc"}}
+	list, info, err := paginate(db, req, ks, func(q *QueueItem) []interface{} {
+		return []interface{}{q.ReportCount, q.FirstReportID}
+	})
+	if err != nil || len(list) == 0 {
+		return list, info, err
+	}
+
+	targets := make([]moderationTarget, len(list))
+	for i, q := range list {
+		targets[i] = moderationTarget{Type: q.TargetType, ID: q.TargetID}
+	}
+	prompts, comments, err := loadModerationTargets(targets, moderatorID)
+	if err != nil {
+		return nil, info, err
+	}
+	var rows []struct {
+		TargetType string
+		TargetID   uint
+		Reason     string
+		Count      int64
+	}
+	cond := database.DB.Where("1 = 0")
+	for _, t := range targets {
+		cond = cond.Or("target_type = ? AND target_id = ?", t.Type, t.ID)
+	}
+	if err := database.DB.Model(&model.Report{}).Select("target_type, target_id, reason, count(*) AS count").
+		Where("status = ?", model.ReportOpen).Where(cond).
+		Group("target_type, target_id, reason").Scan(&rows).Error; err != nil {
+		return nil, info, err
+	}
+	reasons := make(map[moderationTarget]map[string]int64, len(list))
+	for _, r := range rows {
+		t := moderationTarget{Type: r.TargetType, ID: r.TargetID}
+		if reasons[t] == nil {
+			reasons[t] = map[string]int64{}
+		}
+		reasons[t][r.Reason] = r.Count
+	}
+	for i := range list {
+		list[i].Reasons = reasons[targets[i]]
+		if list[i].TargetType == model.TargetPrompt {
+			list[i].Prompt = prompts[list[i].TargetID]
+		} else {
+			list[i].Comment = comments[list[i].TargetID]
+		}
+	}
+	return list, info, nil
+}
+
+// loadModerationTargets 批量查询举报对象，评论以版主视角填充（可见隐藏内容）
+func loadModerationTargets(targets []moderationTarget, moderatorID uint) (map[uint]*model.Prompt, map[uint]*model.Comment, error) {
+	var promptIDs, commentIDs []uint
+	for _, t := range targets {
+		if t.Type == model.TargetPrompt {
+			promptIDs = append(promptIDs, t.ID)
+		} else {
+			commentIDs = append(commentIDs, t.ID)
+		}
+	}
+	prompts := make(map[uint]*model.Prompt, len(promptIDs))
+	comments := make(map[uint]*model.Comment, len(commentIDs))
+	if len(promptIDs) > 0 {
+		var list []model.Prompt
+		if err := database.DB.Where("id IN ?", uniqueIDs(promptIDs)).Find(&list).Error; err != nil {
+			return nil, nil, err
+		}
+		for i := range list {
+			prompts[list[i].ID] = &list[i]
+		}
+	}
+	if len(commentIDs) > 0 {
+		var list []model.Comment
+		if err := database.DB.Where("id IN ? AND deleted_at IS NULL", uniqueIDs(commentIDs)).Find(&list).Error; err != nil {
+			return nil, nil, err
+		}
+		ptrs := make([]*model.Comment, len(list))
+		for i := range list {
+			ptrs[i] = &list[i]
+			comments[list[i].ID] = &list[i]
+		}
+		if err := prepareComments(ptrs, moderatorID); err != nil {
+			return nil, nil, err
+		}
+	}
+	return prompts, comments, nil
+}
+
+// checkOneOf 非空的筛选值必须为 allowed 之一
+func checkOneOf(errs utils.FieldErrors, field, value string, allowed ...string) {
+	if value != "" && !slices.Contains(allowed, value) {
+		errs.Add(field, "must be one of "+strings.Join(allowed, ", "))
+	}
+}
+
+// ModerationActionFilter 操作记录筛选条件
+type ModerationActionFilter struct {
+	TargetType   string
+	TargetID     uint
+	ModeratorID  uint
+	TargetUserID uint
+	Action       string
+}
+
+// ListModerationActions 分页查询版主操作记录，新的在前
+func ListModerationActions(f ModerationActionFilter, req PageRequest) ([]model.ModerationAction, PageInfo, error) {
+	db := database.DB.Model(&model.ModerationAction{})
+	if f.TargetType != "" {
+		db = db.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != 0 {
+		db = db.Where("target_id = ?", f.TargetID)
+	}
+	if f.ModeratorID != 0 {
+		db = db.Where("moderator_id = ?", f.ModeratorID)
+	}
+	if f.TargetUserID != 0 {
+		db = db.Where("target_user_id = ?", f.TargetUserID)
+	}
+	if f.Action != "" {
+		db = db.Where("action = ?", f.Action)
+	}
+	ks := keyset{scope: "moderation_actions", columns: []string{"created_at desc", "id desc"}}
+	list, info, err := paginate(db, req, ks, func(a *model.ModerationAction) []interface{} {
+		return []interface{}{a.CreatedAt, a.ID}
+	})
+	if err != nil || len(list) == 0 {
+		return list, info, err
+	}
+	ids := make([]uint, len(list))
+	for i, a := range list {
+		ids[i] = a.ModeratorID
+	}
+	users, err := userBriefs(ids)
+	if err != nil {
+		return nil, info, err
+	}
+	for i := range list {
+		list[i].Moderator = users[list[i].ModeratorID]
+	}
+	return list, info, nil
+}
+
+// ListMyModerationActions 分页查询针对用户内容的处理记录（隐藏、删除、警告等），不含处理人
+func ListMyModerationActions(userID uint, req PageRequest) ([]model.ModerationAction, PageInfo, error) {
+	db := database.DB.Model(&model.ModerationAction{}).
+		Where("target_user_id = ? AND action <> ?", userID, model.ModerationDismiss)
+	ks := keyset{scope: "my_moderation_actions", columns: []string{"created_at desc", "id desc"}}
+	list, info, err := paginate(db, req, ks, func(a *model.ModerationAction) []interface{} {
+		return []interface{}{a.CreatedAt, a.ID}
+	})
+	for i := range list {
+		list[i] = authorView(list[i])
+	}
+	return list, info, err
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// notificationActorsShown 每条通知返回的最近触发者数
+const notificationActorsShown = 3
+
+// notifyEvent 一次需要通知的事件
+type notifyEvent struct {
+	Type      string
+	Recipient uint
+	ActorID   uint
+	PromptID  uint
+	CommentID uint
+}
+
+// notify 在产生事件的事务提交后调用，写入或合并通知；失败只记录日志，不影响主流程
+func notify(events ...notifyEvent) {
+	for _, e := range events {
+		if err := saveNotification(e); err != nil {
+			log.Printf("save %s notification for user %d failed: %v", e.Type, e.Recipient, err)
+		}
+	}
+}
+
+// retractNotification 从未读通知中移除该触发者（如取消点赞），没有其他触发者时删除整条通知
+func retractNotification(e notifyEvent) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		n, err := unreadNotification(tx, e)
+		if err != nil || n == nil {
+			return err
+		}
+		if err := tx.Where("notification_id = ? AND actor_id = ?", n.ID, e.ActorID).
+			Delete(&model.NotificationActor{}).Error; err != nil {
+			return err
+		}
+		var last model.NotificationActor
+		err = tx.Where("notification_id = ?", n.ID).Order("updated_at desc, id desc").First(&last).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Delete(n).Error
+		}
+		if err != nil {
+			return err
+		}
+		return refreshNotification(tx, n, last.ActorID, last.CommentID, false)
+	})
+	if err != nil {
+		log.Printf("retract %s notification for user %d failed: %v", e.Type, e.Recipient, err)
+	}
+}
+
+func saveNotification(e notifyEvent) error {
+	if e.Recipient == 0 || e.Recipient == e.ActorID {
+		return nil
+	}
+	muted, err := mutedNotifications(e.Recipient)
+	if err != nil {
+		return err
+	}
+	if muted[e.Type] {
+		return nil
+	}
+	var id uint
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		n, err := unreadNotification(tx, e)
+		if err != nil {
+			return err
+		}
+		if n == nil {
+			n = &model.Notification{UserID: e.Recipient, Type: e.Type, PromptID: e.PromptID,
+				CommentID: e.CommentID, ActorID: e.ActorID, ActorCount: 1}
+			if err := tx.Create(n).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "notification_id"}, {Name: "actor_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"comment_id", "updated_at"}),
+		}).Create(&model.NotificationActor{NotificationID: n.ID, ActorID: e.ActorID, CommentID: e.CommentID}).Error; err != nil {
+			return err
+		}
+		id = n.ID
+		return refreshNotification(tx, n, e.ActorID, e.CommentID, true)
+	})
+	if err != nil {
+		return err
+	}
+	return publishNotification(e.Recipient, id)
+}
+
+// publishNotification 推送最新的通知内容和未读数到接收者的私有频道
+func publishNotification(userID, id uint) error {
+	var n model.Notification
+	if err := database.DB.First(&n, id).Error; err != nil {
+		return err
+	}
+	list := []model.Notification{n}
+	if err := fillNotifications(list); err != nil {
+		return err
+	}
+	unread, err := CountUnreadNotifications(userID)
+	if err != nil {
+		return err
+	}
+	publish(EventNotification, map[string]interface{}{"notification": list[0], "unread": unread}, UserTopic(userID))
+	return nil
+}
+
+// unreadNotification 查询可合并的未读通知，没有时返回 nil
+func unreadNotification(tx *gorm.DB, e notifyEvent) (*model.Notification, error) {
+	var n model.Notification
+	err := tx.Where("user_id = ? AND type = ? AND prompt_id = ? AND read_at IS NULL", e.Recipient, e.Type, e.PromptID).
+		Order("id desc").First(&n).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// refreshNotification 重新统计触发者数并记录最近的触发者，bump 为 true 时通知移到列表最前
+func refreshNotification(tx *gorm.DB, n *model.Notification, actorID, commentID uint, bump bool) error {
+	var count int64
+	if err := tx.Model(&model.NotificationActor{}).Where("notification_id = ?", n.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	updates := map[string]interface{}{"actor_id": actorID, "comment_id": commentID, "actor_count": count}
+	if bump {
+		updates["updated_at"] = time.Now()
+	}
+	return tx.Model(n).UpdateColumns(updates).Error
+}
+
+// NotificationFilter 通知列表筛选条件
+type NotificationFilter struct {
+	UnreadOnly bool
+	Type       string
+}
+
+// ListNotifications 分页查询用户的通知，最近更新的在前
+func ListNotifications(userID uint, f NotificationFilter, req PageRequest) ([]model.Notification, PageInfo, error) {
+	if err := checkNotificationType(f.Type); err != nil {
+		return nil, PageInfo{}, err
+	}
+	db := database.DB.Model(&model.Notification{}).Where("user_id = ?", userID)
+	if f.UnreadOnly {
+		db = db.Where("read_at IS NULL")
+	}
+	if f.Type != "" {
+		db = db.Where("type = ?", f.Type)
+	}
+	ks := keyset{scope: "notifications", columns: []string{"updated_at desc", "id desc"}}
+	list, info, err := paginate(db, req, ks, func(n *model.Notification) []interface{} {
+		return []interface{}{n.UpdatedAt, n.ID}
+	})
+	if err != nil || len(list) == 0 {
+		return list, info, err
+	}
+	return list, info, fillNotifications(list)
+}
+
+// fillNotifications 填充最近的触发者与 prompt 标题
+func fillNotifications(list []model.Notification) error {
+	ids := make([]uint, len(list))
+	promptIDs := make([]uint, 0, len(list))
+	for i, n := range list {
+		ids[i] = n.ID
+		promptIDs = append(promptIDs, n.PromptID)
+	}
+	var actors []model.NotificationActor
+	if err := database.DB.Raw(`SELECT * FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY notification_id ORDER BY updated_at DESC, id DESC) AS rn
+			FROM notification_actors WHERE notification_id IN ?
+		) WHERE rn <= ? ORDER BY updated_at DESC, id DESC`, ids, notificationActorsShown).Scan(&actors).Error; err != nil {
+		return err
+	}
+	actorIDs := make([]uint, len(actors))
+	for i, a := range actors {
+		actorIDs[i] = a.ActorID
+	}
+	users, err := userBriefs(actorIDs)
+	if err != nil {
+		return err
+	}
+	var prompts []model.Prompt
+	if err := database.DB.Select("id", "title").Where("id IN ?", uniqueIDs(promptIDs)).Find(&prompts).Error; err != nil {
+		return err
+	}
+	titles := make(map[uint]string, len(prompts))
+	for _, p := range prompts {
+		titles[p.ID] = p.Title
+	}
+	byNotification := make(map[uint][]model.UserBrief, len(list))
+	for _, a := range actors {
+		if u := users[a.ActorID]; u != nil {
+			byNotification[a.NotificationID] = append(byNotification[a.NotificationID], *u)
+		}
+	}
+	for i := range list {
+		list[i].Actors = byNotification[list[i].ID]
+		if list[i].Actors == nil {
+			list[i].Actors = []model.UserBrief{}
+		}
+		list[i].PromptTitle = titles[list[i].PromptID]
+	}
+	return nil
+}
+
+// UnreadCounts 未读通知数，总数和按类型
+type UnreadCounts struct {
+	Total  int64            `json:"total"`
+	ByType map[string]int64 `json:"by_type"`
+}
+
+// CountUnreadNotifications 统计用户的未读通知
+func CountUnreadNotifications(userID uint) (UnreadCounts, error) {
+	out := UnreadCounts{ByType: make(map[string]int64, len(model.NotificationTypes))}
+	for _, t := range model.NotificationTypes {
+		out.ByType[t] = 0
+	}
+	var rows []struct {
+		Type  string
+		Count int64
+	}
+	if err := database.DB.Model(&model.Notification{}).Select("type, count(*) AS count").
+		Where("user_id = ? AND read_at IS NULL", userID).Group("type").Scan(&rows).Error; err != nil {
+		return out, err
+	}
+	for _, r := range rows {
+		out.ByType[r.Type] = r.Count
+		out.Total += r.Count
+	}
+	return out, nil
+}
+
+// MarkNotificationRead 将一条通知标记为已读，已读的不做修改
+func MarkNotificationRead(userID, id uint) error {
+	var n model.Notification
+	if err := database.DB.Where("user_id = ?", userID).First(&n, id).Error; err != nil {
+		return err
+	}
+	return database.DB.Model(&n).Where("read_at IS NULL").UpdateColumn("read_at", time.Now()).Error
+}
+
+// MarkAllNotificationsRead 将用户的未读通知全部标记为已读，typ 非空时只处理该类型，返回处理条数
+func MarkAllNotificationsRead(userID uint, typ string) (int64, error) {
+	if err := checkNotificationType(typ); err != nil {
+		return 0, err
+	}
+	db := database.DB.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if typ != "" {
+		db = db.Where("type = ?", typ)
+	}
+	res := db.UpdateColumn("read_at", time.Now())
+	return res.RowsAffected, res.Error
+}
+
+func checkNotificationType(typ string) error {
+	if typ != "" && !slices.Contains(model.NotificationTypes, typ) {
+		return utils.FieldErrors{"type": "must be one of " + strings.Join(model.NotificationTypes, ", ")}
+	}
+	return nil
+}
+
+// mutedNotifications 用户关闭的通知类型
+func mutedNotifications(userID uint) (map[string]bool, error) {
+	var u model.User
+	if err := database.DB.Select("id", "muted_notifications").First(&u, userID).Error; err != nil {
+		return nil, err
+	}
+	muted := make(map[string]bool)
+	for _, t := range strings.Split(u.MutedNotifications, ",") {
+		if t != "" {
+			muted[t] = true
+		}
+	}
+	return muted, nil
+}
+
+// NotificationPreferences 返回各类型通知是否开启
+func NotificationPreferences(userID uint) (map[string]bool, error) {
+	muted, err := mutedNotifications(userID)
+	if err != nil {
+		return nil, err
+	}
+	prefs := make(map[string]bool, len(model.NotificationTypes))
+	for _, t := range model.NotificationTypes {
+		prefs[t] = !muted[t]
+	}
+	return prefs, nil
+}
+
+// UpdateNotificationPreferences 按类型开关通知，未列出的类型保持不变
+func UpdateNotificationPreferences(userID uint, changes map[string]bool) (map[string]bool, error) {
+	errs := utils.FieldErrors{}
+	for t := range changes {
+		if err := checkNotificationType(t); err != nil {
+			errs.Add(t, "unknown notification type")
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	prefs, err := NotificationPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	var muted []string
+	for _, t := range model.NotificationTypes {
+		if on, ok := changes[t]; ok {
+			prefs[t] = on
+		}
+		if !prefs[t] {
+			muted = append(muted, t)
+		}
+	}
+	if err := database.DB.Model(&model.User{}).Where("id = ?", userID).
+		UpdateColumn("muted_notifications", strings.Join(muted, ",")).Error; err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+	"prompt-share-backend/config"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// 配置缺省时的 nsfw 默认值
+const (
+	defaultAnonymousNSFW = model.NSFWHide
+	defaultUserNSFW      = model.NSFWBlur
+	defaultBlurSigma     = 20
+)
+
+// NSFWPreference 用户的 nsfw 偏好，未设置时取站点默认值；userID 为 0 时取匿名访客的默认值
+func NSFWPreference(userID uint) (string, error) {
+	if userID == 0 {
+		return nsfwDefault(config.Cfg.NSFW.AnonymousDefault, defaultAnonymousNSFW), nil
+	}
+	var u model.User
+	if err := database.DB.Select("id", "nsfw_preference").Limit(1).Find(&u, userID).Error; err != nil {
+		return "", err
+	}
+	if slices.Contains(model.NSFWPreferences, u.NSFWPreference) {
+		return u.NSFWPreference, nil
+	}
+	return nsfwDefault(config.Cfg.NSFW.UserDefault, defaultUserNSFW), nil
+}
+
+func nsfwDefault(v, fallback string) string {
+	if slices.Contains(model.NSFWPreferences, v) {
+		return v
+	}
+	return fallback
+}
+
+// hideNSFW hide 偏好下从 prompt 查询中排除被标记的 prompt
+func hideNSFW(db *gorm.DB, pref string) *gorm.DB {
+	if pref != model.NSFWHide {
+		return db
+	}
+	return db.Where("prompts.nsfw = ?", false)
+}
+
+// CheckNSFW hide 偏好下被标记的内容对作者以外的用户返回 ErrNSFWHidden
+func CheckNSFW(nsfw bool, authorID, viewerID uint, pref string) error {
+	if nsfw && pref == model.NSFWHide && (viewerID == 0 || viewerID != authorID) {
+		return ErrNSFWHidden
+	}
+	return nil
+}
+
+// CheckPromptNSFW 按浏览者偏好校验能否访问该 prompt，规则见 CheckNSFW
+func CheckPromptNSFW(id, viewerID uint, pref string) error {
+	if pref != model.NSFWHide {
+		return nil
+	}
+	var p model.Prompt
+	if err := database.DB.Select("id", "user_id", "nsfw").First(&p, id).Error; err != nil {
+		return err
+	}
+	return CheckNSFW(p.NSFW, p.UserID, viewerID, pref)
+}
+
+// ApplyNSFWPreference 按偏好处理已填充图片的 prompt 列表：hide 时去掉被标记的 prompt 和图片，其余偏好原样返回
+// blur 由客户端根据 nsfw 字段处理，缩略图在文件接口中模糊
+func ApplyNSFWPreference(pref string, list []model.Prompt) []model.Prompt {
+	if pref != model.NSFWHide {
+		return list
+	}
+	out := list[:0]
+	for _, p := range list {
+		if p.NSFW {
+			continue
+		}
+		p.Images = FilterNSFWImages(pref, p.Images)
+		out = append(out, p)
+	}
+	return out
+}
+
+// FilterNSFWImages hide 偏好下去掉被标记的图片
+func FilterNSFWImages(pref string, images []model.PromptImg) []model.PromptImg {
+	if pref != model.NSFWHide {
+		return images
+	}
+	return slices.DeleteFunc(images, func(img model.PromptImg) bool { return img.NSFW })
+}
+
+// nsfwFiles 被标记为 nsfw 的文件 id：引用它的图片或图片所属的 prompt 被标记
+func nsfwFiles() *gorm.DB {
+	return database.DB.Model(&model.PromptImg{}).Select("prompt_imgs.file_id").
+		Joins("LEFT JOIN prompts ON prompts.id = prompt_imgs.prompt_id").
+		Where("(prompt_imgs.nsfw = ? OR prompts.nsfw = ?)", true, true)
+}
+
+// NSFWFileView 按浏览者偏好决定文件如何输出
+// 返回 nsfw 表示文件被标记，blur 表示应输出模糊的缩略图；hide 偏好时返回 ErrNSFWHidden
+// 上传者本人总是看到原图，blur 偏好的登录用户可以通过 reveal 主动查看原图
+func NSFWFileView(f *model.File, viewerID uint, pref string, reveal bool) (nsfw, blur bool, err error) {
+	var n int64
+	if err := nsfwFiles().Where("prompt_imgs.file_id = ?", f.ID).Count(&n).Error; err != nil {
+		return false, false, err
+	}
+	if n == 0 || pref == model.NSFWShow || (viewerID != 0 && viewerID == f.UploaderID) {
+		return n > 0, false, nil
+	}
+	if pref == model.NSFWHide {
+		return true, false, ErrNSFWHidden
+	}
+	return true, !reveal || viewerID == 0, nil
+}
+
+// BlurredThumbnail 返回模糊后的 base64 缩略图，首次生成后保存
+func BlurredThumbnail(f *model.File) (string, error) {
+	if f.Blurred != "" {
+		return f.Blurred, nil
+	}
+	thumbnail := f.Thumbnail
+	if thumbnail == "" {
+		var err error
+		if thumbnail, err = GenThumbnail(f); err != nil {
+			return "", err
+		}
+	}
+	sigma := config.Cfg.NSFW.BlurSigma
+	if sigma <= 0 {
+		sigma = defaultBlurSigma
+	}
+	blurred, err := utils.BlurThumbnail(thumbnail, sigma, 80)
+	if err != nil {
+		return "", err
+	}
+	if err := database.DB.Model(&model.File{}).Where("id = ?", f.ID).UpdateColumn("blurred", blurred).Error; err != nil {
+		return "", err
+	}
+	f.Blurred = blurred
+	return blurred, nil
+}
+
+// applyFileNSFW 按偏好处理文件列表中被标记的文件：blur 时替换为模糊的缩略图
+func applyFileNSFW(pref string, list []model.File) error {
+	if pref != model.NSFWBlur || len(list) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(list))
+	for i := range list {
+		ids[i] = list[i].ID
+	}
+	var flagged []uint
+	if err := nsfwFiles().Where("prompt_imgs.file_id IN ?", ids).Pluck("prompt_imgs.file_id", &flagged).Error; err != nil {
+		return err
+	}
+	for i := range list {
+		if !slices.Contains(flagged, list[i].ID) {
+			continue
+		}
+		blurred, err := BlurredThumbnail(&list[i])
+		if err != nil {
+			// 无法生成时不输出原缩略图
+			blurred = ""
+		}
+		list[i].Thumbnail = blurred
+	}
+	return nil
+}
+
+// NSFWInput 修改 nsfw 标记，nil 或未列出的图片不修改
+type NSFWInput struct {
+	NSFW   *bool         `json:"nsfw"`   // prompt 整体
+	Images map[uint]bool `json:"images"` // 图片 id → 是否 nsfw
+}
+
+// SetPromptNSFW 作者或版主修改 prompt 及其图片的 nsfw 标记，不产生新版本
+// 版主为他人内容设置的标记会锁定，作者不能取消；版主取消标记时一并解锁
+func SetPromptNSFW(id, userID uint, in NSFWInput) (*model.Prompt, error) {
+	moderator, err := canModerate(userID)
+	if err != nil {
+		return nil, err
+	}
+	var p model.Prompt
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&p, id).Error; err != nil {
+			return err
+		}
+		if err := checkVisible(p.Status, p.UserID, userID); err != nil {
+			return err
+		}
+		if p.UserID != userID && !moderator {
+			return ErrForbidden
+		}
+		lock := moderator && p.UserID != userID
+		if in.NSFW != nil {
+			nsfw, locked, err := nextNSFW(*in.NSFW, p.NSFWLocked, lock, moderator)
+			if err != nil {
+				return err
+			}
+			p.NSFW, p.NSFWLocked = nsfw, locked
+			if err := tx.Model(&model.Prompt{}).Where("id = ?", p.ID).
+				UpdateColumns(map[string]interface{}{"nsfw": nsfw, "nsfw_locked": locked}).Error; err != nil {
+				return err
+			}
+		}
+		if len(in.Images) > 0 {
+			var images []model.PromptImg
+			if err := tx.Where("prompt_id = ? AND id IN ?", p.ID, slices.Collect(maps.Keys(in.Images))).
+				Find(&images).Error; err != nil {
+				return err
+			}
+			if len(images) != len(in.Images) {
+				return utils.FieldErrors{"images": "must only contain images of this prompt"}
+			}
+			for _, img := range images {
+				nsfw, locked, err := nextNSFW(in.Images[img.ID], img.NSFWLocked, lock, moderator)
+				if err != nil {
+					return fmt.Errorf("image %d: %w", img.ID, err)
+				}
+				if err := tx.Model(&model.PromptImg{}).Where("id = ?", img.ID).
+					UpdateColumns(map[string]interface{}{"nsfw": nsfw, "nsfw_locked": locked}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Where("prompt_id = ?", p.ID).Order("id asc").Find(&p.Images).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == model.StatusVisible {
+		publishPrompt(EventPromptUpdated, p.ID, &p)
+		queueWebhooks(model.WebhookPromptUpdated, &p, p.UserID)
+	}
+	return &p, nil
+}
+
+// nextNSFW 计算新的标记与锁定状态，被锁定的标记只有版主可以取消
+func nextNSFW(nsfw, locked, lock, moderator bool) (bool, bool, error) {
+	if !nsfw {
+		if locked && !moderator {
+			return false, false, fmt.Errorf("%w: marked nsfw by a moderator", ErrForbidden)
+		}
+		return false, false, nil
+	}
+	return true, locked || lock, nil
+}
+
+// keepImageNSFWLocks 重新保存 prompt 图片时保留版主对同一文件的锁定，客户端提交的 nsfw_locked 不生效
+func keepImageNSFWLocks(tx *gorm.DB, promptID uint, images []model.PromptImg) error {
+	var locked []uint
+	if err := tx.Model(&model.PromptImg{}).Where("prompt_id = ? AND nsfw_locked = ?", promptID, true).
+		Pluck("file_id", &locked).Error; err != nil {
+		return err
+	}
+	for i := range images {
+		images[i].NSFWLocked = slices.Contains(locked, images[i].FileId)
+		if images[i].NSFWLocked {
+			images[i].NSFW = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"fmt"
+	"prompt-share-backend/config"
+	"prompt-share-backend/utils"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 每页条数默认值与上限
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+)
+
+// PageRequest 列表分页参数
+// 优先使用 Cursor；没有游标时按 Page 兼容旧的页码分页
+type PageRequest struct {
+	Cursor    string
+	Page      int
+	Size      int
+	WithTotal bool
+}
+
+// PageInfo 列表分页结果，游标为空表示没有更多
+type PageInfo struct {
+	Total      *int64 `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor"`
+	PrevCursor string `json:"prev_cursor"`
+}
+
+// keyset 列表排序键，列格式为 "<column> asc|desc"，最后一列须唯一（通常为主键）
+// columns 为空表示该排序无法 keyset（如相关度、热度），游标退化为偏移量，调用方需先设置排序
+// selects 为联表查询时只取主表字段，如 "prompts.*"；在统计总数之后才应用
+type keyset struct {
+	scope   string
+	columns []string
+	selects string
+}
+
+func cursorSecret() []byte {
+	return []byte("cursor:" + config.Cfg.JWT.Secret)
+}
+
+// normalizePage 限制每页条数并修正页码
+func normalizePage(req *PageRequest) {
+	if req.Size <= 0 {
+		req.Size = DefaultPageSize
+	}
+	if req.Size > MaxPageSize {
+		req.Size = MaxPageSize
+	}
+	if req.Page < 1 {
+		req.Page = 1
+	}
+}
+
+// emptyPage 结果必为空时的分页信息
+func emptyPage(req PageRequest) PageInfo {
+	var info PageInfo
+	if req.WithTotal {
+		info.Total = new(int64)
+	}
+	return info
+}
+
+// paginate 按游标分页查询，values 返回一行在 ks.columns 上的取值
+func paginate[T any](db *gorm.DB, req PageRequest, ks keyset, values func(*T) []interface{}) ([]T, PageInfo, error) {
+	normalizePage(&req)
+	var info PageInfo
+	var cur utils.Cursor
+	if req.Cursor != "" {
+		var err error
+		if cur, err = utils.DecodeCursor(req.Cursor, cursorSecret()); err != nil || cur.Scope != ks.scope {
+			return nil, info, fmt.Errorf("%w: cursor", ErrInvalidParam)
+		}
+	}
+	if req.WithTotal {
+		var total int64
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, info, err
+		}
+		info.Total = &total
+	}
+
+	if ks.selects != "" {
+		db = db.Select(ks.selects)
+	}
+	list := make([]T, 0, req.Size+1)
+	if len(ks.columns) == 0 {
+		offset := (req.Page - 1) * req.Size
+		if req.Cursor != "" {
+			offset = cur.Offset
+		}
+		if err := db.Limit(req.Size + 1).Offset(offset).Find(&list).Error; err != nil {
+			return nil, info, err
+		}
+		if len(list) > req.Size {
+			list = list[:req.Size]
+			info.NextCursor = utils.EncodeCursor(utils.Cursor{Scope: ks.scope, Offset: offset + req.Size}, cursorSecret())
+		}
+		if offset > 0 {
+			info.PrevCursor = utils.EncodeCursor(utils.Cursor{Scope: ks.scope, Offset: max(offset-req.Size, 0)}, cursorSecret())
+		}
+		return list, info, nil
+	}
+
+	backward := req.Cursor != "" && cur.Before
+	offset := 0
+	if req.Cursor != "" {
+		where, args, err := keysetCondition(ks.columns, cur.Keys, backward)
+		if err != nil {
+			return nil, info, err
+		}
+		db = db.Where(where, args...)
+	} else {
+		offset = (req.Page - 1) * req.Size
+	}
+	for _, col := range ks.columns {
+		name, desc := splitOrder(col)
+		if desc != backward {
+			db = db.Order(name + " desc")
+		} else {
+			db = db.Order(name + " asc")
+		}
+	}
+	if err := db.Limit(req.Size + 1).Offset(offset).Find(&list).Error; err != nil {
+		return nil, info, err
+	}
+	more := len(list) > req.Size
+	if more {
+		list = list[:req.Size]
+	}
+	if backward {
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+	if len(list) == 0 {
+		return list, info, nil
+	}
+	boundary := func(row *T, before bool) string {
+		keys := make([]string, 0, len(ks.columns))
+		for _, v := range values(row) {
+			keys = append(keys, utils.CursorValue(v))
+		}
+		return utils.EncodeCursor(utils.Cursor{Scope: ks.scope, Keys: keys, Before: before}, cursorSecret())
+	}
+	if more || backward {
+		info.NextCursor = boundary(&list[len(list)-1], false)
+	}
+	if (backward && more) || (!backward && (req.Cursor != "" || offset > 0)) {
+		info.PrevCursor = boundary(&list[0], true)
+	}
+	return list, info, nil
+}
+
+// keysetCondition 生成 "在边界行之后" 的条件：(a > ?) OR (a = ? AND b > ?) ...，方向按列各自的排序
+func keysetCondition(columns, keys []string, backward bool) (string, []interface{}, error) {
+	if len(keys) != len(columns) {
+		return "", nil, fmt.Errorf("%w: cursor", ErrInvalidParam)
+	}
+	vals := make([]interface{}, len(keys))
+	for i, k := range keys {
+		v, err := utils.ParseCursorValue(k)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: cursor", ErrInvalidParam)
+		}
+		vals[i] = v
+	}
+	var ors []string
+	var args []interface{}
+	for i, col := range columns {
+		var ands []string
+		for j := 0; j < i; j++ {
+			name, _ := splitOrder(columns[j])
+			ands = append(ands, name+" = ?")
+			args = append(args, vals[j])
+		}
+		name, desc := splitOrder(col)
+		op := ">"
+		if desc != backward {
+			op = "<"
+		}
+		ands = append(ands, name+" "+op+" ?")
+		args = append(args, vals[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args, nil
+}
+
+func splitOrder(col string) (string, bool) {
+	name, dir, _ := strings.Cut(col, " ")
+	return name, strings.EqualFold(dir, "desc")
+}
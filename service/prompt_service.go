@@ -1,15 +1,173 @@
 package service
 
 import (
+	"fmt"
 	"prompt-share-backend/database"
 	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"slices"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// validatePrompt 保存前校验模板变量与模型参数，prev 为修改前的 prompt，创建时为 nil
+// 没有声明变量且 content 未改动时不校验模板，content 中恰好含 {{...}} 的旧 prompt 仍可修改其他字段
+func validatePrompt(p, prev *model.Prompt) error {
+	errs := utils.FieldErrors{}
+	if prev == nil || len(p.Variables) > 0 || p.Content != prev.Content {
+		errs = CheckTemplate(p.Content, p.Variables)
+	}
+	for field, msg := range CheckModelParams(p.ModelFamily, p.Params) {
+		errs.Add(field, msg)
+	}
+	return errs.Err()
+}
+
+// CreatePrompt 创建 prompt 并记录初始版本，命中 review 内容规则时保存为待审
+// p.Images 不为 nil 时在同一事务中保存图片，见 savePromptImagesTx
 func CreatePrompt(p *model.Prompt) error {
-	return database.DB.Create(p).Error
+	if err := validatePrompt(p, nil); err != nil {
+		return err
+	}
+	verdict, err := checkPromptContent(p)
+	if err != nil {
+		return err
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		set, err := resolvePromptTags(tx, p)
+		if err != nil {
+			return err
+		}
+		if err := set.checkBanned(); err != nil {
+			return err
+		}
+		if err := createPromptTx(tx, p); err != nil {
+			return err
+		}
+		if err := set.save(tx, p.ID); err != nil {
+			return err
+		}
+		if p.Images != nil {
+			if err := savePromptImagesTx(tx, p, p.UserID, p.Images); err != nil {
+				return err
+			}
+		}
+		if err := recordRevision(tx, p, p.UserID); err != nil {
+			return err
+		}
+		change, err := flagForReview(tx, model.TargetPrompt, p.ID, verdict)
+		if change != nil {
+			p.Status = change.status
+		}
+		return err
+	})
+	if err == nil {
+		queueEmbeddings(p.ID)
+		// 待审内容不对外推送
+		if p.Status == model.StatusVisible {
+			publishPrompt(EventPromptCreated, p.ID, p)
+			queueWebhooks(model.WebhookPromptCreated, p, p.UserID)
+		}
+	}
+	return err
+}
+
+// createPromptTx 在事务中写入新 prompt，服务端维护的字段统一重置
+func createPromptTx(tx *gorm.DB, p *model.Prompt) error {
+	p.ID = 0
+	p.Version = 1
+	p.LikeCount, p.FavCount, p.ForkCount, p.CommentCount = 0, 0, 0, 0
+	p.Status = model.StatusVisible
+	if err := tx.Create(p).Error; err != nil {
+		return err
+	}
+	return indexPrompts(tx, p.ID)
+}
+
+// UpdatePrompt 作者或版主修改 prompt 的可编辑字段并记录新版本
+// expectedVersion 非 0 时要求与当前版本一致，否则返回 ErrConflict
+// in.Images 不为 nil 时在同一事务中整体替换图片，图片变化不产生新版本
+func UpdatePrompt(in *model.Prompt, editorID uint, expectedVersion int) (*model.Prompt, error) {
+	var prev model.Prompt
+	if err := database.DB.Select("id", "content").First(&prev, in.ID).Error; err != nil {
+		return nil, err
+	}
+	if err := validatePrompt(in, &prev); err != nil {
+		return nil, err
+	}
+	verdict, err := checkPromptContent(in)
+	if err != nil {
+		return nil, err
+	}
+	moderator, err := canModerate(editorID)
+	if err != nil {
+		return nil, err
+	}
+	var p model.Prompt
+	var change *statusChange
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&p, in.ID).Error; err != nil {
+			return err
+		}
+		if p.UserID != editorID && !moderator {
+			return ErrForbidden
+		}
+		if err := checkVersion(&p, expectedVersion); err != nil {
+			return err
+		}
+		if err := ensureBaselineRevision(tx, &p); err != nil {
+			return err
+		}
+		// 版主标记的 nsfw 作者不能取消
+		if p.NSFWLocked {
+			in.NSFW = true
+		}
+		columns := []string{"title", "content", "tags", "variables", "negative_prompt", "model_family", "params",
+			"author_name", "source_by", "source_url", "source_tags", "nsfw"}
+		set, err := resolvePromptTags(tx, in)
+		if err != nil {
+			return err
+		}
+		if err := set.checkBanned(); err != nil {
+			return err
+		}
+		if err := bumpPromptVersion(tx, &p, columns, in); err != nil {
+			return err
+		}
+		if err := set.save(tx, p.ID); err != nil {
+			return err
+		}
+		if err := tx.First(&p, in.ID).Error; err != nil {
+			return err
+		}
+		if in.Images != nil {
+			if err := savePromptImagesTx(tx, &p, editorID, in.Images); err != nil {
+				return err
+			}
+			p.Images = in.Images
+		}
+		if err := recordRevision(tx, &p, editorID); err != nil {
+			return err
+		}
+		change, err = flagForReview(tx, model.TargetPrompt, p.ID, verdict)
+		if change != nil {
+			p.Status = change.status
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	queueEmbeddings(p.ID)
+	if p.Status == model.StatusVisible {
+		publishPrompt(EventPromptUpdated, p.ID, &p)
+		queueWebhooks(model.WebhookPromptUpdated, &p, p.UserID)
+	} else {
+		change.publish()
+	}
+	return &p, nil
 }
 
 func GetPromptByID(id uint) (*model.Prompt, error) {
@@ -23,7 +181,7 @@ func GetPromptByID(id uint) (*model.Prompt, error) {
 func GetPromptImgByID(id uint) ([]model.PromptImg, error) {
 	var list []model.PromptImg
 	db := database.DB.Model(&model.PromptImg{})
-	db = db.Where("prompt_id = ?", id)
+	db = db.Where("prompt_id = ?", id).Order("id asc")
 	if err := db.Find(&list).Error; err != nil {
 		return nil, err
 	}
@@ -36,9 +194,9 @@ func GetPromptImgByPromptIds(ids []uint) ([]model.PromptImg, error) {
 	db := database.DB.Model(&model.PromptImg{})
 
 	// 只查询指定的字段
-	db = db.Select("id", "prompt_id", "file_id", "file_url", "tags")
+	db = db.Select("id", "prompt_id", "file_id", "file_url", "tags", "nsfw", "nsfw_locked")
 
-	db = db.Where("prompt_id in ?", ids)
+	db = db.Where("prompt_id in ?", ids).Order("id asc")
 	if err := db.Find(&list).Error; err != nil {
 		return nil, err
 	}
@@ -46,46 +204,444 @@ func GetPromptImgByPromptIds(ids []uint) ([]model.PromptImg, error) {
 	return list, nil
 }
 
-func QueryPrompts(q string, tag string, page, pageSize int) ([]model.Prompt, int64, error) {
-	var list []model.Prompt
-	var total int64
-	db := database.DB.Model(&model.Prompt{})
+// FillPromptImages 批量查询并绑定 prompt 的图片
+func FillPromptImages(list []model.Prompt) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	// 1. 查询所有id
+	ids := make([]uint, len(list))
+	for i, v := range list {
+		ids[i] = v.ID
+	}
+
+	// 2. 批量查询图片
+	images, err := GetPromptImgByPromptIds(ids)
+	if err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		return nil
+	}
+
+	// 3. 创建以PromptID为键的图片映射，单次遍历将图片绑定到对应的prompt
+	imageMap := make(map[uint][]model.PromptImg)
+	for _, img := range images {
+		imageMap[img.PromptID] = append(imageMap[img.PromptID], img)
+	}
+	for i := range list {
+		if promptImages, exists := imageMap[list[i].ID]; exists {
+			list[i].Images = promptImages
+		}
+	}
+	return nil
+}
+
+// prompt 列表排序方式
+const (
+	SortNewest    = "newest"
+	SortLikes     = "likes"
+	SortFavs      = "favs"
+	SortComments  = "comments"
+	SortTrending  = "trending"  // 互动量按发布时长衰减
+	SortRelevance = "relevance" // 全文检索相关度，仅在有 q 时有效
+)
+
+// trendingScore (点赞 + 2×收藏 + 评论) / (发布小时数 + 2)²
+const trendingScore = `(prompts.like_count + 2 * prompts.fav_count + prompts.comment_count) /
+	(((julianday('now') - julianday(prompts.created_at)) * 24 + 2) *
+	((julianday('now') - julianday(prompts.created_at)) * 24 + 2))`
+
+// promptSortColumns 可 keyset 分页的排序的主排序列，均以 created_at、id 倒序兜底
+var promptSortColumns = map[string]string{
+	SortNewest:   "",
+	SortLikes:    "prompts.like_count",
+	SortFavs:     "prompts.fav_count",
+	SortComments: "prompts.comment_count",
+}
+
+// PromptFilter prompt 列表查询条件
+type PromptFilter struct {
+	Q           string
+	TagsAll     []string // 必须同时包含
+	TagsAny     []string // 至少包含其一
+	TagsNot     []string // 不能包含
+	ModelFamily string
+	Negative    string            // 反向提示词包含
+	Params      map[string]string // 推荐参数精确匹配，key 为参数名
+	Author      string            // 作者署名
+	UserID      uint              // 发布者
+	Source      string            // 来源 SourceBy
+	CreatedFrom time.Time         // 创建时间下界（含），零值不限
+	CreatedTo   time.Time         // 创建时间上界（不含），零值不限
+	HasImages   *bool
+	MinLikes    int64
+	MinFavs     int64
+	Sort        string // 为空时有 q 按相关度，否则按最新
+	NSFW        string // 浏览者的 nsfw 偏好，hide 时排除被标记的 prompt
+}
+
+// FacetCount 分面统计项
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// PromptFacets 当前结果集的分面统计
+type PromptFacets struct {
+	Tags    []FacetCount `json:"tags"`
+	Authors []FacetCount `json:"authors"`
+	Sources []FacetCount `json:"sources"`
+}
+
+func QueryPrompts(f PromptFilter, req PageRequest) ([]model.Prompt, PageInfo, error) {
+	db, ranked, empty, err := filterPrompts(f)
+	if err != nil || empty {
+		return []model.Prompt{}, emptyPage(req), err
+	}
+
+	sort := f.Sort
+	if sort == "" || (sort == SortRelevance && !ranked) {
+		sort = SortNewest
+		if ranked {
+			sort = SortRelevance
+		}
+	}
+	ks := keyset{scope: "prompts:" + sort, selects: "prompts.*"}
+	switch sort {
+	case SortRelevance:
+		db = db.Order(searchRank).Order("prompts.id desc")
+	case SortTrending:
+		db = db.Order(trendingScore + " desc").Order("prompts.id desc")
+	default:
+		if col := promptSortColumns[sort]; col != "" {
+			ks.columns = append(ks.columns, col+" desc")
+		}
+		ks.columns = append(ks.columns, "prompts.created_at desc", "prompts.id desc")
+	}
+	list, info, err := paginate(db, req, ks, func(p *model.Prompt) []interface{} {
+		switch sort {
+		case SortLikes:
+			return []interface{}{p.LikeCount, p.CreatedAt, p.ID}
+		case SortFavs:
+			return []interface{}{p.FavCount, p.CreatedAt, p.ID}
+		case SortComments:
+			return []interface{}{p.CommentCount, p.CreatedAt, p.ID}
+		}
+		return []interface{}{p.CreatedAt, p.ID}
+	})
+	if err != nil {
+		return nil, info, searchError(err)
+	}
+	if ranked {
+		if err := fillSearchHighlights(f.Q, list); err != nil {
+			return nil, info, err
+		}
+	}
+	return list, info, nil
+}
+
+// FacetPrompts 统计符合条件的全部 prompt 按标签、作者、来源的分布，每项最多 limit 个
+func FacetPrompts(f PromptFilter, limit int) (*PromptFacets, error) {
+	facets := &PromptFacets{Tags: []FacetCount{}, Authors: []FacetCount{}, Sources: []FacetCount{}}
+	db, _, empty, err := filterPrompts(f)
+	if err != nil || empty {
+		return facets, err
+	}
+	ids := func() *gorm.DB { return db.Select("prompts.id") }
+
+	if err := database.DB.Table("prompt_tags").
+		Select("tags.name AS value, count(*) AS count").
+		Joins("JOIN tags ON tags.id = prompt_tags.tag_id").
+		Where("prompt_tags.kind = ? AND prompt_tags.prompt_id IN (?)", model.TagKindPrompt, ids()).
+		Group("tags.id").Order("count desc, tags.name").Limit(limit).
+		Scan(&facets.Tags).Error; err != nil {
+		return nil, searchError(err)
+	}
+	for column, out := range map[string]*[]FacetCount{"author_name": &facets.Authors, "source_by": &facets.Sources} {
+		if err := database.DB.Model(&model.Prompt{}).
+			Select(column+" AS value, count(*) AS count").
+			Where("id IN (?) AND "+column+" <> ''", ids()).
+			Group(column).Order("count desc, " + column).Limit(limit).
+			Scan(out).Error; err != nil {
+			return nil, searchError(err)
+		}
+	}
+	return facets, nil
+}
+
+// filterPrompts 构造带全部过滤条件的查询，返回的 db 可重复链式使用
+// ranked 表示使用了全文检索，empty 表示结果必为空
+func filterPrompts(f PromptFilter) (db *gorm.DB, ranked, empty bool, err error) {
+	if _, ok := promptSortColumns[f.Sort]; f.Sort != "" && !ok && f.Sort != SortRelevance && f.Sort != SortTrending {
+		return nil, false, false, utils.FieldErrors{"sort": "must be one of newest, likes, favs, comments, trending, relevance"}
+	}
+	db = hideNSFW(database.DB.Model(&model.Prompt{}).Where("prompts.status = ?", model.StatusVisible), f.NSFW)
+	if f.Q != "" {
+		if db, ranked, err = applySearch(db, f.Q); err != nil {
+			return nil, false, false, err
+		}
+	}
+	if db, empty, err = applyTagFilter(db, f); err != nil || empty {
+		return nil, false, empty, err
+	}
+	if f.ModelFamily != "" {
+		db = db.Where("prompts.model_family = ?", f.ModelFamily)
+	}
+	if f.Negative != "" {
+		db = db.Where("prompts.negative_prompt LIKE ?", "%"+f.Negative+"%")
+	}
+	for key, value := range f.Params {
+		if !IsParamKey(key) {
+			return nil, false, false, fmt.Errorf("%w: param %q", ErrInvalidParam, key)
+		}
+		db = db.Where("CAST(json_extract(prompts.params, ?) AS TEXT) = ?", "$."+key, value)
+	}
+	if f.Author != "" {
+		db = db.Where("prompts.author_name = ?", f.Author)
+	}
+	if f.UserID != 0 {
+		db = db.Where("prompts.user_id = ?", f.UserID)
+	}
+	if f.Source != "" {
+		db = db.Where("prompts.source_by = ?", f.Source)
+	}
+	if !f.CreatedFrom.IsZero() {
+		db = db.Where("julianday(prompts.created_at) >= julianday(?)", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		db = db.Where("julianday(prompts.created_at) < julianday(?)", f.CreatedTo)
+	}
+	if f.HasImages != nil {
+		images := database.DB.Model(&model.PromptImg{}).Select("prompt_id")
+		if *f.HasImages {
+			db = db.Where("prompts.id IN (?)", images)
+		} else {
+			db = db.Where("prompts.id NOT IN (?)", images)
+		}
+	}
+	if f.MinLikes > 0 {
+		db = db.Where("prompts.like_count >= ?", f.MinLikes)
+	}
+	if f.MinFavs > 0 {
+		db = db.Where("prompts.fav_count >= ?", f.MinFavs)
+	}
+	return db.Session(&gorm.Session{}), ranked, false, nil
+}
 
-	if q != "" {
-		db = db.Where("title LIKE ? OR content LIKE ?", "%"+q+"%", "%"+q+"%")
+// applyTagFilter 按规范标签过滤（AND/OR/NOT），上级标签包含全部下级
+// 返回 empty=true 表示结果必为空
+func applyTagFilter(db *gorm.DB, f PromptFilter) (*gorm.DB, bool, error) {
+	tagged := func(ids []uint) *gorm.DB {
+		return database.DB.Model(&model.PromptTag{}).Select("prompt_id").
+			Where("kind = ? AND tag_id IN ?", model.TagKindPrompt, ids)
 	}
-	if tag != "" {
-		db = db.Where("tags LIKE ?", "%"+tag+"%")
+
+	if len(f.TagsAll) > 0 {
+		groups, missing, err := lookupTagGroups(f.TagsAll)
+		if err != nil {
+			return nil, false, err
+		}
+		if missing > 0 {
+			return db, true, nil
+		}
+		for _, ids := range groups {
+			db = db.Where("prompts.id IN (?)", tagged(ids))
+		}
 	}
-	if err := db.Count(&total).Error; err != nil {
-		return nil, 0, err
+	if len(f.TagsAny) > 0 {
+		groups, _, err := lookupTagGroups(f.TagsAny)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(groups) == 0 {
+			return db, true, nil
+		}
+		db = db.Where("prompts.id IN (?)", tagged(flattenIDs(groups)))
 	}
-	offset := (page - 1) * pageSize
-	if err := db.Order("created_at desc").Limit(pageSize).Offset(offset).Find(&list).Error; err != nil {
-		return nil, 0, err
+	if len(f.TagsNot) > 0 {
+		groups, _, err := lookupTagGroups(f.TagsNot)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(groups) > 0 {
+			db = db.Where("prompts.id NOT IN (?)", tagged(flattenIDs(groups)))
+		}
 	}
-	return list, total, nil
+	return db, false, nil
 }
 
-func LikePrompt(id uint) error {
-	return database.DB.Model(&model.Prompt{}).Where("id = ?", id).UpdateColumn("like_count", gorm.Expr("like_count + ?", 1)).Error
+func flattenIDs(groups [][]uint) []uint {
+	var out []uint
+	for _, g := range groups {
+		out = append(out, g...)
+	}
+	return uniqueIDs(out)
 }
 
-func FavoritePrompt(id uint) error {
-	return database.DB.Model(&model.Prompt{}).Where("id = ?", id).UpdateColumn("fav_count", gorm.Expr("fav_count + ?", 1)).Error
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	out := ids[:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
 }
 
+// ParseTags 去掉每个标签首尾空白并丢弃空标签，返回逗号连接的字符串
 func ParseTags(tags []string) string {
+	out := make([]string, 0, len(tags))
 	for i := range tags {
-		tags[i] = strings.TrimSpace(tags[i])
+		if t := strings.TrimSpace(tags[i]); t != "" {
+			out = append(out, t)
+		}
+	}
+	return strings.Join(out, ",")
+}
+
+// DeletePrompt 作者或版主将 prompt 移入回收站并移出全文索引，返回删除前的记录
+// 保留期内可以恢复，见 RestoreTrash
+func DeletePrompt(id, userID uint) (*model.Prompt, error) {
+	moderator, err := canModerate(userID)
+	if err != nil {
+		return nil, err
+	}
+	var p model.Prompt
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if err := tx.Select("id", "user_id").First(&p, id).Error; err != nil {
+			return err
+		}
+		if p.UserID != userID && !moderator {
+			return ErrForbidden
+		}
+		p, err = deletePromptTx(tx, id, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	promptDeleted(id, p)
+	return &p, nil
+}
+
+// deletePromptTx 在事务中将 prompt 移入回收站，返回删除前的记录，不存在时为零值
+// 图片、评论等关联数据保留到永久删除时一并清理
+func deletePromptTx(tx *gorm.DB, id, deletedBy uint) (model.Prompt, error) {
+	var p model.Prompt
+	if err := tx.Limit(1).Find(&p, id).Error; err != nil || p.ID == 0 {
+		return p, err
+	}
+	if err := tx.Model(&model.Prompt{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"deleted_at": time.Now(), "deleted_by": deletedBy}).Error; err != nil {
+		return p, err
+	}
+	if err := recountPromptTags(tx, id); err != nil {
+		return p, err
+	}
+	return p, indexPrompts(tx, id)
+}
+
+// promptDeleted 删除事务提交后清理向量并推送事件
+func promptDeleted(id uint, p model.Prompt) {
+	queueEmbeddings(id)
+	publishPrompt(EventPromptDeleted, id, map[string]uint{"id": id})
+	if p.ID != 0 {
+		queueWebhooks(model.WebhookPromptDeleted, map[string]interface{}{"id": p.ID, "user_id": p.UserID, "title": p.Title}, p.UserID)
 	}
-	return strings.Join(tags, ",")
 }
 
 func AddPromptImg(m *model.PromptImg) error {
 	return database.DB.Create(m).Error
 }
 
-func DeletePromptImages(id uint) error {
-	return database.DB.Where("prompt_id = ?", id).Delete(&model.PromptImg{}).Error
+// SavePromptImages 作者按顺序整体替换 prompt 的图片，空列表表示删除全部图片
+func SavePromptImages(promptID, userID uint, images []model.PromptImg) ([]model.PromptImg, error) {
+	var p model.Prompt
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id", "user_id").First(&p, promptID).Error; err != nil {
+			return err
+		}
+		return savePromptImagesTx(tx, &p, userID, images)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// savePromptImagesTx 在事务中按顺序整体替换 prompt 的图片并同步图片标签
+// 只有作者可以修改图片；新加入的文件须为作者本人上传的图片，已在该 prompt 中的文件（如 fork 得到的）可以保留
+func savePromptImagesTx(tx *gorm.DB, p *model.Prompt, userID uint, images []model.PromptImg) error {
+	if p.UserID != userID {
+		return fmt.Errorf("%w: only the author can change the images", ErrForbidden)
+	}
+	if err := checkImageFiles(tx, p.ID, userID, images); err != nil {
+		return err
+	}
+	if err := keepImageNSFWLocks(tx, p.ID, images); err != nil {
+		return err
+	}
+	if err := deletePromptImagesTx(tx, p.ID); err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		return nil
+	}
+	for i := range images {
+		images[i].ID = 0
+		images[i].PromptID = p.ID
+		images[i].FileUrl = fileURL(images[i].FileId)
+	}
+	// 按列表顺序插入，图片按 id 升序展示
+	if err := tx.Create(&images).Error; err != nil {
+		return err
+	}
+	return syncImageTags(tx, images, true)
+}
+
+// checkImageFiles 校验图片引用的文件存在、未删除且为图片，新加入的文件须由 userID 上传
+func checkImageFiles(tx *gorm.DB, promptID, userID uint, images []model.PromptImg) error {
+	if len(images) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(images))
+	for i := range images {
+		ids[i] = images[i].FileId
+	}
+	var files []model.File
+	if err := tx.Select("id", "uploader_id", "type").Where("id IN ?", ids).Find(&files).Error; err != nil {
+		return err
+	}
+	var attached []uint
+	if err := tx.Model(&model.PromptImg{}).Where("prompt_id = ?", promptID).Pluck("file_id", &attached).Error; err != nil {
+		return err
+	}
+	byID := make(map[uint]model.File, len(files))
+	for _, f := range files {
+		byID[f.ID] = f
+	}
+	errs := utils.FieldErrors{}
+	for i, img := range images {
+		field := fmt.Sprintf("images[%d].file_id", i)
+		f, ok := byID[img.FileId]
+		switch {
+		case !ok:
+			errs.Add(field, "file does not exist")
+		case !utils.IsImage(f.Type):
+			errs.Add(field, "must be an image")
+		case f.UploaderID != userID && !slices.Contains(attached, f.ID):
+			errs.Add(field, "must be a file you uploaded")
+		}
+	}
+	return errs.Err()
+}
+
+// fileURL 图片的访问地址
+func fileURL(fileID uint) string {
+	return fmt.Sprintf("/api/files/preview/%d", fileID)
 }
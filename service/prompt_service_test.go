@@ -0,0 +1,33 @@
+package service
+
+import (
+	"errors"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"testing"
+)
+
+// content 中含 {{...}} 但没有声明变量的旧 prompt，只改其他字段时不应因模板校验失败
+func TestUpdateLegacyTemplateContent(t *testing.T) {
+	setupTestDB(t)
+	u := createTestUser(t, "alice")
+	legacy := &model.Prompt{UserID: u.ID, Title: "old", Content: "use {{style}} here", Version: 1, Status: model.StatusVisible}
+	if err := database.DB.Create(legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := UpdatePrompt(&model.Prompt{ID: legacy.ID, Title: "renamed", Content: legacy.Content}, u.ID, 0); err != nil {
+		t.Fatalf("title-only edit: %v", err)
+	}
+
+	var fe utils.FieldErrors
+	_, err := UpdatePrompt(&model.Prompt{ID: legacy.ID, Title: "renamed", Content: "use {{other}} here"}, u.ID, 0)
+	if !errors.As(err, &fe) || fe["content"] == "" {
+		t.Errorf("changed content with undeclared variable: err = %v, want content field error", err)
+	}
+	err = CreatePrompt(&model.Prompt{UserID: u.ID, Title: "new", Content: "use {{style}} here"})
+	if !errors.As(err, &fe) || fe["content"] == "" {
+		t.Errorf("create with undeclared variable: err = %v, want content field error", err)
+	}
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RevisionDiff 两个版本之间的差异
+type RevisionDiff struct {
+	PromptID      uint                `json:"prompt_id"`
+	From          int                 `json:"from"`
+	To            int                 `json:"to"`
+	Title         []utils.DiffSegment `json:"title"`
+	Content       []utils.DiffSegment `json:"content"`
+	Tags          []utils.DiffSegment `json:"tags"`
+	Negative      []utils.DiffSegment `json:"negative_prompt"`
+	AddedImages   []model.PromptImg   `json:"added_images"`
+	RemovedImages []model.PromptImg   `json:"removed_images"`
+}
+
+// recordRevision 以 prompt 当前状态（含图片）生成一条版本记录，需在事务中调用
+func recordRevision(tx *gorm.DB, p *model.Prompt, editorID uint) error {
+	var images []model.PromptImg
+	if err := tx.Where("prompt_id = ?", p.ID).Order("id asc").Find(&images).Error; err != nil {
+		return err
+	}
+	raw, err := json.Marshal(images)
+	if err != nil {
+		return err
+	}
+	rev := &model.PromptRevision{
+		PromptID:   p.ID,
+		Version:    p.Version,
+		Title:      p.Title,
+		Content:    p.Content,
+		Tags:       p.Tags,
+		Variables:  p.Variables,
+		ImagesJSON: string(raw),
+		EditorID:   editorID,
+
+		NegativePrompt: p.NegativePrompt,
+		ModelFamily:    p.ModelFamily,
+		Params:         p.Params,
+	}
+	return tx.Create(rev).Error
+}
+
+// ensureBaselineRevision 历史数据没有版本记录时，先把修改前的状态补记为一个版本
+func ensureBaselineRevision(tx *gorm.DB, p *model.Prompt) error {
+	var count int64
+	if err := tx.Model(&model.PromptRevision{}).
+		Where("prompt_id = ? AND version = ?", p.ID, p.Version).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return recordRevision(tx, p, p.UserID)
+}
+
+// checkVersion 校验客户端期望的版本号，expected 为 0 表示不校验
+func checkVersion(p *model.Prompt, expected int) error {
+	if expected != 0 && expected != p.Version {
+		return fmt.Errorf("%w: current version is %d", ErrConflict, p.Version)
+	}
+	return nil
+}
+
+// bumpPromptVersion 以 CAS 方式写入字段并递增版本号，防止并发修改互相覆盖
+func bumpPromptVersion(tx *gorm.DB, cur *model.Prompt, columns []string, in *model.Prompt) error {
+	in.Version = cur.Version + 1
+	in.UpdatedAt = time.Now()
+	columns = append(columns, "version", "updated_at")
+	res := tx.Model(&model.Prompt{}).
+		Where("id = ? AND version = ?", cur.ID, cur.Version).
+		Select(columns).Updates(in)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return indexPrompts(tx, cur.ID)
+}
+
+func decodeRevisionImages(rev *model.PromptRevision) {
+	rev.Images = []model.PromptImg{}
+	if rev.ImagesJSON != "" {
+		_ = json.Unmarshal([]byte(rev.ImagesJSON), &rev.Images)
+	}
+}
+
+// existingRevisionImages 过滤掉版本快照中文件已删除或移入回收站的图片
+func existingRevisionImages(tx *gorm.DB, images []model.PromptImg) ([]model.PromptImg, error) {
+	if len(images) == 0 {
+		return []model.PromptImg{}, nil
+	}
+	ids := make([]uint, len(images))
+	for i := range images {
+		ids[i] = images[i].FileId
+	}
+	var existing []uint
+	if err := tx.Model(&model.File{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+		return nil, err
+	}
+	kept := make([]model.PromptImg, 0, len(images))
+	for _, img := range images {
+		if slices.Contains(existing, img.FileId) {
+			kept = append(kept, img)
+		}
+	}
+	return kept, nil
+}
+
+// ListRevisions 查询 prompt 的全部版本，新版本在前
+func ListRevisions(promptID uint) ([]model.PromptRevision, error) {
+	var list []model.PromptRevision
+	if err := database.DB.Where("prompt_id = ?", promptID).Order("version desc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for i := range list {
+		decodeRevisionImages(&list[i])
+	}
+	return list, nil
+}
+
+// GetRevision 查询指定版本
+func GetRevision(promptID uint, version int) (*model.PromptRevision, error) {
+	var rev model.PromptRevision
+	if err := database.DB.Where("prompt_id = ? AND version = ?", promptID, version).First(&rev).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: revision %d", ErrNotFound, version)
+		}
+		return nil, err
+	}
+	decodeRevisionImages(&rev)
+	return &rev, nil
+}
+
+// DiffRevisions 计算 from -> to 两个版本之间的词级差异
+func DiffRevisions(promptID uint, from, to int) (*RevisionDiff, error) {
+	a, err := GetRevision(promptID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := GetRevision(promptID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &RevisionDiff{
+		PromptID:      promptID,
+		From:          from,
+		To:            to,
+		Title:         utils.WordDiff(a.Title, b.Title),
+		Content:       utils.WordDiff(a.Content, b.Content),
+		Tags:          utils.WordDiff(a.Tags, b.Tags),
+		Negative:      utils.WordDiff(a.NegativePrompt, b.NegativePrompt),
+		AddedImages:   []model.PromptImg{},
+		RemovedImages: []model.PromptImg{},
+	}
+
+	// 图片按 file_id 比较
+	inA := make(map[uint]bool, len(a.Images))
+	for _, img := range a.Images {
+		inA[img.FileId] = true
+	}
+	inB := make(map[uint]bool, len(b.Images))
+	for _, img := range b.Images {
+		inB[img.FileId] = true
+		if !inA[img.FileId] {
+			diff.AddedImages = append(diff.AddedImages, img)
+		}
+	}
+	for _, img := range a.Images {
+		if !inB[img.FileId] {
+			diff.RemovedImages = append(diff.RemovedImages, img)
+		}
+	}
+	return diff, nil
+}
+
+// RevertPrompt 作者或版主将 prompt 恢复到指定版本的内容与图片，并生成一个新版本
+// 恢复的内容同样经过当前的内容规则，命中 review 规则时转为待审
+func RevertPrompt(promptID uint, version int, editorID uint, expectedVersion int) (*model.Prompt, error) {
+	moderator, err := canModerate(editorID)
+	if err != nil {
+		return nil, err
+	}
+	var cur model.Prompt
+	if err := database.DB.First(&cur, promptID).Error; err != nil {
+		return nil, err
+	}
+	if cur.UserID != editorID && !moderator {
+		return nil, ErrForbidden
+	}
+	var rev model.PromptRevision
+	if err := database.DB.Where("prompt_id = ? AND version = ?", promptID, version).First(&rev).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: revision %d", ErrNotFound, version)
+		}
+		return nil, err
+	}
+	decodeRevisionImages(&rev)
+	in := &model.Prompt{
+		Title:          rev.Title,
+		Content:        rev.Content,
+		Tags:           rev.Tags,
+		Variables:      rev.Variables,
+		NegativePrompt: rev.NegativePrompt,
+		ModelFamily:    rev.ModelFamily,
+		Params:         rev.Params,
+		// 版本中不记录来源标签与 nsfw 标记，沿用当前值，否则保存标签关联时会清掉来源标签
+		SourceTags: cur.SourceTags,
+		NSFW:       cur.NSFW,
+	}
+	verdict, err := checkPromptContent(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var p model.Prompt
+	var change *statusChange
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&p, promptID).Error; err != nil {
+			return err
+		}
+		if err := checkVersion(&p, expectedVersion); err != nil {
+			return err
+		}
+		if err := ensureBaselineRevision(tx, &p); err != nil {
+			return err
+		}
+		// 版主标记的 nsfw 作者不能取消
+		if p.NSFWLocked {
+			in.NSFW = true
+		}
+		columns := []string{"title", "content", "tags", "source_tags", "variables", "negative_prompt", "model_family",
+			"params", "nsfw"}
+		set, err := resolvePromptTags(tx, in)
+		if err != nil {
+			return err
+		}
+		if err := set.checkBanned(); err != nil {
+			return err
+		}
+		if err := bumpPromptVersion(tx, &p, columns, in); err != nil {
+			return err
+		}
+		if err := set.save(tx, promptID); err != nil {
+			return err
+		}
+
+		// 恢复图片：跳过已删除的文件，其余按作者保存图片的规则校验，并保留版主当前的 nsfw 锁定
+		images, err := existingRevisionImages(tx, rev.Images)
+		if err != nil {
+			return err
+		}
+		if err := savePromptImagesTx(tx, &p, p.UserID, images); err != nil {
+			return err
+		}
+
+		if err := tx.First(&p, promptID).Error; err != nil {
+			return err
+		}
+		if err := recordRevision(tx, &p, editorID); err != nil {
+			return err
+		}
+		change, err = flagForReview(tx, model.TargetPrompt, p.ID, verdict)
+		if change != nil {
+			p.Status = change.status
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	queueEmbeddings(p.ID)
+	// 隐藏或待审的 prompt 不对外推送
+	if p.Status == model.StatusVisible {
+		publishPrompt(EventPromptUpdated, p.ID, &p)
+		queueWebhooks(model.WebhookPromptUpdated, &p, p.UserID)
+	} else {
+		change.publish()
+	}
+	return &p, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"strconv"
+	"testing"
+)
+
+func TestRevertPromptImages(t *testing.T) {
+	setupTestDB(t)
+	u := createTestUser(t, "alice")
+	a := createTestFile(t, u.ID, 0x1)
+	b := createTestFile(t, u.ID, 0xff00)
+
+	p := &model.Prompt{UserID: u.ID, Title: "t", Content: "c",
+		Images: []model.PromptImg{{FileId: a.ID}, {FileId: b.ID, NSFW: true}}}
+	if err := CreatePrompt(p); err != nil {
+		t.Fatal(err)
+	}
+	// 快照中的地址被篡改也不应原样恢复
+	if err := database.DB.Model(&model.PromptRevision{}).Where("prompt_id = ? AND version = 1", p.ID).
+		Update("images", `[{"file_id":`+strconv.FormatUint(uint64(a.ID), 10)+`,"file_url":"https://evil.example/a.png"},{"file_id":`+strconv.FormatUint(uint64(b.ID), 10)+`,"nsfw":true}]`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UpdatePrompt(&model.Prompt{ID: p.ID, Title: "t2", Content: "c", Images: []model.PromptImg{{FileId: a.ID}}}, u.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	// 版主之后锁定了 a，b 被删除
+	if err := database.DB.Model(&model.PromptImg{}).Where("prompt_id = ? AND file_id = ?", p.ID, a.ID).
+		UpdateColumns(map[string]interface{}{"nsfw": true, "nsfw_locked": true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeleteFile(b.ID, u.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := RevertPrompt(p.ID, 1, u.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	var imgs []model.PromptImg
+	if err := database.DB.Where("prompt_id = ?", p.ID).Order("id").Find(&imgs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(imgs) != 1 || imgs[0].FileId != a.ID {
+		t.Fatalf("images after revert = %+v, want only file %d", imgs, a.ID)
+	}
+	if !imgs[0].NSFW || !imgs[0].NSFWLocked {
+		t.Errorf("moderator lock lost: %+v", imgs[0])
+	}
+	if imgs[0].FileUrl != fileURL(a.ID) {
+		t.Errorf("file_url = %q, want %q", imgs[0].FileUrl, fileURL(a.ID))
+	}
+}
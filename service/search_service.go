@@ -0,0 +1,297 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"prompt-share-backend/config"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// searchEnabled 当前 sqlite 是否支持 FTS5（需以 -tags sqlite_fts5 编译），不支持时退化为 LIKE 查询
+var searchEnabled bool
+
+// 高亮标记，写入 HTML 前替换为 <mark>
+const (
+	markOpen  = "\x02"
+	markClose = "\x03"
+)
+
+// searchRank 各列 BM25 权重：title, content, tags, author
+const searchRank = "bm25(prompt_fts, 10.0, 1.0, 5.0, 2.0)"
+
+// searchColumns 查询语法中允许的列限定
+var searchColumns = map[string]bool{"title": true, "content": true, "tags": true, "author": true}
+
+// InitSearchIndex 创建 prompt 全文索引，索引为空时自动重建
+// SQLite 未编译 FTS5 时返回错误，除非配置允许退化为 LIKE 查询
+func InitSearchIndex() error {
+	var fts5 bool
+	if err := database.DB.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5).Error; err != nil {
+		return err
+	}
+	if !fts5 {
+		if !config.Cfg.Search.AllowLikeFallback {
+			return errors.New("sqlite built without FTS5: build with -tags sqlite_fts5, or set search.allow_like_fallback to search with LIKE")
+		}
+		log.Println("sqlite built without FTS5, full-text search falls back to LIKE (build with -tags sqlite_fts5)")
+		return nil
+	}
+	if err := database.DB.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS prompt_fts
+		USING fts5(title, content, tags, author, tokenize = 'unicode61 remove_diacritics 2')`).Error; err != nil {
+		return err
+	}
+	searchEnabled = true
+
+	var indexed, prompts int64
+	if err := database.DB.Raw("SELECT count(*) FROM prompt_fts").Scan(&indexed).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Model(&model.Prompt{}).Count(&prompts).Error; err != nil {
+		return err
+	}
+	if indexed == 0 && prompts > 0 {
+		return RebuildSearchIndex()
+	}
+	return nil
+}
+
+// RebuildSearchIndex 清空并重建全文索引
+func RebuildSearchIndex() error {
+	if !searchEnabled {
+		return fmt.Errorf("full-text search is not available in this build")
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM prompt_fts").Error; err != nil {
+			return err
+		}
+		var batch []model.Prompt
+		return tx.Select("id", "title", "content", "tags", "source_tags", "author_name").
+			FindInBatches(&batch, 500, func(b *gorm.DB, _ int) error {
+				for i := range batch {
+					if err := insertSearchRow(tx, &batch[i]); err != nil {
+						return err
+					}
+				}
+				return nil
+			}).Error
+	})
+}
+
+// indexPrompts 在事务中刷新指定 prompt 的索引行，已删除的 prompt 同时移出索引
+func indexPrompts(tx *gorm.DB, ids ...uint) error {
+	if !searchEnabled || len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Exec("DELETE FROM prompt_fts WHERE rowid IN ?", ids).Error; err != nil {
+		return err
+	}
+	var list []model.Prompt
+	if err := tx.Select("id", "title", "content", "tags", "source_tags", "author_name").
+		Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return err
+	}
+	for i := range list {
+		if err := insertSearchRow(tx, &list[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertSearchRow(tx *gorm.DB, p *model.Prompt) error {
+	tags := strings.Trim(p.Tags+","+p.SourceTags, ",")
+	return tx.Exec("INSERT INTO prompt_fts (rowid, title, content, tags, author) VALUES (?, ?, ?, ?, ?)",
+		p.ID, utils.SegmentCJK(p.Title), utils.SegmentCJK(p.Content),
+		utils.SegmentCJK(tags), utils.SegmentCJK(p.AuthorName)).Error
+}
+
+// searchError FTS5 查询语法错误转为 ErrInvalidParam
+func searchError(err error) error {
+	if strings.Contains(err.Error(), "fts5:") {
+		return fmt.Errorf("%w: q: %v", ErrInvalidParam, err)
+	}
+	return err
+}
+
+// applySearch 为查询加上全文检索条件，返回是否按相关度排序
+func applySearch(db *gorm.DB, q string) (*gorm.DB, bool, error) {
+	if !searchEnabled {
+		return db.Where("prompts.title LIKE ? OR prompts.content LIKE ?", "%"+q+"%", "%"+q+"%"), false, nil
+	}
+	expr, err := BuildSearchQuery(q)
+	if err != nil {
+		return nil, false, err
+	}
+	return db.Joins("JOIN prompt_fts ON prompt_fts.rowid = prompts.id").
+		Where("prompt_fts MATCH ?", expr), true, nil
+}
+
+// fillSearchHighlights 为搜索结果填充高亮标题与内容片段
+func fillSearchHighlights(q string, list []model.Prompt) error {
+	if !searchEnabled || len(list) == 0 {
+		return nil
+	}
+	expr, err := BuildSearchQuery(q)
+	if err != nil {
+		return err
+	}
+	ids := make([]uint, len(list))
+	for i := range list {
+		ids[i] = list[i].ID
+	}
+	var rows []struct {
+		ID      uint
+		Title   string
+		Snippet string
+	}
+	if err := database.DB.Raw(`SELECT rowid AS id,
+			highlight(prompt_fts, 0, ?, ?) AS title,
+			snippet(prompt_fts, 1, ?, ?, '…', 32) AS snippet
+		FROM prompt_fts WHERE prompt_fts MATCH ? AND rowid IN ?`,
+		markOpen, markClose, markOpen, markClose, expr, ids).Scan(&rows).Error; err != nil {
+		return err
+	}
+	byID := make(map[uint]*model.SearchHighlight, len(rows))
+	for _, r := range rows {
+		byID[r.ID] = &model.SearchHighlight{
+			Title:   utils.HighlightHTML(r.Title, markOpen, markClose),
+			Snippet: utils.HighlightHTML(r.Snippet, markOpen, markClose),
+		}
+	}
+	for i := range list {
+		list[i].Highlight = byID[list[i].ID]
+	}
+	return nil
+}
+
+// BuildSearchQuery 将用户输入转换为安全的 FTS5 查询表达式
+// 支持："短语"、前缀 term*、AND / OR / NOT、括号、-term 排除、列限定 title: content: tags: author:
+// 相邻的词默认为 AND；每个词都加引号转义，CJK 文本按单字切分后作为短语匹配
+func BuildSearchQuery(q string) (string, error) {
+	var parts, excluded []string
+	depth := 0
+	// prev 记录上一个元素：0 开头/运算符/左括号，1 词/右括号
+	prev := 0
+	invalid := func(msg string) (string, error) {
+		return "", fmt.Errorf("%w: q: %s", ErrInvalidParam, msg)
+	}
+
+	runes := []rune(strings.Map(utils.FoldWidth, q))
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			if prev == 1 {
+				parts = append(parts, "AND")
+			}
+			parts = append(parts, "(")
+			depth++
+			prev = 0
+			i++
+			continue
+		case r == ')':
+			if depth == 0 {
+				return invalid("unbalanced parenthesis")
+			}
+			if prev == 0 {
+				return invalid("empty group or dangling operator")
+			}
+			parts = append(parts, ")")
+			depth--
+			prev = 1
+			i++
+			continue
+		}
+
+		negate := false
+		if r == '-' {
+			negate = true
+			i++
+		}
+		column := ""
+		var text string
+		if i < len(runes) && runes[i] != '"' {
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && runes[j] != '(' && runes[j] != ')' && runes[j] != '"' {
+				j++
+			}
+			word := string(runes[i:j])
+			if c, rest, ok := strings.Cut(word, ":"); ok && searchColumns[strings.ToLower(c)] {
+				column, word, i = strings.ToLower(c), rest, i+len([]rune(c))+1
+			}
+			if !negate && column == "" && (word == "AND" || word == "OR" || word == "NOT") {
+				if prev == 0 {
+					return invalid("dangling operator " + word)
+				}
+				parts = append(parts, word)
+				prev = 0
+				i = j
+				continue
+			}
+			if word != "" {
+				text, i = word, j
+			}
+		}
+		phrase := false
+		if text == "" && i < len(runes) && runes[i] == '"' {
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			text, phrase = string(runes[i+1:j]), true
+			i = j + 1
+		}
+		prefix := false
+		if strings.HasSuffix(text, "*") {
+			text, prefix = strings.TrimRight(text, "*"), true
+		} else if phrase && i < len(runes) && runes[i] == '*' {
+			prefix = true
+			i++
+		}
+		if !strings.ContainsFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+			continue
+		}
+
+		term := `"` + strings.ReplaceAll(utils.SegmentCJK(text), `"`, `""`) + `"`
+		if prefix {
+			term += "*"
+		}
+		if column != "" {
+			term = column + " : " + term
+		}
+		if negate {
+			excluded = append(excluded, term)
+			continue
+		}
+		if prev == 1 {
+			parts = append(parts, "AND")
+		}
+		parts = append(parts, term)
+		prev = 1
+	}
+
+	if depth != 0 {
+		return invalid("unbalanced parenthesis")
+	}
+	if len(parts) > 0 && prev == 0 {
+		return invalid("dangling operator " + parts[len(parts)-1])
+	}
+	if len(parts) == 0 {
+		return invalid("at least one search term is required")
+	}
+	expr := strings.Join(parts, " ")
+	if len(excluded) > 0 {
+		expr = "(" + expr + ") NOT " + strings.Join(excluded, " NOT ")
+	}
+	return expr, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuildSearchQuery(t *testing.T) {
+	cases := []struct {
+		q    string
+		want string
+	}{
+		{`cat dog`, `"cat" AND "dog"`},
+		{`"black cat"`, `"black cat"`},
+		{`cat*`, `"cat"*`},
+		{`"black ca"*`, `"black ca"*`},
+		{`cat OR dog`, `"cat" OR "dog"`},
+		{`(cat OR dog) NOT bird`, `( "cat" OR "dog" ) NOT "bird"`},
+		{`cat(dog)`, `"cat" AND ( "dog" )`},
+		{`cat -dog`, `("cat") NOT "dog"`},
+		{`title:cat`, `title : "cat"`},
+		{`ＴＩＴＬＥ：cat`, `title : "cat"`},
+		{`author:bob* -tags:nsfw`, `(author : "bob"*) NOT tags : "nsfw"`},
+		{`foo:bar`, `"foo:bar"`},
+		{`and or`, `"and" AND "or"`},
+		{`猫耳娘`, "\"猫\u200b耳\u200b娘\""},
+		{`tags:"猫 耳"`, "tags : \"猫\u200b \u200b耳\""},
+		// FTS5 语法和注入尝试都被当作普通词加引号
+		{`NEAR(a b)`, `"NEAR" AND ( "a" AND "b" )`},
+		{`x" OR 1=1 --`, `"x" AND " OR 1=1 --"`},
+		{`a"b`, `"a" AND "b"`},
+		{`it's`, `"it's"`},
+	}
+	for _, c := range cases {
+		got, err := BuildSearchQuery(c.q)
+		if err != nil {
+			t.Errorf("BuildSearchQuery(%q): %v", c.q, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("BuildSearchQuery(%q) = %q, want %q", c.q, got, c.want)
+		}
+	}
+}
+
+func TestBuildSearchQueryInvalid(t *testing.T) {
+	for _, q := range []string{"", "   ", "-cat", "***", "cat AND", "OR cat", "cat OR OR dog", "(cat", "cat)", "()", "(cat OR)"} {
+		if got, err := BuildSearchQuery(q); !errors.Is(err, ErrInvalidParam) {
+			t.Errorf("BuildSearchQuery(%q) = %q, %v; want ErrInvalidParam", q, got, err)
+		}
+	}
+}
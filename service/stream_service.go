@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"prompt-share-backend/config"
+	"prompt-share-backend/model"
+	"prompt-share-backend/stream"
+	"strconv"
+	"strings"
+)
+
+// 实时事件类型
+const (
+	EventPromptCreated  = "prompt.created"
+	EventPromptUpdated  = "prompt.updated"
+	EventPromptDeleted  = "prompt.deleted"
+	EventPromptCounts   = "prompt.counts" // 点赞、收藏数变化
+	EventPromptStatus   = "prompt.status" // 审核状态变化，非 visible 时客户端应移除
+	EventCommentCreated = "comment.created"
+	EventCommentUpdated = "comment.updated"
+	EventCommentDeleted = "comment.deleted"
+	EventCommentStatus  = "comment.status"
+	EventModeration     = "moderation" // 版主处理了用户的内容，推送到作者的私有频道
+	EventNotification   = "notification"
+	EventFileProcessed  = "file.processed"
+	EventStreamReset    = "stream.reset" // 无法续传，客户端应重新拉取数据
+)
+
+// TopicPrompts 全站 prompt 动态：新建、修改、删除和计数变化
+const TopicPrompts = "prompts"
+
+// maxStreamTopics 单个连接最多订阅的 topic 数
+const maxStreamTopics = 100
+
+// Events 实时事件中心，InitStream 之前为 nil，此时发布的事件被丢弃
+var Events *stream.Hub
+
+// InitStream 按配置创建事件中心
+func InitStream() {
+	size := config.Cfg.Stream.BufferSize
+	if size <= 0 {
+		size = 1024
+	}
+	Events = stream.NewHub(size)
+}
+
+// PromptTopic 单个 prompt 的动态：修改、删除、计数变化和评论
+func PromptTopic(id uint) string {
+	return fmt.Sprintf("prompt:%d", id)
+}
+
+// UserTopic 用户私有频道：通知、文件处理结果，仅本人可订阅
+func UserTopic(id uint) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+// publish 在事务提交后调用
+func publish(typ string, data interface{}, topics ...string) {
+	if Events != nil {
+		Events.Publish(typ, data, topics...)
+	}
+}
+
+// publishPrompt 发布 prompt 事件到全站和该 prompt 的 topic
+// 推送时不知道订阅者的 nsfw 偏好，被标记的 prompt 只推送 id 与 nsfw 标记，客户端按偏好通过接口拉取详情
+func publishPrompt(typ string, id uint, data interface{}) {
+	if p, ok := data.(*model.Prompt); ok && p.NSFW {
+		data = map[string]interface{}{"id": p.ID, "nsfw": true}
+	}
+	publish(typ, data, TopicPrompts, PromptTopic(id))
+}
+
+// CheckStreamTopics 校验订阅的 topic：prompts、prompt:<id>，以及仅限本人的 user:<id>
+func CheckStreamTopics(userID uint, topics []string) error {
+	if len(topics) > maxStreamTopics {
+		return fmt.Errorf("%w: at most %d topics", ErrInvalidParam, maxStreamTopics)
+	}
+	for _, t := range topics {
+		if t == TopicPrompts {
+			continue
+		}
+		kind, idStr, _ := strings.Cut(t, ":")
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		switch {
+		case err != nil || id == 0 || (kind != "prompt" && kind != "user"):
+			return fmt.Errorf("%w: unknown topic %q", ErrInvalidParam, t)
+		case kind == "user" && uint(id) != userID:
+			return fmt.Errorf("%w: topic %q", ErrForbidden, t)
+		}
+	}
+	return nil
+}
+
+// SubscribeEvents 订阅实时事件，登录用户自动订阅自己的私有频道
+// 返回的错过事件与 resumed 含义见 stream.Hub.Subscribe
+func SubscribeEvents(userID uint, topics []string, lastEventID string) (*stream.Subscription, []stream.Event, bool, error) {
+	if err := CheckStreamTopics(userID, topics); err != nil {
+		return nil, nil, false, err
+	}
+	if Events == nil {
+		return nil, nil, false, fmt.Errorf("event stream is not initialized")
+	}
+	if userID != 0 {
+		topics = append(topics, UserTopic(userID))
+	}
+	sub, missed, resumed := Events.Subscribe(topics, lastEventID)
+	return sub, missed, resumed, nil
+}
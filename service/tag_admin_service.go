@@ -0,0 +1,329 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TagUpdate 标签可修改字段，nil 表示不修改
+type TagUpdate struct {
+	Name     *string `json:"name"`
+	ParentID *uint   `json:"parent_id"`
+	Banned   *bool   `json:"banned"`
+}
+
+// CreateTag 管理员预先创建标签（如提前禁用某个标签）
+func CreateTag(name string, parentID uint, banned bool) (*model.Tag, error) {
+	name = strings.TrimSpace(strings.Map(utils.FoldWidth, name))
+	slug := utils.Slugify(name)
+	if slug == "" {
+		return nil, utils.FieldErrors{"name": "is required"}
+	}
+	tag := model.Tag{Slug: slug, Name: name, Banned: banned}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := findTagBySlug(tx, slug); err == nil {
+			return utils.FieldErrors{"name": "tag already exists"}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := tx.Create(&tag).Error; err != nil {
+			return err
+		}
+		if parentID != 0 {
+			return setTagParent(tx, &tag, parentID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// UpdateTag 重命名、调整上级或禁用标签
+// 重命名后旧 slug 保留为别名，所有使用该标签的 prompt 与图片同步改写
+func UpdateTag(id uint, in TagUpdate) (*model.Tag, error) {
+	var tag model.Tag
+	var changed []uint
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&tag, id).Error; err != nil {
+			return err
+		}
+		if in.Name != nil {
+			var err error
+			if changed, err = renameTag(tx, &tag, *in.Name); err != nil {
+				return err
+			}
+		}
+		if in.ParentID != nil {
+			if err := setTagParent(tx, &tag, *in.ParentID); err != nil {
+				return err
+			}
+		}
+		if in.Banned != nil {
+			tag.Banned = *in.Banned
+			if err := tx.Model(&tag).UpdateColumn("banned", tag.Banned).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	queueEmbeddings(changed...)
+	return &tag, nil
+}
+
+func renameTag(tx *gorm.DB, tag *model.Tag, name string) ([]uint, error) {
+	name = strings.TrimSpace(strings.Map(utils.FoldWidth, name))
+	slug := utils.Slugify(name)
+	if slug == "" {
+		return nil, utils.FieldErrors{"name": "is required"}
+	}
+	if slug != tag.Slug {
+		existing, err := findTagBySlug(tx, slug)
+		if err == nil && existing.ID != tag.ID {
+			return nil, utils.FieldErrors{"name": fmt.Sprintf("conflicts with tag %q, merge the tags instead", existing.Name)}
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		// 新 slug 若是自己的别名则移除，旧 slug 保留为别名
+		if err := tx.Where("alias = ?", slug).Delete(&model.TagAlias{}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.TagAlias{Alias: tag.Slug, TagID: tag.ID}).Error; err != nil {
+			return nil, err
+		}
+	}
+	tag.Slug, tag.Name = slug, name
+	if err := tx.Model(tag).UpdateColumns(map[string]interface{}{"slug": slug, "name": name}).Error; err != nil {
+		return nil, err
+	}
+	return rewriteTagStrings(tx, []uint{tag.ID})
+}
+
+// setTagParent 设置上级标签，禁止形成环
+func setTagParent(tx *gorm.DB, tag *model.Tag, parentID uint) error {
+	if parentID != 0 {
+		if parentID == tag.ID {
+			return utils.FieldErrors{"parent_id": "a tag cannot be its own parent"}
+		}
+		var parent model.Tag
+		if err := tx.First(&parent, parentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.FieldErrors{"parent_id": "parent tag not found"}
+			}
+			return err
+		}
+		descendants, err := expandTagIDs(tx, []uint{tag.ID})
+		if err != nil {
+			return err
+		}
+		for _, d := range descendants {
+			if d == parentID {
+				return utils.FieldErrors{"parent_id": "would create a cycle"}
+			}
+		}
+	}
+	tag.ParentID = parentID
+	return tx.Model(tag).UpdateColumn("parent_id", parentID).Error
+}
+
+// MergeTag 将 source 合并到 target：关联、别名、下级全部转移，source 的 slug 成为别名，
+// 所有受影响的 prompt 与图片标签字符串在同一事务中改写
+func MergeTag(sourceID, targetID uint) (*model.Tag, error) {
+	if sourceID == targetID {
+		return nil, utils.FieldErrors{"into": "cannot merge a tag into itself"}
+	}
+	var target model.Tag
+	var promptIDs []uint
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var source model.Tag
+		if err := tx.First(&source, sourceID).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&target, targetID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.FieldErrors{"into": "target tag not found"}
+			}
+			return err
+		}
+
+		// 1. 记录受影响的 prompt 与图片，合并后改写字符串
+		var imgIDs []uint
+		if err := tx.Model(&model.PromptTag{}).Where("tag_id = ?", source.ID).
+			Distinct().Pluck("prompt_id", &promptIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.PromptImgTag{}).Where("tag_id = ?", source.ID).
+			Distinct().Pluck("prompt_img_id", &imgIDs).Error; err != nil {
+			return err
+		}
+
+		// 2. 转移关联，已同时拥有两个标签的记录忽略
+		if err := tx.Exec(`INSERT OR IGNORE INTO prompt_tags (prompt_id, tag_id, kind, position)
+			SELECT prompt_id, ?, kind, position FROM prompt_tags WHERE tag_id = ?`, target.ID, source.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", source.ID).Delete(&model.PromptTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`INSERT OR IGNORE INTO prompt_img_tags (prompt_img_id, tag_id, position)
+			SELECT prompt_img_id, ?, position FROM prompt_img_tags WHERE tag_id = ?`, target.ID, source.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", source.ID).Delete(&model.PromptImgTag{}).Error; err != nil {
+			return err
+		}
+
+		// 3. 别名与 slug
+		if err := tx.Model(&model.TagAlias{}).Where("tag_id = ?", source.ID).
+			UpdateColumn("tag_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.TagAlias{Alias: source.Slug, TagID: target.ID}).Error; err != nil {
+			return err
+		}
+
+		// 4. 层级：target 若是 source 的下级，先挂到 source 的上级，再接管 source 的下级
+		if target.ParentID != 0 {
+			descendants, err := expandTagIDs(tx, []uint{source.ID})
+			if err != nil {
+				return err
+			}
+			for _, d := range descendants {
+				if d == target.ID {
+					if err := tx.Model(&target).UpdateColumn("parent_id", source.ParentID).Error; err != nil {
+						return err
+					}
+					target.ParentID = source.ParentID
+					break
+				}
+			}
+		}
+		if err := tx.Model(&model.Tag{}).Where("parent_id = ? AND id <> ?", source.ID, target.ID).
+			UpdateColumn("parent_id", target.ID).Error; err != nil {
+			return err
+		}
+		if target.ParentID == source.ID {
+			if err := tx.Model(&target).UpdateColumn("parent_id", source.ParentID).Error; err != nil {
+				return err
+			}
+			target.ParentID = source.ParentID
+		}
+
+		if err := tx.Delete(&source).Error; err != nil {
+			return err
+		}
+		if err := rewritePromptTagStrings(tx, promptIDs); err != nil {
+			return err
+		}
+		if err := rewriteImageTagStrings(tx, imgIDs); err != nil {
+			return err
+		}
+		if err := recountTags(tx, []uint{target.ID}); err != nil {
+			return err
+		}
+		return tx.First(&target, target.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	queueEmbeddings(promptIDs...)
+	return &target, nil
+}
+
+// rewriteTagStrings 改写使用了指定标签的 prompt 与图片的标签字符串，返回受影响的 prompt
+func rewriteTagStrings(tx *gorm.DB, tagIDs []uint) ([]uint, error) {
+	var promptIDs, imgIDs []uint
+	if err := tx.Model(&model.PromptTag{}).Where("tag_id IN ?", tagIDs).
+		Distinct().Pluck("prompt_id", &promptIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&model.PromptImgTag{}).Where("tag_id IN ?", tagIDs).
+		Distinct().Pluck("prompt_img_id", &imgIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := rewritePromptTagStrings(tx, promptIDs); err != nil {
+		return nil, err
+	}
+	return promptIDs, rewriteImageTagStrings(tx, imgIDs)
+}
+
+// rewritePromptTagStrings 按关联表重建 prompt 的 tags / source_tags 字符串
+func rewritePromptTagStrings(tx *gorm.DB, promptIDs []uint) error {
+	if len(promptIDs) == 0 {
+		return nil
+	}
+	var rows []struct {
+		PromptID uint
+		Kind     string
+		Name     string
+	}
+	if err := tx.Table("prompt_tags").
+		Select("prompt_tags.prompt_id, prompt_tags.kind, tags.name").
+		Joins("JOIN tags ON tags.id = prompt_tags.tag_id").
+		Where("prompt_tags.prompt_id IN ?", promptIDs).
+		Order("prompt_tags.prompt_id, prompt_tags.kind, prompt_tags.position, tags.id").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	names := map[uint]map[string][]string{}
+	for _, r := range rows {
+		if names[r.PromptID] == nil {
+			names[r.PromptID] = map[string][]string{}
+		}
+		names[r.PromptID][r.Kind] = append(names[r.PromptID][r.Kind], r.Name)
+	}
+	for _, id := range promptIDs {
+		byKind := names[id]
+		if err := tx.Model(&model.Prompt{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+			"tags":        strings.Join(byKind[model.TagKindPrompt], ","),
+			"source_tags": strings.Join(byKind[model.TagKindSource], ","),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return indexPrompts(tx, promptIDs...)
+}
+
+// rewriteImageTagStrings 按关联表重建图片的 tags 字符串
+func rewriteImageTagStrings(tx *gorm.DB, imgIDs []uint) error {
+	if len(imgIDs) == 0 {
+		return nil
+	}
+	var rows []struct {
+		PromptImgID uint
+		Name        string
+	}
+	if err := tx.Table("prompt_img_tags").
+		Select("prompt_img_tags.prompt_img_id, tags.name").
+		Joins("JOIN tags ON tags.id = prompt_img_tags.tag_id").
+		Where("prompt_img_tags.prompt_img_id IN ?", imgIDs).
+		Order("prompt_img_tags.prompt_img_id, prompt_img_tags.position, tags.id").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	names := map[uint][]string{}
+	for _, r := range rows {
+		names[r.PromptImgID] = append(names[r.PromptImgID], r.Name)
+	}
+	for _, id := range imgIDs {
+		if err := tx.Model(&model.PromptImg{}).Where("id = ?", id).
+			UpdateColumn("tags", strings.Join(names[id], ",")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
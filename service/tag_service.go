@@ -0,0 +1,436 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// promptTagSet 一个 prompt 解析后的规范标签
+type promptTagSet struct {
+	tags   []model.Tag
+	source []model.Tag
+}
+
+// SplitTags 拆分逗号分隔的标签（兼容中文逗号），去掉空白与空标签
+func SplitTags(s string) []string {
+	s = strings.ReplaceAll(s, "，", ",")
+	joined := ParseTags(strings.Split(s, ","))
+	if joined == "" {
+		return nil
+	}
+	return strings.Split(joined, ",")
+}
+
+// findTagBySlug 按 slug 查找规范标签，别名优先映射
+func findTagBySlug(tx *gorm.DB, slug string) (*model.Tag, error) {
+	var tag model.Tag
+	var alias model.TagAlias
+	err := tx.Where("alias = ?", slug).Limit(1).Find(&alias).Error
+	if err != nil {
+		return nil, err
+	}
+	if alias.ID != 0 {
+		err = tx.First(&tag, alias.TagID).Error
+	} else {
+		err = tx.Where("slug = ?", slug).First(&tag).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// resolveTags 将标签名解析为规范标签，不存在时创建；结果按输入顺序去重
+func resolveTags(tx *gorm.DB, names []string) ([]model.Tag, error) {
+	var tags []model.Tag
+	seen := map[uint]bool{}
+	for _, name := range names {
+		slug := utils.Slugify(name)
+		if slug == "" {
+			continue
+		}
+		tag, err := findTagBySlug(tx, slug)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			created := model.Tag{Slug: slug, Name: strings.TrimSpace(strings.Map(utils.FoldWidth, name))}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&created).Error; err != nil {
+				return nil, err
+			}
+			tag, err = findTagBySlug(tx, slug)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag.ID] {
+			seen[tag.ID] = true
+			tags = append(tags, *tag)
+		}
+	}
+	return tags, nil
+}
+
+// lookupTagGroups 只查询不创建，用于过滤；每个标签展开为自身及全部下级
+// 返回每个标签对应的 id 组与未找到的数量
+func lookupTagGroups(names []string) ([][]uint, int, error) {
+	var groups [][]uint
+	missing := 0
+	for _, name := range names {
+		slug := utils.Slugify(name)
+		if slug == "" {
+			continue
+		}
+		tag, err := findTagBySlug(database.DB, slug)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			missing++
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		ids, err := expandTagIDs(database.DB, []uint{tag.ID})
+		if err != nil {
+			return nil, 0, err
+		}
+		groups = append(groups, ids)
+	}
+	return groups, missing, nil
+}
+
+// expandTagIDs 返回给定标签及其全部下级标签
+func expandTagIDs(tx *gorm.DB, ids []uint) ([]uint, error) {
+	var out []uint
+	err := tx.Raw(`
+		WITH RECURSIVE sub(id) AS (
+			SELECT id FROM tags WHERE id IN ?
+			UNION
+			SELECT tags.id FROM tags JOIN sub ON tags.parent_id = sub.id
+		)
+		SELECT id FROM sub`, ids).Scan(&out).Error
+	return out, err
+}
+
+// checkBannedTags 存在禁用标签时返回字段错误
+func checkBannedTags(field string, tags []model.Tag) error {
+	var banned []string
+	for _, t := range tags {
+		if t.Banned {
+			banned = append(banned, t.Name)
+		}
+	}
+	if len(banned) > 0 {
+		return utils.FieldErrors{field: "banned tags: " + strings.Join(banned, ", ")}
+	}
+	return nil
+}
+
+func tagNames(tags []model.Tag) string {
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func tagIDs(tags []model.Tag) []uint {
+	ids := make([]uint, len(tags))
+	for i, t := range tags {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+// resolvePromptTags 解析 prompt 的标签与来源标签，并把字符串字段改写为规范名称
+func resolvePromptTags(tx *gorm.DB, p *model.Prompt) (*promptTagSet, error) {
+	tags, err := resolveTags(tx, SplitTags(p.Tags))
+	if err != nil {
+		return nil, err
+	}
+	source, err := resolveTags(tx, SplitTags(p.SourceTags))
+	if err != nil {
+		return nil, err
+	}
+	p.Tags, p.SourceTags = tagNames(tags), tagNames(source)
+	return &promptTagSet{tags: tags, source: source}, nil
+}
+
+// checkBanned 校验 prompt 是否使用了禁用标签
+func (s *promptTagSet) checkBanned() error {
+	errs := utils.FieldErrors{}
+	for field, tags := range map[string][]model.Tag{"tags": s.tags, "source_tags": s.source} {
+		var fe utils.FieldErrors
+		if err := checkBannedTags(field, tags); errors.As(err, &fe) {
+			errs.Add(field, fe[field])
+		}
+	}
+	return errs.Err()
+}
+
+// save 覆盖写入 prompt 的标签关联并刷新使用次数
+func (s *promptTagSet) save(tx *gorm.DB, promptID uint) error {
+	var oldIDs []uint
+	if err := tx.Model(&model.PromptTag{}).Where("prompt_id = ?", promptID).
+		Pluck("tag_id", &oldIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("prompt_id = ?", promptID).Delete(&model.PromptTag{}).Error; err != nil {
+		return err
+	}
+
+	var rows []model.PromptTag
+	for i, t := range s.tags {
+		rows = append(rows, model.PromptTag{PromptID: promptID, TagID: t.ID, Kind: model.TagKindPrompt, Position: i})
+	}
+	for i, t := range s.source {
+		rows = append(rows, model.PromptTag{PromptID: promptID, TagID: t.ID, Kind: model.TagKindSource, Position: i})
+	}
+	if len(rows) > 0 {
+		if err := tx.Create(&rows).Error; err != nil {
+			return err
+		}
+	}
+	return recountTags(tx, append(oldIDs, tagIDs(s.tags)...))
+}
+
+// recountTags 重新统计标签被多少个 prompt 使用，回收站中的 prompt 不计入
+func recountTags(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Model(&model.Tag{}).Where("id IN ?", ids).
+		UpdateColumn("usage_count", gorm.Expr(
+			`(SELECT COUNT(DISTINCT prompt_id) FROM prompt_tags WHERE prompt_tags.tag_id = tags.id AND prompt_tags.kind = ?
+				AND prompt_id IN (SELECT id FROM prompts WHERE deleted_at IS NULL))`,
+			model.TagKindPrompt)).Error
+}
+
+// recountPromptTags 移入或移出回收站后刷新 prompt 所用标签的使用次数
+func recountPromptTags(tx *gorm.DB, promptID uint) error {
+	var ids []uint
+	if err := tx.Model(&model.PromptTag{}).Where("prompt_id = ?", promptID).Pluck("tag_id", &ids).Error; err != nil {
+		return err
+	}
+	return recountTags(tx, ids)
+}
+
+// syncImageTags 解析图片标签，改写为规范名称并覆盖写入关联
+// checkBanned 为 true 时拒绝禁用标签，历史数据回填时不校验
+func syncImageTags(tx *gorm.DB, imgs []model.PromptImg, checkBanned bool) error {
+	for i := range imgs {
+		tags, err := resolveTags(tx, SplitTags(imgs[i].Tags))
+		if err != nil {
+			return err
+		}
+		if checkBanned {
+			if err := checkBannedTags(fmt.Sprintf("images[%d].tags", i), tags); err != nil {
+				return err
+			}
+		}
+		imgs[i].Tags = tagNames(tags)
+		if err := tx.Model(&model.PromptImg{}).Where("id = ?", imgs[i].ID).
+			UpdateColumn("tags", imgs[i].Tags).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("prompt_img_id = ?", imgs[i].ID).Delete(&model.PromptImgTag{}).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			continue
+		}
+		rows := make([]model.PromptImgTag, len(tags))
+		for j, t := range tags {
+			rows[j] = model.PromptImgTag{PromptImgID: imgs[i].ID, TagID: t.ID, Position: j}
+		}
+		if err := tx.Create(&rows).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletePromptImagesTx 删除 prompt 的全部图片及其标签关联
+func deletePromptImagesTx(tx *gorm.DB, promptID uint) error {
+	if err := tx.Where("prompt_img_id IN (?)",
+		tx.Model(&model.PromptImg{}).Select("id").Where("prompt_id = ?", promptID)).
+		Delete(&model.PromptImgTag{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("prompt_id = ?", promptID).Delete(&model.PromptImg{}).Error
+}
+
+// ListTags 标签列表，按使用次数倒序；q 用于自动补全，按 slug、名称或别名前缀匹配
+// includeBanned 为 false 时不返回禁用标签
+func ListTags(q string, includeBanned bool, req PageRequest) ([]model.Tag, PageInfo, error) {
+	db := database.DB.Model(&model.Tag{})
+	if !includeBanned {
+		db = db.Where("banned = ?", false)
+	}
+	if q = strings.TrimSpace(q); q != "" {
+		slug := utils.Slugify(q)
+		db = db.Where("slug LIKE ? OR name LIKE ? OR id IN (?)", slug+"%", q+"%",
+			database.DB.Model(&model.TagAlias{}).Select("tag_id").Where("alias LIKE ?", slug+"%"))
+	}
+	ks := keyset{scope: "tags", columns: []string{"usage_count desc", "name asc", "id asc"}}
+	list, info, err := paginate(db, req, ks, func(t *model.Tag) []interface{} {
+		return []interface{}{t.UsageCount, t.Name, t.ID}
+	})
+	if err != nil {
+		return nil, info, err
+	}
+	if err := fillTagAliases(list); err != nil {
+		return nil, info, err
+	}
+	return list, info, nil
+}
+
+func fillTagAliases(list []model.Tag) error {
+	if len(list) == 0 {
+		return nil
+	}
+	var aliases []model.TagAlias
+	if err := database.DB.Where("tag_id IN ?", tagIDs(list)).Order("alias asc").Find(&aliases).Error; err != nil {
+		return err
+	}
+	byTag := map[uint][]string{}
+	for _, a := range aliases {
+		byTag[a.TagID] = append(byTag[a.TagID], a.Alias)
+	}
+	for i := range list {
+		list[i].Aliases = byTag[list[i].ID]
+	}
+	return nil
+}
+
+// AddTagAlias 为标签添加别名，别名不能与已有标签或别名冲突
+func AddTagAlias(tagID uint, alias string) (*model.TagAlias, error) {
+	slug := utils.Slugify(alias)
+	if slug == "" {
+		return nil, utils.FieldErrors{"alias": "is required"}
+	}
+	var a model.TagAlias
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var tag model.Tag
+		if err := tx.First(&tag, tagID).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&model.Tag{}).Where("slug = ?", slug).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return utils.FieldErrors{"alias": "already used by a tag, merge the tags instead"}
+		}
+		if err := tx.Model(&model.TagAlias{}).Where("alias = ?", slug).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return utils.FieldErrors{"alias": "already exists"}
+		}
+		a = model.TagAlias{Alias: slug, TagID: tag.ID}
+		return tx.Create(&a).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// RemoveTagAlias 删除别名
+func RemoveTagAlias(tagID uint, alias string) error {
+	return database.DB.Where("tag_id = ? AND alias = ?", tagID, utils.Slugify(alias)).Delete(&model.TagAlias{}).Error
+}
+
+// reslugTags 按当前规则重新生成含 + # & @ 的标签的 slug，新 slug 已被占用时保留原值
+func reslugTags() error {
+	var tags []model.Tag
+	if err := database.DB.Select("id", "slug", "name").Where("name GLOB ?", "*[+#&@＋＃＆＠]*").Find(&tags).Error; err != nil {
+		return err
+	}
+	updated := 0
+	for _, t := range tags {
+		slug := utils.Slugify(t.Name)
+		if slug == "" || slug == t.Slug {
+			continue
+		}
+		var taken int64
+		if err := database.DB.Model(&model.Tag{}).Where("slug = ?", slug).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken == 0 {
+			if err := database.DB.Model(&model.TagAlias{}).Where("alias = ?", slug).Count(&taken).Error; err != nil {
+				return err
+			}
+		}
+		if taken > 0 {
+			log.Printf("tag %d %q: slug %q is taken, keeping %q", t.ID, t.Name, slug, t.Slug)
+			continue
+		}
+		if err := database.DB.Model(&model.Tag{}).Where("id = ?", t.ID).UpdateColumn("slug", slug).Error; err != nil {
+			return err
+		}
+		updated++
+	}
+	if updated > 0 {
+		log.Printf("updated slugs of %d tags", updated)
+	}
+	return nil
+}
+
+// MigrateTags 将历史的逗号分隔标签回填到规范化标签表，可重复执行
+func MigrateTags() error {
+	if err := reslugTags(); err != nil {
+		return err
+	}
+
+	var prompts []model.Prompt
+	if err := database.DB.Select("id", "tags", "source_tags").
+		Where("(tags <> '' OR source_tags <> '') AND id NOT IN (?)",
+			database.DB.Model(&model.PromptTag{}).Select("prompt_id")).
+		Find(&prompts).Error; err != nil {
+		return err
+	}
+	for i := range prompts {
+		p := &prompts[i]
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			set, err := resolvePromptTags(tx, p)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Prompt{}).Where("id = ?", p.ID).
+				UpdateColumns(map[string]interface{}{"tags": p.Tags, "source_tags": p.SourceTags}).Error; err != nil {
+				return err
+			}
+			if err := set.save(tx, p.ID); err != nil {
+				return err
+			}
+			return indexPrompts(tx, p.ID)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var imgs []model.PromptImg
+	if err := database.DB.Where("tags <> '' AND id NOT IN (?)",
+		database.DB.Model(&model.PromptImgTag{}).Select("prompt_img_id")).
+		Find(&imgs).Error; err != nil {
+		return err
+	}
+	if len(imgs) > 0 {
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			return syncImageTags(tx, imgs, false)
+		}); err != nil {
+			return err
+		}
+	}
+	if len(prompts) > 0 || len(imgs) > 0 {
+		log.Printf("migrated tags for %d prompts and %d images", len(prompts), len(imgs))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"slices"
+	"testing"
+)
+
+func TestSymbolTagsGetDistinctSlugs(t *testing.T) {
+	setupTestDB(t)
+	u := createTestUser(t, "alice")
+	// 旧规则下 C++ 的 slug 为 "c"
+	legacy := model.Tag{Slug: "c", Name: "C++"}
+	if err := database.DB.Create(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateTags(); err != nil {
+		t.Fatal(err)
+	}
+	var got model.Tag
+	if err := database.DB.First(&got, legacy.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Slug != "c-plus-plus" {
+		t.Errorf("migrated slug = %q, want c-plus-plus", got.Slug)
+	}
+
+	p := &model.Prompt{UserID: u.ID, Title: "t", Content: "c", Tags: "C++,C#,C"}
+	if err := CreatePrompt(p); err != nil {
+		t.Fatal(err)
+	}
+	var slugs []string
+	if err := database.DB.Model(&model.Tag{}).
+		Joins("JOIN prompt_tags ON prompt_tags.tag_id = tags.id").
+		Where("prompt_tags.prompt_id = ?", p.ID).Order("prompt_tags.position").Pluck("tags.slug", &slugs).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"c-plus-plus", "c-sharp", "c"}
+	if !slices.Equal(slugs, want) {
+		t.Errorf("prompt tags = %v, want %v", slugs, want)
+	}
+}
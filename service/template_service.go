@@ -0,0 +1,255 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxTemplateVariables  = 50
+	maxVariableDescLen    = 200
+	maxVariableOptions    = 100
+	maxRenderedValueRunes = 1000
+)
+
+var (
+	// placeholderRe 匹配 {{ ... }}，内部再校验是否为合法变量名
+	placeholderRe  = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+	variableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// templatePlaceholders 按出现顺序返回 content 中引用的变量名（去重）以及非法占位符
+func templatePlaceholders(content string) (names []string, invalid []string) {
+	seen := map[string]bool{}
+	for _, m := range placeholderRe.FindAllStringSubmatch(content, -1) {
+		name := m[1]
+		if !variableNameRe.MatchString(name) {
+			invalid = append(invalid, m[0])
+			continue
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, invalid
+}
+
+// CheckTemplate 校验变量声明与 content 中的引用是否一致
+// 未声明就引用、声明了却未使用、类型或默认值非法都会返回字段错误
+func CheckTemplate(content string, vars []model.PromptVariable) utils.FieldErrors {
+	errs := utils.FieldErrors{}
+	if len(vars) > maxTemplateVariables {
+		errs.Add("variables", fmt.Sprintf("must have at most %d variables", maxTemplateVariables))
+		return errs
+	}
+
+	declared := make(map[string]bool, len(vars))
+	for i := range vars {
+		v := &vars[i]
+		field := fmt.Sprintf("variables[%d]", i)
+		if v.Type == "" {
+			v.Type = model.VarTypeString
+		}
+		if !variableNameRe.MatchString(v.Name) {
+			errs.Add(field+".name", "must start with a letter or underscore and contain only letters, digits and underscores")
+			continue
+		}
+		if declared[v.Name] {
+			errs.Add(field+".name", fmt.Sprintf("duplicate variable %q", v.Name))
+			continue
+		}
+		declared[v.Name] = true
+
+		switch v.Type {
+		case model.VarTypeString, model.VarTypeNumber, model.VarTypeInteger, model.VarTypeBoolean:
+			if len(v.Options) > 0 {
+				errs.Add(field+".options", "only allowed for enum variables")
+			}
+		case model.VarTypeEnum:
+			if len(v.Options) == 0 {
+				errs.Add(field+".options", "enum variables need at least one option")
+			} else if len(v.Options) > maxVariableOptions {
+				errs.Add(field+".options", fmt.Sprintf("must have at most %d options", maxVariableOptions))
+			}
+			seen := map[string]bool{}
+			for _, o := range v.Options {
+				if seen[o] {
+					errs.Add(field+".options", fmt.Sprintf("duplicate option %q", o))
+				}
+				seen[o] = true
+			}
+		default:
+			errs.Add(field+".type", "must be one of string, number, integer, boolean, enum")
+			continue
+		}
+		if utf8.RuneCountInString(v.Description) > maxVariableDescLen {
+			errs.Add(field+".description", fmt.Sprintf("must be at most %d characters", maxVariableDescLen))
+		}
+		if v.Default != nil {
+			normalized, err := coerceVariableValue(v, v.Default)
+			if err != "" {
+				errs.Add(field+".default", err)
+			} else {
+				v.Default = normalized
+			}
+		}
+	}
+
+	used, invalid := templatePlaceholders(content)
+	for _, ph := range invalid {
+		errs.Add("content", fmt.Sprintf("invalid placeholder %s", ph))
+	}
+	var undefined []string
+	usedSet := make(map[string]bool, len(used))
+	for _, name := range used {
+		usedSet[name] = true
+		if !declared[name] {
+			undefined = append(undefined, name)
+		}
+	}
+	if len(undefined) > 0 {
+		errs.Add("content", "undefined variables: "+strings.Join(undefined, ", "))
+	}
+	var unused []string
+	for _, v := range vars {
+		if declared[v.Name] && !usedSet[v.Name] {
+			unused = append(unused, v.Name)
+		}
+	}
+	if len(unused) > 0 {
+		errs.Add("variables", "unused variables: "+strings.Join(unused, ", "))
+	}
+	return errs
+}
+
+// coerceVariableValue 按变量类型校验并规范化取值，返回错误描述
+func coerceVariableValue(v *model.PromptVariable, value interface{}) (interface{}, string) {
+	switch v.Type {
+	case model.VarTypeNumber, model.VarTypeInteger:
+		var f float64
+		switch x := value.(type) {
+		case float64:
+			f = x
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+			if err != nil {
+				return nil, "must be a number"
+			}
+			f = parsed
+		default:
+			return nil, "must be a number"
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, "must be a finite number"
+		}
+		if v.Type == model.VarTypeInteger && f != math.Trunc(f) {
+			return nil, "must be an integer"
+		}
+		return f, ""
+	case model.VarTypeBoolean:
+		switch x := value.(type) {
+		case bool:
+			return x, ""
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(x))
+			if err != nil {
+				return nil, "must be a boolean"
+			}
+			return b, ""
+		}
+		return nil, "must be a boolean"
+	case model.VarTypeEnum:
+		s, ok := value.(string)
+		if !ok {
+			return nil, "must be a string"
+		}
+		for _, o := range v.Options {
+			if o == s {
+				return s, ""
+			}
+		}
+		return nil, "must be one of: " + strings.Join(v.Options, ", ")
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return nil, "must be a string"
+		}
+		if utf8.RuneCountInString(s) > maxRenderedValueRunes {
+			return nil, fmt.Sprintf("must be at most %d characters", maxRenderedValueRunes)
+		}
+		return s, ""
+	}
+}
+
+func formatVariableValue(value interface{}) string {
+	switch x := value.(type) {
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case string:
+		return x
+	}
+	return fmt.Sprint(value)
+}
+
+// RenderTemplate 使用给定取值填充模板，缺失的变量使用默认值
+// 返回填充后的文本以及实际使用的取值
+func RenderTemplate(content string, vars []model.PromptVariable, values map[string]interface{}) (string, map[string]interface{}, error) {
+	errs := utils.FieldErrors{}
+	byName := make(map[string]*model.PromptVariable, len(vars))
+	for i := range vars {
+		byName[vars[i].Name] = &vars[i]
+	}
+	for name := range values {
+		if _, ok := byName[name]; !ok {
+			errs.Add("values."+name, "is not a declared variable")
+		}
+	}
+
+	resolved := make(map[string]interface{}, len(vars))
+	for _, v := range vars {
+		raw, ok := values[v.Name]
+		if !ok || raw == nil {
+			if v.Default == nil {
+				errs.Add("values."+v.Name, "is required")
+				continue
+			}
+			raw = v.Default
+		}
+		val, msg := coerceVariableValue(byName[v.Name], raw)
+		if msg != "" {
+			errs.Add("values."+v.Name, msg)
+			continue
+		}
+		resolved[v.Name] = val
+	}
+	if len(errs) > 0 {
+		return "", nil, errs
+	}
+
+	text := placeholderRe.ReplaceAllStringFunc(content, func(m string) string {
+		name := placeholderRe.FindStringSubmatch(m)[1]
+		if val, ok := resolved[name]; ok {
+			return formatVariableValue(val)
+		}
+		return m
+	})
+	return text, resolved, nil
+}
+
+// RenderPrompt 渲染指定 prompt 的模板
+func RenderPrompt(id uint, values map[string]interface{}) (string, map[string]interface{}, error) {
+	p, err := GetPromptByID(id)
+	if err != nil {
+		return "", nil, err
+	}
+	return RenderTemplate(p.Content, p.Variables, values)
+}
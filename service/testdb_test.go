@@ -0,0 +1,62 @@
+package service
+
+import (
+	"path/filepath"
+	"prompt-share-backend/config"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"testing"
+)
+
+// setupTestDB 在临时目录创建独立的 sqlite 数据库并替换 database.DB
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	config.Cfg = &config.Config{
+		Database:  config.DatabaseConfig{Driver: "sqlite", Path: filepath.Join(dir, "test.db")},
+		Storage:   config.StorageConfig{Local: config.LocalStorageConfig{BasePath: filepath.Join(dir, "files")}},
+		Search:    config.SearchConfig{AllowLikeFallback: true},
+		Embedding: config.EmbeddingConfig{Provider: "hash", Dim: 64},
+		Trash:     config.TrashConfig{RetentionDays: 30},
+	}
+	db, err := database.Open(config.Cfg.Database.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+	imageHashes.tree = utils.BKTree{}
+	InitStorage()
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := InitModelSchemas(); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createTestUser 直接写入一个普通用户
+func createTestUser(t *testing.T, name string) *model.User {
+	t.Helper()
+	u := &model.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}
+	if err := database.DB.Create(u).Error; err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// createTestFile 直接写入一张已计算感知哈希的图片并加入索引
+func createTestFile(t *testing.T, uploaderID uint, phash uint64) *model.File {
+	t.Helper()
+	f := &model.File{UploaderID: uploaderID, Name: "img.png", Type: "image/png", Path: "img.png", PHash: utils.FormatHash(phash)}
+	if err := database.DB.Create(f).Error; err != nil {
+		t.Fatal(err)
+	}
+	addImageHash(f.PHash, f.ID)
+	return f
+}
@@ -0,0 +1,397 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"prompt-share-backend/config"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// defaultTrashRetentionDays 未配置时回收站的保留天数
+const defaultTrashRetentionDays = 30
+
+// trashPurgeInterval 清理过期回收站内容的间隔
+const trashPurgeInterval = 24 * time.Hour
+
+// trashTypes 回收站中的内容类型，按此顺序清理：prompt 会连带删除其评论
+var trashTypes = []string{model.TargetPrompt, model.TargetComment, model.TargetFile}
+
+// trashSQL 已删除的 prompt、评论和文件，owner_id 为作者或上传者
+const trashSQL = `SELECT 'prompt' AS type, id, title, 0 AS prompt_id, user_id AS owner_id, deleted_by, deleted_at
+	FROM prompts WHERE deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'comment', id, substr(content, 1, 100), prompt_id, user_id, deleted_by, deleted_at
+	FROM comments WHERE deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'file', id, name, 0, uploader_id, deleted_by, deleted_at
+	FROM files WHERE deleted_at IS NOT NULL`
+
+// TrashItem 回收站中的一项
+type TrashItem struct {
+	Type      string    `json:"type"`      // prompt、comment 或 file
+	ID        uint      `json:"id"`        // 内容 id
+	Title     string    `json:"title"`     // prompt 标题、评论开头或文件名
+	PromptID  uint      `json:"prompt_id"` // 评论所属的 prompt
+	OwnerID   uint      `json:"owner_id"`
+	DeletedBy uint      `json:"deleted_by"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `gorm:"-" json:"purge_at"` // 超过该时间后永久删除，不能再恢复
+}
+
+func trashRetention() time.Duration {
+	days := config.Cfg.Trash.RetentionDays
+	if days <= 0 {
+		days = defaultTrashRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func trashTable(db *gorm.DB) *gorm.DB {
+	return db.Table("(?) AS trash", db.Raw(trashSQL))
+}
+
+// InitTrash 定期永久删除超过保留期的回收站内容
+func InitTrash() {
+	go func() {
+		for ; ; time.Sleep(trashPurgeInterval) {
+			if n, err := PurgeTrash(); err != nil {
+				log.Println("purge trash failed:", err)
+			} else if n > 0 {
+				log.Printf("purged %d trash items", n)
+			}
+		}
+	}()
+}
+
+// ListTrash 分页查询用户自己删除、仍可恢复的内容，最近删除的在前；typ 为空表示全部类型
+// 版主删除的内容不进入作者的回收站
+func ListTrash(userID uint, typ string, req PageRequest) ([]TrashItem, PageInfo, error) {
+	errs := utils.FieldErrors{}
+	checkOneOf(errs, "type", typ, trashTypes...)
+	if err := errs.Err(); err != nil {
+		return nil, PageInfo{}, err
+	}
+	db := trashTable(database.DB).Where("owner_id = ? AND deleted_by = ?", userID, userID).
+		Where("julianday(deleted_at) >= julianday(?)", time.Now().Add(-trashRetention()))
+	if typ != "" {
+		db = db.Where("type = ?", typ)
+	}
+	ks := keyset{scope: "trash", columns: []string{"deleted_at desc", "type asc", "id desc"}}
+	list, info, err := paginate(db, req, ks, func(t *TrashItem) []interface{} {
+		return []interface{}{t.DeletedAt, t.Type, t.ID}
+	})
+	for i := range list {
+		list[i].PurgeAt = list[i].DeletedAt.Add(trashRetention())
+	}
+	return list, info, err
+}
+
+// trashItem 查询回收站中的内容并校验权限：本人删除的内容可由本人处理，版主可以处理全部
+func trashItem(tx *gorm.DB, typ string, id, userID uint) (*TrashItem, error) {
+	errs := utils.FieldErrors{}
+	checkOneOf(errs, "type", typ, trashTypes...)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	var item TrashItem
+	if err := trashTable(tx).Where("type = ? AND id = ?", typ, id).First(&item).Error; err != nil {
+		return nil, err
+	}
+	item.PurgeAt = item.DeletedAt.Add(trashRetention())
+	if item.OwnerID != userID || item.DeletedBy != userID {
+		moderator, err := canModerate(userID)
+		if err != nil {
+			return nil, err
+		}
+		if !moderator {
+			return nil, gorm.ErrRecordNotFound
+		}
+	}
+	return &item, nil
+}
+
+// RestoreTrash 在保留期内恢复已删除的内容，恢复后保持删除前的审核状态
+// 评论所属的 prompt 仍在回收站时需先恢复 prompt
+func RestoreTrash(typ string, id, userID uint) (*TrashItem, error) {
+	var item *TrashItem
+	var change *statusChange
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if item, err = trashItem(tx, typ, id, userID); err != nil {
+			return err
+		}
+		if time.Now().After(item.PurgeAt) {
+			return fmt.Errorf("%w: the retention window has passed", ErrNotFound)
+		}
+		switch typ {
+		case model.TargetPrompt:
+			change, err = restorePromptTx(tx, id)
+		case model.TargetComment:
+			change, err = restoreCommentTx(tx, id, item.PromptID)
+		case model.TargetFile:
+			err = tx.Unscoped().Model(&model.File{}).Where("id = ?", id).
+				UpdateColumns(map[string]interface{}{"deleted_at": nil, "deleted_by": 0}).Error
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case model.TargetPrompt:
+		queueEmbeddings(id)
+	case model.TargetFile:
+		var f model.File
+		if err := database.DB.Select("id", "p_hash").First(&f, id).Error; err == nil {
+			addImageHash(f.PHash, f.ID)
+		}
+	}
+	change.publish()
+	return item, nil
+}
+
+// restorePromptTx 恢复 prompt 的检索索引与标签使用次数
+func restorePromptTx(tx *gorm.DB, id uint) (*statusChange, error) {
+	if err := tx.Unscoped().Model(&model.Prompt{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"deleted_at": nil, "deleted_by": 0}).Error; err != nil {
+		return nil, err
+	}
+	var p model.Prompt
+	if err := tx.Select("id", "status").First(&p, id).Error; err != nil {
+		return nil, err
+	}
+	if err := indexPrompts(tx, id); err != nil {
+		return nil, err
+	}
+	if err := recountPromptTags(tx, id); err != nil {
+		return nil, err
+	}
+	return &statusChange{targetType: model.TargetPrompt, id: id, status: p.Status}, nil
+}
+
+// restoreCommentTx 恢复评论，公开的评论同时加回 prompt 的评论数
+func restoreCommentTx(tx *gorm.DB, id, promptID uint) (*statusChange, error) {
+	var n int64
+	if err := tx.Model(&model.Prompt{}).Where("id = ?", promptID).Count(&n).Error; err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrPromptTrashed
+	}
+	var c model.Comment
+	if err := tx.Select("id", "prompt_id", "status").First(&c, id).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&model.Comment{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"deleted_at": nil, "deleted_by": 0}).Error; err != nil {
+		return nil, err
+	}
+	change := &statusChange{targetType: model.TargetComment, id: id, promptID: promptID, status: c.Status}
+	if c.Status == model.StatusVisible {
+		change.count = new(int64)
+		if err := changeCommentCount(tx, promptID, 1, change.count); err != nil {
+			return nil, err
+		}
+	}
+	return change, nil
+}
+
+// PurgeTrashItem 不等保留期结束，立即永久删除回收站中的内容
+func PurgeTrashItem(typ string, id, userID uint) (*TrashItem, error) {
+	item, err := trashItem(database.DB, typ, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := purgeTrashItem(typ, id); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// PurgeTrash 永久删除超过保留期的内容，返回删除的条数
+func PurgeTrash() (int, error) {
+	cutoff := time.Now().Add(-trashRetention())
+	purged := 0
+	for _, typ := range trashTypes {
+		var ids []uint
+		if err := trashTable(database.DB).Where("type = ? AND julianday(deleted_at) < julianday(?)", typ, cutoff).
+			Order("id desc").Pluck("id", &ids).Error; err != nil {
+			return purged, err
+		}
+		// 回复的 id 大于被回复的评论，倒序处理使父评论在子评论删除后也能删除
+		for _, id := range ids {
+			ok, err := purgeTrashItem(typ, id)
+			if errors.Is(err, errFileInUse) {
+				continue
+			}
+			if err != nil {
+				return purged, fmt.Errorf("%s %d: %w", typ, id, err)
+			}
+			if ok {
+				purged++
+			}
+		}
+	}
+	return purged, nil
+}
+
+// purgeTrashItem 永久删除一项，返回是否有数据被删除
+func purgeTrashItem(typ string, id uint) (bool, error) {
+	if typ == model.TargetFile {
+		return purgeFile(id)
+	}
+	var ok bool
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if typ == model.TargetPrompt {
+			ok, err = purgePromptTx(tx, id)
+		} else {
+			ok, err = purgeCommentTx(tx, id)
+		}
+		return err
+	})
+	return ok, err
+}
+
+// purgePromptTx 删除 prompt 及其图片、标签、评论、点赞收藏、版本、向量、举报和通知
+func purgePromptTx(tx *gorm.DB, id uint) (bool, error) {
+	var p model.Prompt
+	if err := tx.Unscoped().Select("id").Limit(1).Find(&p, id).Error; err != nil || p.ID == 0 {
+		return false, err
+	}
+	var tagIDs []uint
+	if err := tx.Model(&model.PromptTag{}).Where("prompt_id = ?", id).Pluck("tag_id", &tagIDs).Error; err != nil {
+		return false, err
+	}
+	if err := removeFromCollections(tx, id); err != nil {
+		return false, err
+	}
+	if err := deletePromptImagesTx(tx, id); err != nil {
+		return false, err
+	}
+	comments := tx.Model(&model.Comment{}).Select("id").Where("prompt_id = ?", id)
+	if err := deleteCommentRefs(tx, comments); err != nil {
+		return false, err
+	}
+	notifications := tx.Model(&model.Notification{}).Select("id").Where("prompt_id = ?", id)
+	if err := tx.Where("notification_id IN (?)", notifications).Delete(&model.NotificationActor{}).Error; err != nil {
+		return false, err
+	}
+	for _, m := range []interface{}{&model.Comment{}, &model.Notification{}, &model.PromptTag{}, &model.PromptLike{},
+		&model.PromptFavorite{}, &model.PromptRevision{}, &model.PromptEmbedding{}} {
+		if err := tx.Where("prompt_id = ?", id).Delete(m).Error; err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Where("target_type = ? AND target_id = ?", model.TargetPrompt, id).Delete(&model.Report{}).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Unscoped().Delete(&model.Prompt{}, id).Error; err != nil {
+		return false, err
+	}
+	return true, recountTags(tx, tagIDs)
+}
+
+// removeFromCollections 从所有收藏夹中移除 prompt，封面取自该 prompt 时一并清空
+func removeFromCollections(tx *gorm.DB, promptID uint) error {
+	var colIDs []uint
+	if err := tx.Model(&model.CollectionItem{}).Where("prompt_id = ?", promptID).
+		Pluck("collection_id", &colIDs).Error; err != nil || len(colIDs) == 0 {
+		return err
+	}
+	if err := tx.Model(&model.Collection{}).Where("id IN ?", colIDs).
+		UpdateColumn("item_count", gorm.Expr("max(item_count - 1, 0)")).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&model.Collection{}).Where("id IN ? AND cover_img_id IN (?)", colIDs,
+		tx.Model(&model.PromptImg{}).Select("id").Where("prompt_id = ?", promptID)).
+		UpdateColumns(map[string]interface{}{"cover_img_id": 0, "cover_url": ""}).Error; err != nil {
+		return err
+	}
+	return tx.Where("prompt_id = ?", promptID).Delete(&model.CollectionItem{}).Error
+}
+
+// deleteCommentRefs 删除评论的提及、表情回应和举报，ids 为评论 id 子查询
+func deleteCommentRefs(tx *gorm.DB, ids *gorm.DB) error {
+	if err := tx.Where("comment_id IN (?)", ids).Delete(&model.CommentMention{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("comment_id IN (?)", ids).Delete(&model.CommentReaction{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("target_type = ? AND target_id IN (?)", model.TargetComment, ids).Delete(&model.Report{}).Error
+}
+
+// purgeCommentTx 永久删除已删除的评论；仍有回复时只清空内容，保留占位维持楼层结构
+func purgeCommentTx(tx *gorm.DB, id uint) (bool, error) {
+	var c model.Comment
+	if err := tx.Select("id", "parent_id", "content", "content_html").Where("deleted_at IS NOT NULL").
+		Limit(1).Find(&c, id).Error; err != nil || c.ID == 0 {
+		return false, err
+	}
+	ids := tx.Model(&model.Comment{}).Select("id").Where("id = ?", id)
+	if err := deleteCommentRefs(tx, ids); err != nil {
+		return false, err
+	}
+	var replies int64
+	if err := tx.Model(&model.Comment{}).Where("parent_id = ?", id).Count(&replies).Error; err != nil {
+		return false, err
+	}
+	if replies > 0 {
+		if c.Content == "" && c.ContentHTML == "" {
+			return false, nil
+		}
+		return true, tx.Model(&model.Comment{}).Where("id = ?", id).
+			UpdateColumns(map[string]interface{}{"content": "", "content_html": ""}).Error
+	}
+	if err := tx.Delete(&model.Comment{}, id).Error; err != nil {
+		return false, err
+	}
+	if c.ParentID == 0 {
+		return true, nil
+	}
+	return true, tx.Model(&model.Comment{}).Where("id = ?", c.ParentID).
+		UpdateColumn("reply_count", gorm.Expr("max(reply_count - 1, 0)")).Error
+}
+
+// errFileInUse 文件仍被 prompt 图片或历史版本引用，清理时跳过
+var errFileInUse = fmt.Errorf("%w: file is still used by a prompt or one of its revisions", ErrInvalidParam)
+
+// fileReferenced 文件是否仍被 prompt 图片（含回收站中的 prompt）或历史版本的图片快照引用
+func fileReferenced(tx *gorm.DB, id uint) (bool, error) {
+	var n int64
+	if err := tx.Model(&model.PromptImg{}).Where("file_id = ?", id).Count(&n).Error; err != nil || n > 0 {
+		return n > 0, err
+	}
+	err := tx.Raw(`SELECT count(*) FROM prompt_revisions, json_each(prompt_revisions.images)
+		WHERE prompt_revisions.images <> '' AND json_extract(json_each.value, '$.file_id') = ?`, id).Scan(&n).Error
+	return n > 0, err
+}
+
+// purgeFile 删除存储中的文件和文件记录，存储中已不存在的文件视为删除成功
+// 仍被引用的文件返回 errFileInUse，留在回收站中，引用它的 prompt 永久删除后再清理
+func purgeFile(id uint) (bool, error) {
+	var f model.File
+	if err := database.DB.Unscoped().Select("id", "path").Where("deleted_at IS NOT NULL").
+		Limit(1).Find(&f, id).Error; err != nil || f.ID == 0 {
+		return false, err
+	}
+	used, err := fileReferenced(database.DB, id)
+	if err != nil {
+		return false, err
+	}
+	if used {
+		return false, errFileInUse
+	}
+	if err := Store.Delete(f.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	return true, database.DB.Unscoped().Delete(&model.File{}, id).Error
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"testing"
+	"time"
+)
+
+func TestRestoredFileIsSearchableAgain(t *testing.T) {
+	setupTestDB(t)
+	u := createTestUser(t, "alice")
+	a := createTestFile(t, u.ID, 0x0f0f0f0f0f0f0f0f)
+	b := createTestFile(t, u.ID, 0x0f0f0f0f0f0f0f0e)
+
+	similar := func() []uint {
+		t.Helper()
+		matches, err := SimilarFiles(a.ID, u.ID, model.NSFWShow, NearDuplicateDistance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]uint, len(matches))
+		for i, m := range matches {
+			ids[i] = m.FileID
+		}
+		return ids
+	}
+
+	if got := similar(); len(got) != 1 || got[0] != b.ID {
+		t.Fatalf("before delete: similar = %v, want [%d]", got, b.ID)
+	}
+	if _, err := DeleteFile(b.ID, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := similar(); len(got) != 0 {
+		t.Fatalf("after delete: similar = %v, want none", got)
+	}
+	if _, err := RestoreTrash("file", b.ID, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := similar(); len(got) != 1 || got[0] != b.ID {
+		t.Fatalf("after restore: similar = %v, want [%d]", got, b.ID)
+	}
+}
+
+func TestPurgeKeepsReferencedFiles(t *testing.T) {
+	setupTestDB(t)
+	u := createTestUser(t, "alice")
+	f := createTestFile(t, u.ID, 0x1234)
+	p := &model.Prompt{UserID: u.ID, Title: "t", Content: "c", Images: []model.PromptImg{{FileId: f.ID}}}
+	if err := CreatePrompt(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeleteFile(f.ID, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	fileExists := func() bool {
+		var n int64
+		database.DB.Unscoped().Model(&model.File{}).Where("id = ?", f.ID).Count(&n)
+		return n > 0
+	}
+
+	// 仍在 prompt 图片中
+	if _, err := PurgeTrashItem("file", f.ID, u.ID); !errors.Is(err, errFileInUse) {
+		t.Fatalf("purge used file: err = %v, want errFileInUse", err)
+	}
+	// 只剩历史版本的快照引用
+	if err := database.DB.Where("prompt_id = ?", p.ID).Delete(&model.PromptImg{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PurgeTrashItem("file", f.ID, u.ID); !errors.Is(err, errFileInUse) {
+		t.Fatalf("purge file in revision: err = %v, want errFileInUse", err)
+	}
+	// 过期清理跳过仍被引用的文件
+	database.DB.Unscoped().Model(&model.File{}).Where("id = ?", f.ID).
+		UpdateColumn("deleted_at", time.Now().Add(-2*trashRetention()))
+	if _, err := PurgeTrash(); err != nil {
+		t.Fatal(err)
+	}
+	if !fileExists() {
+		t.Fatal("referenced file was purged")
+	}
+
+	// prompt 永久删除后文件不再被引用
+	if _, err := DeletePrompt(p.ID, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PurgeTrashItem("prompt", p.ID, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PurgeTrashItem("file", f.ID, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if fileExists() {
+		t.Fatal("unreferenced file was not purged")
+	}
+}
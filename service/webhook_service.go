@@ -0,0 +1,543 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"prompt-share-backend/database"
+	"prompt-share-backend/model"
+	"prompt-share-backend/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	webhookTimeout       = 10 * time.Second
+	webhookMaxAttempts   = 6                // 首次投递加 5 次重试
+	webhookBaseBackoff   = 30 * time.Second // 第 n 次失败后等待 base * 2^(n-1)，上下浮动 20%
+	webhookMaxBackoff    = time.Hour
+	webhookDisableAfter  = 20 // 连续失败的尝试次数达到该值时自动停用
+	webhookWorkers       = 4
+	webhookPollInterval  = 5 * time.Second
+	webhookMaxBodyStored = 2048
+)
+
+var (
+	webhookWake = make(chan struct{}, 1)
+
+	// webhookHTTP 管理员的 webhook 可投递到内网服务
+	webhookHTTP = &http.Client{Timeout: webhookTimeout, CheckRedirect: noRedirect}
+	// publicWebhookHTTP 普通用户的 webhook 只能投递到公网地址，连接时校验解析出的 IP，防止借 webhook 访问内网
+	publicWebhookHTTP = &http.Client{
+		Timeout:       webhookTimeout,
+		CheckRedirect: noRedirect,
+		Transport: &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: rejectPrivateAddr}).DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+)
+
+// noRedirect 不跟随重定向，3xx 视为投递失败
+func noRedirect(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+func rejectPrivateAddr(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+		return fmt.Errorf("address %s is not public", host)
+	}
+	return nil
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+}
+
+// WebhookInput 创建或修改 webhook 的参数，修改时 nil 表示不变
+type WebhookInput struct {
+	URL         *string   `json:"url"`
+	Secret      *string   `json:"secret"` // 创建时为空则自动生成
+	Events      *[]string `json:"events"` // 空数组表示全部事件
+	Description *string   `json:"description"`
+	Global      *bool     `json:"global"` // 仅管理员
+	Active      *bool     `json:"active"` // 重新启用时清空失败计数
+}
+
+// isAdmin 用户是否为管理员
+func isAdmin(userID uint) (bool, error) {
+	var u model.User
+	if err := database.DB.Select("id", "role").First(&u, userID).Error; err != nil {
+		return false, err
+	}
+	return u.Role == model.RoleAdmin, nil
+}
+
+// applyWebhookInput 校验并写入字段，admin 表示操作者是否为管理员
+func applyWebhookInput(w *model.Webhook, in WebhookInput, admin bool) error {
+	errs := utils.FieldErrors{}
+	if in.URL != nil {
+		u := strings.TrimSpace(*in.URL)
+		switch {
+		case !utils.IsHTTPURL(u):
+			errs.Add("url", "must be an http(s) URL")
+		case len(u) > 1024:
+			errs.Add("url", "must be at most 1024 characters")
+		case !admin && isPrivateHost(u):
+			errs.Add("url", "must point to a public address")
+		}
+		w.URL = u
+	}
+	if in.Secret != nil {
+		if n := len(*in.Secret); n < 16 || n > 128 {
+			errs.Add("secret", "must be 16 to 128 characters")
+		}
+		w.Secret = *in.Secret
+	}
+	if in.Events != nil {
+		events := make([]string, 0, len(*in.Events))
+		for _, e := range *in.Events {
+			if !slices.Contains(model.WebhookEvents, e) {
+				errs.Add("events", "unknown event "+strconv.Quote(e))
+			} else if !slices.Contains(events, e) {
+				events = append(events, e)
+			}
+		}
+		w.Events = strings.Join(events, ",")
+	}
+	if in.Description != nil {
+		if len([]rune(*in.Description)) > 255 {
+			errs.Add("description", "must be at most 255 characters")
+		}
+		w.Description = *in.Description
+	}
+	if in.Global != nil {
+		if *in.Global && !admin {
+			errs.Add("global", "only admins can receive site-wide events")
+		}
+		w.Global = *in.Global
+	}
+	if in.Active != nil {
+		if *in.Active && !w.Active {
+			w.FailureCount, w.DisabledAt, w.DisabledReason = 0, nil, ""
+		}
+		w.Active = *in.Active
+	}
+	return errs.Err()
+}
+
+// isPrivateHost 地址是否直接写了内网 IP 或 localhost；域名在投递时按解析结果再校验
+func isPrivateHost(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return true
+	}
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && isPrivateIP(ip)
+}
+
+// CreateWebhook 注册 webhook
+func CreateWebhook(userID uint, in WebhookInput) (*model.Webhook, error) {
+	admin, err := isAdmin(userID)
+	if err != nil {
+		return nil, err
+	}
+	if in.URL == nil {
+		return nil, utils.FieldErrors{"url": "is required"}
+	}
+	w := &model.Webhook{UserID: userID, Active: true}
+	if err := applyWebhookInput(w, in, admin); err != nil {
+		return nil, err
+	}
+	if w.Secret == "" {
+		w.Secret = utils.RandomToken(32)
+	}
+	active := w.Active
+	if err := database.DB.Create(w).Error; err != nil {
+		return nil, err
+	}
+	// Active 有数据库默认值，创建时的 false 会被忽略
+	if !active {
+		w.Active = false
+		if err := database.DB.Model(w).UpdateColumn("active", false).Error; err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// UpdateWebhook 修改 webhook，所有者或管理员可操作
+func UpdateWebhook(id, userID uint, in WebhookInput) (*model.Webhook, error) {
+	w, admin, err := ownWebhook(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyWebhookInput(w, in, admin); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Save(w).Error; err != nil {
+		return nil, err
+	}
+	if w.Active {
+		wakeWebhooks()
+	}
+	return w, nil
+}
+
+// DeleteWebhook 删除 webhook 及其投递记录，返回删除前的 webhook
+func DeleteWebhook(id, userID uint) (*model.Webhook, error) {
+	w, _, err := ownWebhook(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", w.ID).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(w).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// GetWebhook 查询 webhook，所有者或管理员可见
+func GetWebhook(id, userID uint) (*model.Webhook, error) {
+	w, _, err := ownWebhook(id, userID)
+	return w, err
+}
+
+// ownWebhook 查询 webhook 并校验所有者或管理员，同时返回操作者是否为管理员
+func ownWebhook(id, userID uint) (*model.Webhook, bool, error) {
+	var w model.Webhook
+	if err := database.DB.First(&w, id).Error; err != nil {
+		return nil, false, err
+	}
+	admin, err := isAdmin(userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if w.UserID != userID && !admin {
+		return nil, false, ErrForbidden
+	}
+	return &w, admin, nil
+}
+
+// ListWebhooks 分页查询 webhook，userID 为 0 时返回全部（管理员）
+func ListWebhooks(userID uint, req PageRequest) ([]model.Webhook, PageInfo, error) {
+	db := database.DB.Model(&model.Webhook{})
+	if userID != 0 {
+		db = db.Where("user_id = ?", userID)
+	}
+	ks := keyset{scope: "webhooks", columns: []string{"created_at desc", "id desc"}}
+	return paginate(db, req, ks, func(w *model.Webhook) []interface{} {
+		return []interface{}{w.CreatedAt, w.ID}
+	})
+}
+
+// ListWebhookDeliveries 分页查询投递记录，新的在前，status 非空时按状态筛选
+func ListWebhookDeliveries(id, userID uint, status string, req PageRequest) ([]model.WebhookDelivery, PageInfo, error) {
+	if _, _, err := ownWebhook(id, userID); err != nil {
+		return nil, PageInfo{}, err
+	}
+	db := database.DB.Model(&model.WebhookDelivery{}).Where("webhook_id = ?", id)
+	switch status {
+	case "":
+	case model.DeliveryPending, model.DeliverySuccess, model.DeliveryFailed:
+		db = db.Where("status = ?", status)
+	default:
+		return nil, PageInfo{}, utils.FieldErrors{"status": "must be pending, success or failed"}
+	}
+	ks := keyset{scope: "webhook_deliveries", columns: []string{"id desc"}}
+	return paginate(db, req, ks, func(d *model.WebhookDelivery) []interface{} {
+		return []interface{}{d.ID}
+	})
+}
+
+// RedeliverWebhook 以原内容重新投递一次，生成新的投递记录
+func RedeliverWebhook(id, deliveryID, userID uint) (*model.WebhookDelivery, error) {
+	w, _, err := ownWebhook(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !w.Active {
+		return nil, fmt.Errorf("%w: webhook is disabled", ErrInvalidParam)
+	}
+	var orig model.WebhookDelivery
+	if err := database.DB.Where("webhook_id = ?", w.ID).First(&orig, deliveryID).Error; err != nil {
+		return nil, err
+	}
+	d, err := enqueueDelivery(database.DB, w.ID, orig.Event, orig.Payload, orig.ID)
+	if err != nil {
+		return nil, err
+	}
+	wakeWebhooks()
+	return d, nil
+}
+
+// PingWebhook 发送一次 ping 事件用于测试，不受事件过滤影响
+func PingWebhook(id, userID uint) (*model.WebhookDelivery, error) {
+	w, _, err := ownWebhook(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !w.Active {
+		return nil, fmt.Errorf("%w: webhook is disabled", ErrInvalidParam)
+	}
+	payload, err := webhookPayload(model.WebhookPing, map[string]uint{"webhook_id": w.ID})
+	if err != nil {
+		return nil, err
+	}
+	d, err := enqueueDelivery(database.DB, w.ID, model.WebhookPing, payload, 0)
+	if err != nil {
+		return nil, err
+	}
+	wakeWebhooks()
+	return d, nil
+}
+
+func webhookPayload(event string, data interface{}) (string, error) {
+	b, err := json.Marshal(map[string]interface{}{"event": event, "created_at": time.Now(), "data": data})
+	return string(b), err
+}
+
+func enqueueDelivery(tx *gorm.DB, webhookID uint, event, payload string, redeliveryOf uint) (*model.WebhookDelivery, error) {
+	now := time.Now()
+	d := &model.WebhookDelivery{WebhookID: webhookID, Event: event, Payload: payload,
+		Status: model.DeliveryPending, NextAttemptAt: &now, RedeliveryOf: redeliveryOf}
+	return d, tx.Create(d).Error
+}
+
+// queueWebhooks 在事务提交后调用，为订阅了该事件的 webhook 创建投递记录；失败只记录日志
+// owners 为事件涉及资源的所有者，普通用户的 webhook 只接收与自己相关的事件
+func queueWebhooks(event string, data interface{}, owners ...uint) {
+	if err := enqueueWebhooks(event, data, owners); err != nil {
+		log.Printf("queue %s webhooks failed: %v", event, err)
+	}
+}
+
+func enqueueWebhooks(event string, data interface{}, owners []uint) error {
+	owners = slices.DeleteFunc(uniqueIDs(owners), func(id uint) bool { return id == 0 })
+	db := database.DB.Where("active = ?", true)
+	if len(owners) > 0 {
+		db = db.Where("global = ? OR user_id IN ?", true, owners)
+	} else {
+		db = db.Where("global = ?", true)
+	}
+	var hooks []model.Webhook
+	if err := db.Find(&hooks).Error; err != nil {
+		return err
+	}
+	hooks = slices.DeleteFunc(hooks, func(w model.Webhook) bool {
+		return w.Events != "" && !slices.Contains(strings.Split(w.Events, ","), event)
+	})
+	if len(hooks) == 0 {
+		return nil
+	}
+	payload, err := webhookPayload(event, data)
+	if err != nil {
+		return err
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, w := range hooks {
+			if _, err := enqueueDelivery(tx, w.ID, event, payload, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		wakeWebhooks()
+	}
+	return err
+}
+
+// InitWebhooks 启动后台投递，重启前未完成的投递会继续
+func InitWebhooks() {
+	go webhookDispatcher()
+}
+
+func wakeWebhooks() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+func webhookDispatcher() {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	sem := make(chan struct{}, webhookWorkers)
+	var mu sync.Mutex
+	inflight := make(map[uint]bool)
+	for {
+		var due []model.WebhookDelivery
+		if err := database.DB.Where("status = ? AND next_attempt_at <= ?", model.DeliveryPending, time.Now()).
+			Order("next_attempt_at").Limit(100).Find(&due).Error; err != nil {
+			log.Println("load webhook deliveries failed:", err)
+		}
+		for _, d := range due {
+			mu.Lock()
+			busy := inflight[d.ID]
+			inflight[d.ID] = true
+			mu.Unlock()
+			if busy {
+				continue
+			}
+			sem <- struct{}{}
+			go func(d model.WebhookDelivery) {
+				defer func() {
+					mu.Lock()
+					delete(inflight, d.ID)
+					mu.Unlock()
+					<-sem
+				}()
+				if err := attemptDelivery(&d); err != nil {
+					log.Printf("webhook delivery %d failed: %v", d.ID, err)
+				}
+			}(d)
+		}
+		select {
+		case <-ticker.C:
+		case <-webhookWake:
+		}
+	}
+}
+
+// attemptDelivery 投递一次并记录结果，失败时按指数退避安排重试
+func attemptDelivery(d *model.WebhookDelivery) error {
+	var w model.Webhook
+	err := database.DB.First(&w, d.WebhookID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !w.Active) {
+		return database.DB.Model(d).Updates(map[string]interface{}{
+			"status": model.DeliveryFailed, "next_attempt_at": nil, "error": "webhook deleted or disabled",
+		}).Error
+	}
+	if err != nil {
+		return err
+	}
+	admin, err := isAdmin(w.UserID)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	status, body, sendErr := sendWebhook(&w, d, admin)
+	now := time.Now()
+	updates := map[string]interface{}{
+		"attempts":        d.Attempts + 1,
+		"last_attempt_at": now,
+		"response_status": status,
+		"response_body":   body,
+		"duration_ms":     now.Sub(start).Milliseconds(),
+		"error":           "",
+	}
+	if sendErr == nil && status >= 200 && status < 300 {
+		updates["status"], updates["next_attempt_at"] = model.DeliverySuccess, nil
+		if err := database.DB.Model(d).Updates(updates).Error; err != nil {
+			return err
+		}
+		return database.DB.Model(&w).UpdateColumn("failure_count", 0).Error
+	}
+
+	switch {
+	case sendErr != nil:
+		updates["error"] = truncate(sendErr.Error(), 512)
+	default:
+		updates["error"] = fmt.Sprintf("unexpected status %d", status)
+	}
+	if d.Attempts+1 >= webhookMaxAttempts {
+		updates["status"], updates["next_attempt_at"] = model.DeliveryFailed, nil
+	} else {
+		updates["next_attempt_at"] = now.Add(webhookBackoff(d.Attempts + 1))
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(d).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&w).UpdateColumn("failure_count", gorm.Expr("failure_count + 1")).Error; err != nil {
+			return err
+		}
+		if w.FailureCount+1 < webhookDisableAfter {
+			return nil
+		}
+		// 连续失败过多：停用 webhook，剩余待投递的记录一并放弃
+		if err := tx.Model(&w).Updates(map[string]interface{}{
+			"active": false, "disabled_at": now,
+			"disabled_reason": fmt.Sprintf("disabled after %d consecutive failed attempts", webhookDisableAfter),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.WebhookDelivery{}).Where("webhook_id = ? AND status = ?", w.ID, model.DeliveryPending).
+			Updates(map[string]interface{}{"status": model.DeliveryFailed, "next_attempt_at": nil, "error": "webhook disabled"}).Error
+	})
+}
+
+// sendWebhook 发送签名请求，返回状态码和截断的响应体
+func sendWebhook(w *model.Webhook, d *model.WebhookDelivery, admin bool) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewBufferString(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "prompt-share-webhook/1.0")
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set("X-Webhook-Timestamp", ts)
+	req.Header.Set("X-Webhook-Signature", "sha256="+utils.SignWebhook(w.Secret, ts, []byte(d.Payload)))
+
+	client := publicWebhookHTTP
+	if admin {
+		client = webhookHTTP
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxBodyStored))
+	return resp.StatusCode, string(body), nil
+}
+
+// webhookBackoff 第 attempts 次失败后到下一次重试的等待时间
+func webhookBackoff(attempts int) time.Duration {
+	d := webhookBaseBackoff << (attempts - 1)
+	if d > webhookMaxBackoff || d <= 0 {
+		d = webhookMaxBackoff
+	}
+	jitter := time.Duration(float64(d) * (rand.Float64()*0.4 - 0.2))
+	return d + jitter
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,166 @@
+package stream
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer 每个订阅者的待发送事件上限，积压超过时断开，由客户端带 Last-Event-ID 重连补发
+const subscriberBuffer = 64
+
+// Event 推送给客户端的一条事件
+type Event struct {
+	ID     string      `json:"id"` // <启动标识>-<序号>，用于断线续传
+	Type   string      `json:"type"`
+	Topics []string    `json:"topics"`
+	Data   interface{} `json:"data"`
+	Time   time.Time   `json:"time"`
+
+	seq uint64
+}
+
+// Hub 进程内的发布订阅中心，保留最近的事件用于断线续传
+type Hub struct {
+	mu     sync.Mutex
+	epoch  string // 本次启动的标识，重启后旧的事件 id 无法续传
+	seq    uint64
+	recent []Event // 环形缓冲，recent[seq % len] 为序号 seq 的事件
+	subs   map[*Subscription]struct{}
+}
+
+// NewHub 创建 Hub，bufferSize 为可续传的最近事件数
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+	return &Hub{
+		epoch:  strconv.FormatInt(time.Now().UnixMilli(), 36),
+		recent: make([]Event, bufferSize),
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish 发布事件，订阅了任一 topic 的订阅者都会收到一次
+func (h *Hub) Publish(typ string, data interface{}, topics ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	e := Event{
+		ID:     h.epoch + "-" + strconv.FormatUint(h.seq, 10),
+		Type:   typ,
+		Topics: topics,
+		Data:   data,
+		Time:   time.Now(),
+		seq:    h.seq,
+	}
+	h.recent[h.seq%uint64(len(h.recent))] = e
+	for s := range h.subs {
+		if !s.matches(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			h.drop(s)
+		}
+	}
+}
+
+// Subscribe 订阅 topics；lastEventID 非空时先返回其后错过的事件
+// 无法续传（服务重启或事件已被缓冲覆盖）时 resumed 为 false，客户端应重新拉取数据
+func (h *Hub) Subscribe(topics []string, lastEventID string) (s *Subscription, missed []Event, resumed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s = &Subscription{hub: h, ch: make(chan Event, subscriberBuffer), topics: make(map[string]bool)}
+	for _, t := range topics {
+		s.topics[t] = true
+	}
+	h.subs[s] = struct{}{}
+
+	resumed = lastEventID == ""
+	epoch, seqStr, ok := strings.Cut(lastEventID, "-")
+	last, err := strconv.ParseUint(seqStr, 10, 64)
+	if !ok || err != nil || epoch != h.epoch || last > h.seq {
+		return s, nil, resumed
+	}
+	oldest := uint64(1)
+	if h.seq > uint64(len(h.recent)) {
+		oldest = h.seq - uint64(len(h.recent)) + 1
+	}
+	if last+1 < oldest {
+		return s, nil, false
+	}
+	for seq := last + 1; seq <= h.seq; seq++ {
+		if e := h.recent[seq%uint64(len(h.recent))]; s.matches(e) {
+			missed = append(missed, e)
+		}
+	}
+	return s, missed, true
+}
+
+// drop 移除订阅者并关闭其通道，调用方需持有锁
+func (h *Hub) drop(s *Subscription) {
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+// Subscription 一个客户端连接的订阅
+type Subscription struct {
+	hub    *Hub
+	ch     chan Event
+	topics map[string]bool // 由 hub.mu 保护
+}
+
+// Events 事件通道，订阅被关闭或因积压被断开时关闭
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Add 追加订阅的 topics
+func (s *Subscription) Add(topics ...string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	for _, t := range topics {
+		s.topics[t] = true
+	}
+}
+
+// Remove 取消订阅的 topics
+func (s *Subscription) Remove(topics ...string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	for _, t := range topics {
+		delete(s.topics, t)
+	}
+}
+
+// Topics 当前订阅的 topics
+func (s *Subscription) Topics() []string {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	out := make([]string, 0, len(s.topics))
+	for t := range s.topics {
+		out = append(out, t)
+	}
+	return out
+}
+
+// Close 取消订阅，可重复调用
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s)
+}
+
+func (s *Subscription) matches(e Event) bool {
+	for _, t := range e.Topics {
+		if s.topics[t] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package utils
+
+// BKTree 按汉明距离组织的 BK 树，用于查找距离不超过阈值的哈希
+// 非并发安全，由调用方加锁
+type BKTree struct {
+	root *bkNode
+	size int
+}
+
+type bkNode struct {
+	hash     uint64
+	ids      []uint
+	children map[int]*bkNode
+}
+
+// BKMatch 检索结果
+type BKMatch struct {
+	ID       uint
+	Hash     uint64
+	Distance int
+}
+
+// Len 已收录的 id 数
+func (t *BKTree) Len() int {
+	return t.size
+}
+
+// Add 收录 id，相同哈希的 id 挂在同一节点
+func (t *BKTree) Add(hash uint64, id uint) {
+	t.size++
+	if t.root == nil {
+		t.root = &bkNode{hash: hash, ids: []uint{id}}
+		return
+	}
+	node := t.root
+	for {
+		d := HammingDistance(hash, node.hash)
+		if d == 0 {
+			node.ids = append(node.ids, id)
+			return
+		}
+		child, ok := node.children[d]
+		if !ok {
+			if node.children == nil {
+				node.children = map[int]*bkNode{}
+			}
+			node.children[d] = &bkNode{hash: hash, ids: []uint{id}}
+			return
+		}
+		node = child
+	}
+}
+
+// Remove 移除 id，节点保留以维持树结构
+func (t *BKTree) Remove(hash uint64, id uint) {
+	node := t.root
+	for node != nil {
+		d := HammingDistance(hash, node.hash)
+		if d == 0 {
+			for i, v := range node.ids {
+				if v == id {
+					node.ids = append(node.ids[:i], node.ids[i+1:]...)
+					t.size--
+					return
+				}
+			}
+			return
+		}
+		node = node.children[d]
+	}
+}
+
+// Search 返回与 hash 距离不超过 maxDist 的全部 id
+func (t *BKTree) Search(hash uint64, maxDist int) []BKMatch {
+	var out []BKMatch
+	if t.root == nil {
+		return out
+	}
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := HammingDistance(hash, node.hash)
+		if d <= maxDist {
+			for _, id := range node.ids {
+				out = append(out, BKMatch{ID: id, Hash: node.hash, Distance: d})
+			}
+		}
+		for cd, child := range node.children {
+			if cd >= d-maxDist && cd <= d+maxDist {
+				stack = append(stack, child)
+			}
+		}
+	}
+	return out
+}
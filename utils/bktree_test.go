@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func sortMatches(m []BKMatch) []BKMatch {
+	sort.Slice(m, func(i, j int) bool { return m[i].ID < m[j].ID })
+	return m
+}
+
+func equalMatches(a, b []BKMatch) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func bruteForce(hashes map[uint]uint64, q uint64, maxDist int) []BKMatch {
+	var out []BKMatch
+	for id, h := range hashes {
+		if d := HammingDistance(q, h); d <= maxDist {
+			out = append(out, BKMatch{ID: id, Hash: h, Distance: d})
+		}
+	}
+	return sortMatches(out)
+}
+
+func TestBKTreeSearch(t *testing.T) {
+	var tree BKTree
+	if got := tree.Search(0, 64); len(got) != 0 {
+		t.Fatalf("empty tree returned %v", got)
+	}
+
+	cases := []struct {
+		name    string
+		hashes  map[uint]uint64
+		query   uint64
+		maxDist int
+		want    []BKMatch
+	}{
+		{
+			name:    "exact only",
+			hashes:  map[uint]uint64{1: 0b1010, 2: 0b1011, 3: 0b0101},
+			query:   0b1010,
+			maxDist: 0,
+			want:    []BKMatch{{ID: 1, Hash: 0b1010}},
+		},
+		{
+			name:    "within distance",
+			hashes:  map[uint]uint64{1: 0b1010, 2: 0b1011, 3: 0b0101},
+			query:   0b1010,
+			maxDist: 1,
+			want:    []BKMatch{{ID: 1, Hash: 0b1010}, {ID: 2, Hash: 0b1011, Distance: 1}},
+		},
+		{
+			name:    "duplicate hashes share a node",
+			hashes:  map[uint]uint64{1: 7, 2: 7, 3: 0},
+			query:   6,
+			maxDist: 1,
+			want:    []BKMatch{{ID: 1, Hash: 7, Distance: 1}, {ID: 2, Hash: 7, Distance: 1}},
+		},
+		{
+			name:    "high bit",
+			hashes:  map[uint]uint64{1: 1 << 63, 2: 0},
+			query:   1<<63 | 1,
+			maxDist: 1,
+			want:    []BKMatch{{ID: 1, Hash: 1 << 63, Distance: 1}},
+		},
+	}
+	for _, c := range cases {
+		var tree BKTree
+		ids := make([]uint, 0, len(c.hashes))
+		for id := range c.hashes {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			tree.Add(c.hashes[id], id)
+		}
+		if got := sortMatches(tree.Search(c.query, c.maxDist)); !equalMatches(got, c.want) {
+			t.Errorf("%s: Search = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestBKTreeMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var tree BKTree
+	hashes := map[uint]uint64{}
+	// 一部分哈希围绕少数中心小幅扰动，模拟近似重复图片
+	centers := []uint64{rng.Uint64(), rng.Uint64(), rng.Uint64()}
+	for id := uint(1); id <= 2000; id++ {
+		h := rng.Uint64()
+		if id%2 == 0 {
+			h = centers[rng.Intn(len(centers))]
+			for n := rng.Intn(8); n > 0; n-- {
+				h ^= 1 << uint(rng.Intn(64))
+			}
+		}
+		hashes[id] = h
+		tree.Add(h, id)
+	}
+	if tree.Len() != len(hashes) {
+		t.Fatalf("Len = %d, want %d", tree.Len(), len(hashes))
+	}
+
+	queries := append([]uint64{rng.Uint64(), rng.Uint64()}, centers...)
+	for _, q := range queries {
+		for _, maxDist := range []int{0, 3, 10, 24} {
+			want := bruteForce(hashes, q, maxDist)
+			if got := sortMatches(tree.Search(q, maxDist)); !equalMatches(got, want) {
+				t.Errorf("Search(%016x, %d): got %d matches, want %d", q, maxDist, len(got), len(want))
+			}
+		}
+	}
+
+	// 移除一半后结果仍与暴力检索一致
+	for id := uint(1); id <= 2000; id += 2 {
+		tree.Remove(hashes[id], id)
+		delete(hashes, id)
+	}
+	tree.Remove(12345, 99999) // 不存在的 id 不影响计数
+	if tree.Len() != len(hashes) {
+		t.Fatalf("Len after remove = %d, want %d", tree.Len(), len(hashes))
+	}
+	for _, q := range queries {
+		want := bruteForce(hashes, q, 10)
+		if got := sortMatches(tree.Search(q, 10)); !equalMatches(got, want) {
+			t.Errorf("after remove Search(%016x, 10): got %d matches, want %d", q, len(got), len(want))
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	secret := []byte("test-secret")
+	cases := []Cursor{
+		{Scope: "prompts:latest"},
+		{Scope: "prompts:latest", Keys: []string{"t:2024-01-02T03:04:05.123456789Z", "i:42"}},
+		{Scope: "search:relevance", Offset: 40},
+		{Scope: "comments", Keys: []string{"s:a.b", "i:7"}, Before: true},
+	}
+	for _, c := range cases {
+		s := EncodeCursor(c, secret)
+		got, err := DecodeCursor(s, secret)
+		if err != nil {
+			t.Errorf("DecodeCursor(%q): %v", s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c) {
+			t.Errorf("round trip = %+v, want %+v", got, c)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	secret := []byte("test-secret")
+	valid := EncodeCursor(Cursor{Scope: "prompts:latest", Keys: []string{"i:10"}}, secret)
+	body, sig, _ := strings.Cut(valid, ".")
+	enc := base64.RawURLEncoding
+	forged := enc.EncodeToString([]byte(`{"s":"prompts:latest","k":["i:999"]}`))
+
+	cases := []struct {
+		name   string
+		cursor string
+		secret []byte
+	}{
+		{"empty", "", secret},
+		{"no separator", body, secret},
+		{"bad base64 payload", "!!!." + sig, secret},
+		{"bad base64 signature", body + ".!!!", secret},
+		{"forged payload", forged + "." + sig, secret},
+		{"truncated signature", body + "." + sig[:len(sig)-2], secret},
+		{"wrong secret", valid, []byte("other-secret")},
+	}
+	for _, c := range cases {
+		if _, err := DecodeCursor(c.cursor, c.secret); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err = %v, want ErrInvalidCursor", c.name, err)
+		}
+	}
+}
+
+func TestCursorValue(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	cases := []struct {
+		in      interface{}
+		encoded string
+		want    interface{}
+	}{
+		{ts, "t:2024-01-02T03:04:05.123456789Z", ts},
+		{"a:b", "s:a:b", "a:b"},
+		{uint(42), "i:42", int64(42)},
+		{-3, "i:-3", int64(-3)},
+		{int64(1) << 40, "i:1099511627776", int64(1) << 40},
+		{1.5, "s:1.5", "1.5"},
+	}
+	for _, c := range cases {
+		s := CursorValue(c.in)
+		if s != c.encoded {
+			t.Errorf("CursorValue(%v) = %q, want %q", c.in, s, c.encoded)
+		}
+		got, err := ParseCursorValue(s)
+		if err != nil {
+			t.Errorf("ParseCursorValue(%q): %v", s, err)
+			continue
+		}
+		if gt, ok := got.(time.Time); ok {
+			if !gt.Equal(c.want.(time.Time)) {
+				t.Errorf("ParseCursorValue(%q) = %v, want %v", s, gt, c.want)
+			}
+		} else if got != c.want {
+			t.Errorf("ParseCursorValue(%q) = %#v, want %#v", s, got, c.want)
+		}
+	}
+
+	for _, s := range []string{"", "42", "x:1", "i:abc", "t:yesterday"} {
+		if _, err := ParseCursorValue(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("ParseCursorValue(%q) err = %v, want ErrInvalidCursor", s, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"unicode"
+)
+
+// DiffOp 差异片段类型
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffSegment 一段连续的相同操作文本
+type DiffSegment struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffTokens 超过该规模时不做 LCS，直接整体替换，避免 O(n*m) 内存爆炸
+const maxDiffTokens = 2000
+
+// WordDiff 按词计算 a -> b 的差异
+// 英文等以字母数字连续串为一个词，CJK 字符每个字为一个词，空白与标点单独成词
+func WordDiff(a, b string) []DiffSegment {
+	ta, tb := tokenizeWords(a), tokenizeWords(b)
+
+	// 去掉公共前后缀，缩小 LCS 规模
+	prefix := 0
+	for prefix < len(ta) && prefix < len(tb) && ta[prefix] == tb[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(ta)-prefix && suffix < len(tb)-prefix && ta[len(ta)-1-suffix] == tb[len(tb)-1-suffix] {
+		suffix++
+	}
+
+	var segs []DiffSegment
+	segs = appendSegment(segs, DiffEqual, ta[:prefix]...)
+	midA, midB := ta[prefix:len(ta)-suffix], tb[prefix:len(tb)-suffix]
+	if len(midA) > maxDiffTokens || len(midB) > maxDiffTokens {
+		segs = appendSegment(segs, DiffDelete, midA...)
+		segs = appendSegment(segs, DiffInsert, midB...)
+	} else {
+		segs = append(segs, lcsDiff(midA, midB)...)
+	}
+	segs = appendSegment(segs, DiffEqual, ta[len(ta)-suffix:]...)
+	return mergeSegments(segs)
+}
+
+// lcsDiff 基于最长公共子序列计算差异
+func lcsDiff(a, b []string) []DiffSegment {
+	n, m := len(a), len(b)
+	// dp[i][j] 为 a[i:] 与 b[j:] 的 LCS 长度
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] >= dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+
+	var segs []DiffSegment
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			segs = appendSegment(segs, DiffEqual, a[i])
+			i++
+			j++
+		case dp[i+1][j] >= dp[i][j+1]:
+			segs = appendSegment(segs, DiffDelete, a[i])
+			i++
+		default:
+			segs = appendSegment(segs, DiffInsert, b[j])
+			j++
+		}
+	}
+	segs = appendSegment(segs, DiffDelete, a[i:]...)
+	segs = appendSegment(segs, DiffInsert, b[j:]...)
+	return segs
+}
+
+func appendSegment(segs []DiffSegment, op string, tokens ...string) []DiffSegment {
+	for _, t := range tokens {
+		if n := len(segs); n > 0 && segs[n-1].Op == op {
+			segs[n-1].Text += t
+			continue
+		}
+		segs = append(segs, DiffSegment{Op: op, Text: t})
+	}
+	return segs
+}
+
+func mergeSegments(segs []DiffSegment) []DiffSegment {
+	out := make([]DiffSegment, 0, len(segs))
+	for _, s := range segs {
+		if s.Text == "" {
+			continue
+		}
+		out = appendSegment(out, s.Op, s.Text)
+	}
+	return out
+}
+
+// tokenizeWords 拆分为词、空白、标点与单个 CJK 字符
+func tokenizeWords(s string) []string {
+	var tokens []string
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		j := i + 1
+		switch {
+		case IsCJK(r):
+			// 单字成词
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			for j < len(runes) && !IsCJK(runes[j]) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+		case unicode.IsSpace(r):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+		}
+		tokens = append(tokens, string(runes[i:j]))
+		i = j
+	}
+	return tokens
+}
+
+// IsCJK 是否为中日韩文字
+func IsCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
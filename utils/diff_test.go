@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// applyDiff 分别用 equal+delete 与 equal+insert 还原两侧文本
+func applyDiff(segs []DiffSegment) (a, b string) {
+	var sa, sb strings.Builder
+	for _, s := range segs {
+		if s.Op != DiffInsert {
+			sa.WriteString(s.Text)
+		}
+		if s.Op != DiffDelete {
+			sb.WriteString(s.Text)
+		}
+	}
+	return sa.String(), sb.String()
+}
+
+func TestWordDiff(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want []DiffSegment
+	}{
+		{"", "", []DiffSegment{}},
+		{"same text", "same text", []DiffSegment{{DiffEqual, "same text"}}},
+		{"a cat", "a dog", []DiffSegment{{DiffEqual, "a "}, {DiffDelete, "cat"}, {DiffInsert, "dog"}}},
+		{"red hat", "red big hat", []DiffSegment{{DiffEqual, "red "}, {DiffInsert, "big "}, {DiffEqual, "hat"}}},
+		{"one, two", "one two", []DiffSegment{{DiffEqual, "one"}, {DiffDelete, ","}, {DiffEqual, " two"}}},
+		{"可爱的猫", "可爱的狗", []DiffSegment{{DiffEqual, "可爱的"}, {DiffDelete, "猫"}, {DiffInsert, "狗"}}},
+		{"", "new", []DiffSegment{{DiffInsert, "new"}}},
+		{"old", "", []DiffSegment{{DiffDelete, "old"}}},
+	}
+	for _, c := range cases {
+		got := WordDiff(c.a, c.b)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("WordDiff(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestWordDiffReconstructs(t *testing.T) {
+	long := strings.Repeat("word ", maxDiffTokens)
+	pairs := [][2]string{
+		{"masterpiece, best quality, 1girl, red hair", "best quality, 1girl, blue hair, smile"},
+		{"一只在草地上奔跑的小狗", "一只在雪地里奔跑的小猫"},
+		{"line one\nline two\n", "line one\nline 2\nline three\n"},
+		{long + "end", "start " + long},
+	}
+	for _, p := range pairs {
+		segs := WordDiff(p[0], p[1])
+		a, b := applyDiff(segs)
+		if a != p[0] || b != p[1] {
+			t.Errorf("WordDiff(%.30q, %.30q) does not reconstruct inputs", p[0], p[1])
+		}
+		for i := 1; i < len(segs); i++ {
+			if segs[i].Op == segs[i-1].Op {
+				t.Errorf("WordDiff(%.30q, %.30q): adjacent %s segments not merged", p[0], p[1], segs[i].Op)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"html"
+	"strings"
+)
+
+// ftsSep 全文索引中 CJK 字之间插入的零宽空格，unicode61 分词器将其视为分隔符
+const ftsSep = '​'
+
+// SegmentCJK 在每个 CJK 字前后插入零宽空格，使 FTS5 unicode61 分词器按单字切分中日韩文本
+// 零宽空格不可见，UnsegmentCJK 可无损还原
+func SegmentCJK(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	prevCJK, first := false, true
+	for _, r := range s {
+		cjk := IsCJK(r)
+		if !first && (cjk || prevCJK) {
+			b.WriteRune(ftsSep)
+		}
+		b.WriteRune(r)
+		prevCJK, first = cjk, false
+	}
+	return b.String()
+}
+
+// UnsegmentCJK 去掉 SegmentCJK 插入的零宽空格
+func UnsegmentCJK(s string) string {
+	return strings.ReplaceAll(s, string(ftsSep), "")
+}
+
+// HighlightHTML 将 FTS5 highlight/snippet 的结果转义为 HTML，open/close 标记替换为 <mark>
+func HighlightHTML(s, open, close string) string {
+	s = html.EscapeString(UnsegmentCJK(s))
+	s = strings.ReplaceAll(s, open, "<mark>")
+	return strings.ReplaceAll(s, close, "</mark>")
+}
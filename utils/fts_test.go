@@ -0,0 +1,46 @@
+package utils
+
+import "testing"
+
+func TestSegmentCJK(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"hello world", "hello world"},
+		{"猫", "猫"},
+		{"猫耳", "猫\u200b耳"},
+		{"cat猫耳girl", "cat\u200b猫\u200b耳\u200bgirl"},
+		{"猫 耳", "猫\u200b \u200b耳"},
+		{"かわいい", "か\u200bわ\u200bい\u200bい"},
+		{"한국", "한\u200b국"},
+	}
+	for _, c := range cases {
+		got := SegmentCJK(c.in)
+		if got != c.want {
+			t.Errorf("SegmentCJK(%q) = %q, want %q", c.in, got, c.want)
+		}
+		if back := UnsegmentCJK(got); back != c.in {
+			t.Errorf("UnsegmentCJK(SegmentCJK(%q)) = %q", c.in, back)
+		}
+	}
+}
+
+func TestHighlightHTML(t *testing.T) {
+	const open, close = "\x02", "\x03"
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"a \x02cat\x03 b", "a <mark>cat</mark> b"},
+		{"\x02猫\x03\u200b耳", "<mark>猫</mark>耳"},
+		{"<script>\x02x\x03</script>", "&lt;script&gt;<mark>x</mark>&lt;/script&gt;"},
+		{`"q" & 'a'`, "&#34;q&#34; &amp; &#39;a&#39;"},
+	}
+	for _, c := range cases {
+		if got := HighlightHTML(c.in, open, close); got != c.want {
+			t.Errorf("HighlightHTML(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
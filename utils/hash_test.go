@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSignWebhook(t *testing.T) {
+	// 期望值由独立实现计算：hex(HMAC-SHA256(secret, timestamp + "." + body))
+	cases := []struct {
+		secret, timestamp, body string
+		want                    string
+	}{
+		{"whsec_test", "1700000000", `{"event":"ping"}`, "aa8efe37b751e71157c508c5ac4acb1e9fe5225db98355dfc00f4b680afbc447"},
+		{"", "0", "", "b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3"},
+		{"k", "1700000000", "1700000000.x", "7909c7caab9a6c25912dcef63faea5a09d7acaf211cbb6c5b53ff6912b06fd47"},
+	}
+	for _, c := range cases {
+		if got := SignWebhook(c.secret, c.timestamp, []byte(c.body)); got != c.want {
+			t.Errorf("SignWebhook(%q, %q, %q) = %s, want %s", c.secret, c.timestamp, c.body, got, c.want)
+		}
+	}
+
+	hexSig := regexp.MustCompile(`^[0-9a-f]{64}$`)
+	base := SignWebhook("s", "1700000000", []byte("body"))
+	if !hexSig.MatchString(base) {
+		t.Errorf("signature %q is not 64 lowercase hex chars", base)
+	}
+	// 时间戳与正文之间的分隔符必须参与签名，移动边界不能得到相同签名
+	for _, other := range []string{
+		SignWebhook("s", "1700000001", []byte("body")),
+		SignWebhook("s2", "1700000000", []byte("body")),
+		SignWebhook("s", "1700000000", []byte("body ")),
+		SignWebhook("s", "1700000000.", []byte("ody")),
+		SignWebhook("s", "170000000", []byte("0.body")),
+	} {
+		if other == base {
+			t.Errorf("different input produced the same signature %s", base)
+		}
+	}
+}
+
+func TestRandomToken(t *testing.T) {
+	a, b := RandomToken(16), RandomToken(16)
+	if len(a) != 32 || !regexp.MustCompile(`^[0-9a-f]+$`).MatchString(a) {
+		t.Errorf("RandomToken(16) = %q, want 32 hex chars", a)
+	}
+	if a == b {
+		t.Error("RandomToken returned the same value twice")
+	}
+}
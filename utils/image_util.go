@@ -53,6 +53,24 @@ func GenerateThumbnail(data []byte, maxSize int, quality int) (string, error) {
 	return base64Str, nil
 }
 
+// BlurThumbnail 对 base64 编码的缩略图做高斯模糊，返回新的 base64 JPEG
+// sigma 越大越模糊
+func BlurThumbnail(thumbnail string, sigma float64, quality int) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(thumbnail)
+	if err != nil {
+		return "", err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, imaging.Blur(img, sigma), &jpeg.Options{Quality: quality}); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
 func IsImage(contentType string) bool {
 	imageTypes := []string{
 		"image/jpeg",
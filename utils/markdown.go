@@ -0,0 +1,333 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxMentionLen @用户名的最大长度（字符）
+const maxMentionLen = 32
+
+// maxQuoteDepth 引用块最大嵌套层数，更深的 > 按普通文本处理
+const maxQuoteDepth = 4
+
+var (
+	mdHeading     = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	mdRule        = regexp.MustCompile(`^(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	mdBulletItem  = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	mdOrderedItem = regexp.MustCompile(`^(\d{1,9})[.)]\s+(.*)$`)
+	mdFenceLang   = regexp.MustCompile(`^[A-Za-z0-9_+#.-]{1,32}$`)
+)
+
+// RenderMarkdown 将评论使用的 Markdown 子集渲染为安全的 HTML
+// 支持段落、标题、引用、列表、分隔线、围栏代码块，以及行内代码、粗体、斜体、删除线、链接和自动链接
+// 不接受任何原始 HTML：所有文本都先转义，链接仅允许 http/https/mailto
+// mentions 为已解析的 @用户名到用户 id，命中的提及渲染为 <span class="mention" data-user-id="..">
+func RenderMarkdown(src string, mentions map[string]uint) string {
+	r := &mdRenderer{mention: func(name string) string {
+		id, ok := mentions[name]
+		if !ok {
+			return ""
+		}
+		return `<span class="mention" data-user-id="` + strconv.FormatUint(uint64(id), 10) + `">@` + html.EscapeString(name) + `</span>`
+	}}
+	return r.render(src)
+}
+
+// ExtractMentions 按出现顺序返回 Markdown 中去重后的 @用户名，代码块和行内代码中的不计
+func ExtractMentions(src string) []string {
+	var names []string
+	seen := map[string]bool{}
+	r := &mdRenderer{mention: func(name string) string {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		return ""
+	}}
+	r.render(src)
+	return names
+}
+
+type mdRenderer struct {
+	b       strings.Builder
+	mention func(name string) string // 返回提及的 HTML，空串表示按普通文本输出
+	inLink  bool
+}
+
+func (r *mdRenderer) render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	r.blocks(strings.Split(src, "\n"), 0)
+	return strings.TrimSuffix(r.b.String(), "\n")
+}
+
+func (r *mdRenderer) blocks(lines []string, depth int) {
+	for i := 0; i < len(lines); {
+		t := strings.TrimSpace(lines[i])
+		switch {
+		case t == "":
+			i++
+		case strings.HasPrefix(t, "```"):
+			lang := strings.TrimSpace(t[3:])
+			j := i + 1
+			for j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j]), "```") {
+				j++
+			}
+			r.b.WriteString("<pre><code")
+			if mdFenceLang.MatchString(lang) {
+				r.b.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+			}
+			r.b.WriteString(">")
+			r.b.WriteString(html.EscapeString(strings.Join(lines[i+1:min(j, len(lines))], "\n")))
+			r.b.WriteString("</code></pre>\n")
+			i = j + 1
+		case mdHeading.MatchString(t):
+			m := mdHeading.FindStringSubmatch(t)
+			tag := "h" + strconv.Itoa(len(m[1]))
+			r.b.WriteString("<" + tag + ">")
+			r.inline(m[2])
+			r.b.WriteString("</" + tag + ">\n")
+			i++
+		case mdRule.MatchString(t):
+			r.b.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(t, ">") && depth < maxQuoteDepth:
+			var inner []string
+			for ; i < len(lines); i++ {
+				q := strings.TrimSpace(lines[i])
+				if !strings.HasPrefix(q, ">") {
+					break
+				}
+				inner = append(inner, strings.TrimPrefix(q[1:], " "))
+			}
+			r.b.WriteString("<blockquote>\n")
+			r.blocks(inner, depth+1)
+			r.b.WriteString("</blockquote>\n")
+		case mdBulletItem.MatchString(t):
+			r.b.WriteString("<ul>\n")
+			for ; i < len(lines); i++ {
+				m := mdBulletItem.FindStringSubmatch(strings.TrimSpace(lines[i]))
+				if m == nil || mdRule.MatchString(strings.TrimSpace(lines[i])) {
+					break
+				}
+				r.b.WriteString("<li>")
+				r.inline(m[1])
+				r.b.WriteString("</li>\n")
+			}
+			r.b.WriteString("</ul>\n")
+		case mdOrderedItem.MatchString(t):
+			m := mdOrderedItem.FindStringSubmatch(t)
+			if start, _ := strconv.Atoi(m[1]); start != 1 {
+				r.b.WriteString(`<ol start="` + strconv.Itoa(start) + `">` + "\n")
+			} else {
+				r.b.WriteString("<ol>\n")
+			}
+			for ; i < len(lines); i++ {
+				m := mdOrderedItem.FindStringSubmatch(strings.TrimSpace(lines[i]))
+				if m == nil {
+					break
+				}
+				r.b.WriteString("<li>")
+				r.inline(m[2])
+				r.b.WriteString("</li>\n")
+			}
+			r.b.WriteString("</ol>\n")
+		default:
+			r.b.WriteString("<p>")
+			for first := true; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if t == "" || (!first && mdStartsBlock(t)) {
+					break
+				}
+				if !first {
+					r.b.WriteString("<br>\n")
+				}
+				r.inline(t)
+				first = false
+			}
+			r.b.WriteString("</p>\n")
+		}
+	}
+}
+
+// mdStartsBlock 该行是否开始一个新的块，用于结束段落
+func mdStartsBlock(t string) bool {
+	return strings.HasPrefix(t, "```") || strings.HasPrefix(t, ">") || mdHeading.MatchString(t) ||
+		mdRule.MatchString(t) || mdBulletItem.MatchString(t) || mdOrderedItem.MatchString(t)
+}
+
+func (r *mdRenderer) inline(s string) {
+	for i := 0; i < len(s); {
+		prev, _ := utf8.DecodeLastRuneInString(s[:i])
+		boundary := i == 0 || !isWordRune(prev)
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_~[]()#>+-.!@", s[i+1]) >= 0:
+			r.b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				r.b.WriteString("<code>" + html.EscapeString(s[i+1:i+1+end]) + "</code>")
+				i += end + 2
+				continue
+			}
+		case c == '*' || c == '~' || (c == '_' && boundary):
+			if tag, inner, n := mdEmphasis(s[i:]); n > 0 {
+				r.b.WriteString("<" + tag + ">")
+				r.inline(inner)
+				r.b.WriteString("</" + tag + ">")
+				i += n
+				continue
+			}
+		case c == '[' && !r.inLink:
+			if text, href, n := mdLink(s[i:]); n > 0 {
+				r.link(href, text)
+				i += n
+				continue
+			}
+		case c == 'h' && (boundary || prev >= utf8.RuneSelf) && !r.inLink: // 中文后可直接跟链接
+			if n := mdAutolink(s[i:]); n > 0 {
+				r.link(s[i:i+n], "")
+				i += n
+				continue
+			}
+		case c == '@' && boundary && !r.inLink:
+			if name := mentionName(s[i+1:]); name != "" {
+				if h := r.mention(name); h != "" {
+					r.b.WriteString(h)
+					i += 1 + len(name)
+					continue
+				}
+			}
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		r.b.WriteString(html.EscapeString(s[i : i+size]))
+		i += size
+	}
+}
+
+// link 输出外链，text 为空时显示地址本身
+func (r *mdRenderer) link(href, text string) {
+	r.b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer" target="_blank">`)
+	if text == "" {
+		r.b.WriteString(html.EscapeString(href))
+	} else {
+		r.inLink = true
+		r.inline(text)
+		r.inLink = false
+	}
+	r.b.WriteString("</a>")
+}
+
+// mdEmphasis 识别 **粗体**、__粗体__、~~删除线~~、*斜体*、_斜体_，返回标签、内部文本和消耗的字节数
+func mdEmphasis(s string) (tag, inner string, n int) {
+	for _, d := range [...]struct{ delim, tag string }{
+		{"**", "strong"}, {"__", "strong"}, {"~~", "del"}, {"*", "em"}, {"_", "em"},
+	} {
+		if !strings.HasPrefix(s, d.delim) {
+			continue
+		}
+		rest := s[len(d.delim):]
+		end := strings.Index(rest, d.delim)
+		if end <= 0 || unicode.IsSpace(rune(rest[0])) || unicode.IsSpace(rune(rest[end-1])) {
+			continue
+		}
+		return d.tag, rest[:end], end + 2*len(d.delim)
+	}
+	return "", "", 0
+}
+
+// mdLink 识别 [文本](地址)，地址不安全时不作为链接
+func mdLink(s string) (text, href string, n int) {
+	mid := strings.Index(s, "](")
+	if mid <= 1 || strings.ContainsAny(s[1:mid], "[]") {
+		return "", "", 0
+	}
+	end := strings.IndexByte(s[mid+2:], ')')
+	if end < 0 {
+		return "", "", 0
+	}
+	href = strings.TrimSpace(s[mid+2 : mid+2+end])
+	if !isSafeLink(href) {
+		return "", "", 0
+	}
+	return s[1:mid], href, mid + 3 + end
+}
+
+// mdAutolink 识别裸露的 http(s) 地址，遇到空白或非 ASCII 字符结束，去掉末尾标点
+func mdAutolink(s string) int {
+	if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+		return 0
+	}
+	n := 0
+	for n < len(s) && s[n] > ' ' && s[n] < utf8.RuneSelf && strings.IndexByte(`<>"`, s[n]) < 0 {
+		n++
+	}
+	for n > 0 && strings.IndexByte(".,:;!?)'", s[n-1]) >= 0 {
+		n--
+	}
+	if !IsHTTPURL(s[:n]) {
+		return 0
+	}
+	return n
+}
+
+// isSafeLink 仅允许 http/https 绝对地址和 mailto
+func isSafeLink(href string) bool {
+	if strings.ContainsAny(href, " \t\n<>\"") {
+		return false
+	}
+	if rest, ok := strings.CutPrefix(strings.ToLower(href), "mailto:"); ok {
+		return strings.Contains(rest, "@")
+	}
+	return IsHTTPURL(href)
+}
+
+// mentionName 读取 @ 之后的用户名：字母、数字、下划线，中间可含 . 和 -
+func mentionName(s string) string {
+	n, count := 0, 0
+	for n < len(s) && count < maxMentionLen {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !isWordRune(r) && r != '.' && r != '-' {
+			break
+		}
+		n += size
+		count++
+	}
+	return strings.TrimRight(s[:n], ".-")
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// IsEmoji 是否为单个 emoji（含肤色、变体选择符、ZWJ 组合、旗帜和键帽序列）
+func IsEmoji(s string) bool {
+	if s == "" || len(s) > 32 {
+		return false
+	}
+	base := false
+	for i, r := range s {
+		switch {
+		case r == 0x200D || r == 0xFE0F || r == 0xFE0E || r == 0x20E3 || (r >= 0xE0020 && r <= 0xE007F):
+			// 组合字符，不能单独出现
+		case i == 0 && (r == '#' || r == '*' || (r >= '0' && r <= '9')):
+			// 键帽序列的首字符，需配合 U+20E3
+			if !strings.HasSuffix(s, "⃣") {
+				return false
+			}
+			base = true
+		case r >= 0x1F000 && r <= 0x1FAFF, r >= 0x2600 && r <= 0x27BF, r >= 0x2300 && r <= 0x23FF,
+			r >= 0x2B00 && r <= 0x2BFF, r >= 0x2190 && r <= 0x21FF, r == 0x3030, r == 0x303D,
+			r == 0x3297, r == 0x3299, r == 0x00A9, r == 0x00AE, r == 0x203C, r == 0x2049, r == 0x2122, r == 0x2139:
+			base = true
+		default:
+			return false
+		}
+	}
+	return base
+}
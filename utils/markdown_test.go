@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	htmlTag    = regexp.MustCompile(`<(/?)([A-Za-z][A-Za-z0-9]*)([^>]*)>`)
+	htmlAttr   = regexp.MustCompile(`\s([a-z-]+)="([^"]*)"`)
+	allowedTag = map[string]bool{
+		"p": true, "br": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"blockquote": true, "ul": true, "ol": true, "li": true, "hr": true, "pre": true, "code": true,
+		"strong": true, "em": true, "del": true, "a": true, "span": true,
+	}
+	allowedAttr = map[string]bool{"href": true, "rel": true, "target": true, "class": true, "start": true, "data-user-id": true}
+)
+
+// assertSafeHTML 输出只能包含白名单标签和属性，链接只能是 http/https/mailto
+func assertSafeHTML(t *testing.T, src, out string) {
+	t.Helper()
+	for _, m := range htmlTag.FindAllStringSubmatch(out, -1) {
+		if !allowedTag[m[2]] {
+			t.Errorf("%q: unexpected tag <%s> in %q", src, m[2], out)
+		}
+		rest := htmlAttr.ReplaceAllStringFunc(m[3], func(a string) string {
+			kv := htmlAttr.FindStringSubmatch(a)
+			if !allowedAttr[kv[1]] {
+				t.Errorf("%q: unexpected attribute %s in %q", src, kv[1], out)
+			}
+			if kv[1] == "href" {
+				href := strings.ToLower(kv[2])
+				if !strings.HasPrefix(href, "http://") && !strings.HasPrefix(href, "https://") && !strings.HasPrefix(href, "mailto:") {
+					t.Errorf("%q: unsafe href %q", src, kv[2])
+				}
+			}
+			return ""
+		})
+		if strings.TrimSpace(rest) != "" {
+			t.Errorf("%q: unparsed attributes %q in %q", src, rest, out)
+		}
+	}
+}
+
+func TestRenderMarkdownXSS(t *testing.T) {
+	cases := []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`<a href="javascript:alert(1)">x</a>`,
+		`[click](javascript:alert(1))`,
+		`[click](JaVaScRiPt:alert(1))`,
+		`[click](  javascript:alert(1))`,
+		`[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)`,
+		`[click](vbscript:msgbox(1))`,
+		`[click](//evil.example/x)`,
+		`[click](https://ok.example/" onmouseover="alert(1))`,
+		`[click](https://ok.example/"onmouseover="alert(1))`,
+		`[x](https://ok.example/<script>)`,
+		`https://ok.example/"><script>alert(1)</script>`,
+		"```\"><script>alert(1)</script>\n<b>x</b>\n```",
+		"```js\" onload=\"alert(1)\nx\n```",
+		"`<svg onload=alert(1)>`",
+		`**<iframe src=x>**`,
+		`> <style>body{}</style>`,
+		`# <h1 onclick=alert(1)>`,
+		`- <details open ontoggle=alert(1)>`,
+		`@<script>`,
+		`\<script>alert(1)\</script>`,
+		`[<img src=x onerror=alert(1)>](https://ok.example)`,
+		`[a](mailto:javascript:alert(1))`,
+	}
+	for _, src := range cases {
+		out := RenderMarkdown(src, map[string]uint{"alice": 1})
+		assertSafeHTML(t, src, out)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{`<b>hi</b>`, `<p>&lt;b&gt;hi&lt;/b&gt;</p>`},
+		{`[x](javascript:alert(1))`, `<p>[x](javascript:alert(1))</p>`},
+		{`[site](https://a.example/?q=1&r=2)`, `<p><a href="https://a.example/?q=1&amp;r=2" rel="nofollow noopener noreferrer" target="_blank">site</a></p>`},
+		{`see https://a.example.`, `<p>see <a href="https://a.example" rel="nofollow noopener noreferrer" target="_blank">https://a.example</a>.</p>`},
+		{`**bold** and _em_`, `<p><strong>bold</strong> and <em>em</em></p>`},
+		{"`<i>`", `<p><code>&lt;i&gt;</code></p>`},
+		{"```go\nx := \"<a>\"\n```", `<pre><code class="language-go">x := &#34;&lt;a&gt;&#34;</code></pre>`},
+		{`hi @alice and @bob`, `<p>hi <span class="mention" data-user-id="1">@alice</span> and @bob</p>`},
+		{"- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>"},
+		{"3. c", "<ol start=\"3\">\n<li>c</li>\n</ol>"},
+	}
+	for _, c := range cases {
+		if got := RenderMarkdown(c.src, map[string]uint{"alice": 1}); got != c.want {
+			t.Errorf("RenderMarkdown(%q)\n got %q\nwant %q", c.src, got, c.want)
+		}
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	cases := []struct {
+		src  string
+		want []string
+	}{
+		{"@alice @bob @alice", []string{"alice", "bob"}},
+		{"mail me at a@b.example", nil},
+		{"`@code` and\n```\n@fenced\n```\n@real.", []string{"real"}},
+		{"[@linked](https://a.example) @ok", []string{"ok"}},
+	}
+	for _, c := range cases {
+		got := ExtractMentions(c.src)
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("ExtractMentions(%q) = %v, want %v", c.src, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+)
+
+// phashSize 计算 DCT 前缩放到的边长，取其左上 8x8 低频系数
+const phashSize = 32
+
+// PerceptualHash 计算图片的 64 位感知哈希（DCT pHash）
+// 对缩放、重新压缩、轻微裁剪与调色不敏感，相似图片的汉明距离小
+func PerceptualHash(data []byte) (uint64, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	gray := imaging.Grayscale(imaging.Resize(img, phashSize, phashSize, imaging.Lanczos))
+
+	var pixels [phashSize][phashSize]float64
+	for y := 0; y < phashSize; y++ {
+		for x := 0; x < phashSize; x++ {
+			pixels[y][x] = float64(gray.Pix[y*gray.Stride+x*4])
+		}
+	}
+	coeffs := dct2(pixels)
+
+	// 8x8 低频系数与中位数比较；直流分量只反映整体亮度，不参与中位数也不占位，最高位恒为 0
+	values := make([]float64, 0, 64)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			values = append(values, coeffs[y][x])
+		}
+	}
+	sorted := append([]float64(nil), values[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for i, v := range values[1:] {
+		if v > median {
+			hash |= 1 << uint(62-i)
+		}
+	}
+	return hash, nil
+}
+
+// dct2 二维 DCT-II，按行列分离计算
+func dct2(in [phashSize][phashSize]float64) [phashSize][phashSize]float64 {
+	var cos [phashSize][phashSize]float64
+	for k := 0; k < phashSize; k++ {
+		for n := 0; n < phashSize; n++ {
+			cos[k][n] = math.Cos(math.Pi / phashSize * (float64(n) + 0.5) * float64(k))
+		}
+	}
+	var rows, out [phashSize][phashSize]float64
+	for y := 0; y < phashSize; y++ {
+		for k := 0; k < phashSize; k++ {
+			var s float64
+			for n := 0; n < phashSize; n++ {
+				s += in[y][n] * cos[k][n]
+			}
+			rows[y][k] = s
+		}
+	}
+	for x := 0; x < phashSize; x++ {
+		for k := 0; k < phashSize; k++ {
+			var s float64
+			for n := 0; n < phashSize; n++ {
+				s += rows[n][x] * cos[k][n]
+			}
+			out[k][x] = s
+		}
+	}
+	return out
+}
+
+// HammingDistance 两个哈希不同的位数
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FormatHash 哈希转 16 位十六进制字符串，便于存储（sqlite 不支持最高位为 1 的 uint64）
+func FormatHash(h uint64) string {
+	return fmt.Sprintf("%016x", h)
+}
+
+// ParseHash FormatHash 的逆过程
+func ParseHash(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
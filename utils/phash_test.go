@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// gradientImage 左上到右下的渐变，叠加一个亮块，避免所有系数相同
+func gradientImage(w, h int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*255/w + y*255/h) / 2)
+			if x > w/4 && x < w/2 && y > h/3 && y < h*2/3 {
+				v = 255
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func TestPerceptualHash(t *testing.T) {
+	base := gradientImage(256, 256)
+	h, err := PerceptualHash(encodePNG(t, base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h>>63 != 0 {
+		t.Errorf("DC bit set: %016x", h)
+	}
+
+	cases := []struct {
+		name    string
+		img     image.Image
+		maxDist int
+	}{
+		{"identical", base, 0},
+		{"downscaled", imaging.Resize(base, 97, 97, imaging.Box), 6},
+		{"brighter", imaging.AdjustBrightness(base, 10), 6},
+	}
+	for _, c := range cases {
+		got, err := PerceptualHash(encodePNG(t, c.img))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if d := HammingDistance(h, got); d > c.maxDist {
+			t.Errorf("%s: distance %d > %d", c.name, d, c.maxDist)
+		}
+	}
+
+	flipped, err := PerceptualHash(encodePNG(t, imaging.FlipH(base)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := HammingDistance(h, flipped); d <= 10 {
+		t.Errorf("flipped image too close: distance %d", d)
+	}
+
+	if _, err := PerceptualHash([]byte("not an image")); err == nil {
+		t.Error("expected decode error")
+	}
+}
+
+func TestFormatParseHash(t *testing.T) {
+	for _, h := range []uint64{0, 1, 0x7fffffffffffffff, 0xffffffffffffffff, 0x0123456789abcdef} {
+		s := FormatHash(h)
+		if len(s) != 16 {
+			t.Errorf("FormatHash(%x) = %q, want 16 chars", h, s)
+		}
+		got, err := ParseHash(s)
+		if err != nil || got != h {
+			t.Errorf("ParseHash(%q) = %x, %v; want %x", s, got, err, h)
+		}
+	}
+}
@@ -16,3 +16,8 @@ func Error(c *gin.Context, code int, msg string) {
 func ErrorWithHttpCode(c *gin.Context, httpCode int, code int, msg string) {
 	c.JSON(httpCode, gin.H{"code": code, "message": msg})
 }
+
+// ValidationError 输出字段级校验错误
+func ValidationError(c *gin.Context, errs FieldErrors) {
+	c.JSON(http.StatusBadRequest, gin.H{"code": 1, "message": "validation failed", "errors": errs})
+}
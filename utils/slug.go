@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FoldWidth 全角字符转半角，全角空格转普通空格
+func FoldWidth(r rune) rune {
+	switch {
+	case r == '　':
+		return ' '
+	case r >= '！' && r <= '～':
+		return r - 0xFEE0
+	}
+	return r
+}
+
+// slugSymbols 有区分意义的符号按单词转写，避免 C++、C#、C 得到相同的 slug
+var slugSymbols = map[rune]string{'+': "plus", '#': "sharp", '&': "and", '@': "at"}
+
+// Slugify 生成标签 slug：全角转半角、大小写折叠，空白与 -_./ 合并为单个 "-"，
+// + # & @ 转写为独立的单词，去掉其他符号；CJK 等文字保留原样
+func Slugify(name string) string {
+	var b strings.Builder
+	pendingDash := false
+	for _, r := range name {
+		r = unicode.ToLower(FoldWidth(r))
+		if word, ok := slugSymbols[r]; ok {
+			if b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteString(word)
+			pendingDash = true
+			continue
+		}
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if pendingDash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingDash = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '_' || r == '.' || r == '/':
+			pendingDash = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,34 @@
+package utils
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"Anime", "anime"},
+		{"  Blue  Sky ", "blue-sky"},
+		{"blue_sky", "blue-sky"},
+		{"blue--sky", "blue-sky"},
+		{"v1.5/SDXL", "v1-5-sdxl"},
+		{"-lead and trail-", "lead-and-trail"},
+		{"C++", "c-plus-plus"},
+		{"C#", "c-sharp"},
+		{"C", "c"},
+		{"C++ & C#!", "c-plus-plus-and-c-sharp"},
+		{"R&D", "r-and-d"},
+		{"@home", "at-home"},
+		{"Ｃ＃", "c-sharp"},
+		{"ＳＤＸＬ　１．０", "sdxl-1-0"},
+		{"猫耳", "猫耳"},
+		{"赛博 朋克", "赛博-朋克"},
+		{"!!!", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := Slugify(c.in); got != c.want {
+			t.Errorf("Slugify(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
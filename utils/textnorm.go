@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// homoglyphs 外形与拉丁字母相同的西里尔、希腊字母等，归一到对应的小写拉丁字母
+var homoglyphs = map[rune]rune{
+	// 西里尔
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p',
+	'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i', 'ї': 'i', 'ј': 'j', 'ԁ': 'd',
+	'ԛ': 'q', 'ԝ': 'w', 'һ': 'h', 'ӏ': 'l',
+	// 希腊
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+	'τ': 't', 'υ': 'u', 'χ': 'x', 'ζ': 'z',
+	// 拉丁变体
+	'ı': 'i', 'ȷ': 'j', 'ℓ': 'l', 'ſ': 's', 'ɑ': 'a', 'ɡ': 'g', 'ɩ': 'i', 'ʀ': 'r',
+}
+
+// NormalizeText 内容过滤前的归一化：全角转半角、大小写折叠、形近字母替换为拉丁字母，并去掉零宽字符等格式控制符
+func NormalizeText(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		r = unicode.ToLower(FoldWidth(r))
+		if h, ok := homoglyphs[r]; ok {
+			r = h
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldErrors 字段级校验错误，key 为 json 字段名
+type FieldErrors map[string]string
+
+// Add 记录字段错误，同一字段的多条不同错误以 "; " 连接
+func (e FieldErrors) Add(field, msg string) {
+	prev, ok := e[field]
+	switch {
+	case !ok:
+		e[field] = msg
+	case !strings.Contains("; "+prev+"; ", "; "+msg+"; "):
+		e[field] = prev + "; " + msg
+	}
+}
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for f := range e {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f + ": " + e[f]
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Err 没有错误时返回 nil，便于直接作为 error 返回
+func (e FieldErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// CheckLen 字段超过 max 个字符时记录错误
+func CheckLen(errs FieldErrors, field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		errs.Add(field, fmt.Sprintf("must be at most %d characters", max))
+	}
+}
+
+// IsHTTPURL 是否为合法的 http/https 绝对地址
+func IsHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}